@@ -0,0 +1,21 @@
+package main
+
+import (
+	"flag"
+	"log"
+	"net/http"
+
+	"github.com/ranktify/ranktify-be/internal/spotify/spotifytest"
+)
+
+// Serves the fake Spotify on a fixed port, point the backend at it with
+// SPOTIFY_API_URL=http://localhost:<port>/v1 and SPOTIFY_ACCOUNTS_URL=http://localhost:<port>
+func main() {
+	addr := flag.String("addr", ":9191", "Address the fake Spotify listens on")
+	flag.Parse()
+
+	log.Printf("Fake Spotify listening on %s", *addr)
+	if err := http.ListenAndServe(*addr, spotifytest.NewHandler()); err != nil {
+		log.Fatal(err)
+	}
+}
@@ -1,19 +1,24 @@
 package main
 
 import (
+	"context"
+	"database/sql"
 	"flag"
+	"log"
 	"os"
 	"time"
 
 	"github.com/gin-contrib/cors"
 	"github.com/gin-gonic/gin"
 	"github.com/ranktify/ranktify-be/config"
+	"github.com/ranktify/ranktify-be/internal/dao"
 	"github.com/ranktify/ranktify-be/internal/jwt"
 	"github.com/ranktify/ranktify-be/internal/route"
 )
 
 func main() {
 	genTokensAndExit := flag.Bool("jwt", false, "Generate JWT tokens and terminates program")
+	reencryptAndExit := flag.Bool("reencrypt-tokens", false, "Re-encrypts the stored refresh tokens under TOKEN_ENCRYPTION_KEY_ID and terminates program")
 	flag.Parse()
 
 	if *genTokensAndExit {
@@ -21,6 +26,11 @@ func main() {
 		return
 	}
 
+	if *reencryptAndExit {
+		reencryptRefreshTokens(config.SetupConnection())
+		return
+	}
+
 	router := gin.Default()
 
 	// below is the cors setup for browser testing
@@ -47,6 +57,9 @@ func main() {
 		route.SongRecommendationRoutes(mainGroup, db)
 		route.StreakRoutes(mainGroup, db)
 		route.ImpressionRoutes(mainGroup, db)
+		route.SongRoutes(mainGroup, db)
+		route.ArtistRoutes(mainGroup, db)
+		route.GenreRoutes(mainGroup, db)
 	}
 	port := os.Getenv("PORT")
 	if port == "" {
@@ -56,3 +69,20 @@ func main() {
 		panic(err)
 	}
 }
+
+// Moves the Spotify and JWT refresh tokens to the current encryption key, plaintext
+// rows from before encryption get encrypted. Old keys must stay configured until it runs.
+func reencryptRefreshTokens(db *sql.DB) {
+	ctx := context.Background()
+
+	spotifyCount, err := dao.NewSpotifyDAO(db).ReencryptRefreshTokens(ctx)
+	if err != nil {
+		log.Fatalf("Couldn't re-encrypt the Spotify refresh tokens, error: %s", err)
+	}
+	jwtCount, err := dao.NewTokensDAO(db).ReencryptRefreshTokens(ctx)
+	if err != nil {
+		log.Fatalf("Couldn't re-encrypt the JWT refresh tokens, error: %s", err)
+	}
+
+	log.Printf("Re-encrypted %d Spotify and %d JWT refresh tokens", spotifyCount, jwtCount)
+}
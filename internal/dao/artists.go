@@ -0,0 +1,131 @@
+package dao
+
+import (
+	"context"
+	"database/sql"
+
+	"github.com/ranktify/ranktify-be/internal/model"
+)
+
+type ArtistsDAO struct {
+	DB *sql.DB
+}
+
+func NewArtistsDAO(db *sql.DB) *ArtistsDAO {
+	return &ArtistsDAO{DB: db}
+}
+
+func (dao *ArtistsDAO) GetArtistByID(ctx context.Context, artistID uint64) (*model.Artist, error) {
+	query := `
+		SELECT artist_id, spotify_id, name
+		FROM artists
+		WHERE artist_id = $1
+	`
+	var artist model.Artist
+	err := dao.DB.QueryRowContext(ctx, query, artistID).Scan(
+		&artist.ArtistID,
+		&artist.SpotifyID,
+		&artist.Name,
+	)
+	if err != nil {
+		return nil, err
+	}
+	return &artist, nil
+}
+
+// GetArtistSongs returns every stored song of the artist, main or featured, with its
+// average rank across all users, best ranked first
+func (dao *ArtistsDAO) GetArtistSongs(ctx context.Context, artistID uint64) ([]model.ArtistSong, error) {
+	query := `
+		SELECT
+			s.song_id,
+			s.spotify_id,
+			s.title,
+			s.artist,
+			s.album,
+			s.release_date,
+			s.genre,
+			s.cover_uri,
+			s.preview_uri,
+			s.created_at,
+			AVG(r.rank)::float8 AS avg_rank,
+			COUNT(r.ranking_id) AS ratings_count
+		FROM song_artists sa
+		JOIN songs s ON s.song_id = sa.song_id
+		LEFT JOIN rankings r ON r.song_id = s.song_id
+		WHERE sa.artist_id = $1
+		GROUP BY s.song_id
+		ORDER BY
+			avg_rank DESC NULLS LAST,
+			ratings_count DESC
+	`
+	return dao.queryArtistSongs(ctx, query, artistID)
+}
+
+// GetTopArtistSongsAmongFriends returns the artist's songs ranked by the user's friends,
+// ordered by the friends' average rank
+func (dao *ArtistsDAO) GetTopArtistSongsAmongFriends(ctx context.Context, userID uint64, artistID uint64, limit int) ([]model.ArtistSong, error) {
+	query := `
+		SELECT
+			s.song_id,
+			s.spotify_id,
+			s.title,
+			s.artist,
+			s.album,
+			s.release_date,
+			s.genre,
+			s.cover_uri,
+			s.preview_uri,
+			s.created_at,
+			AVG(r.rank)::float8 AS avg_rank,
+			COUNT(*)            AS ratings_count
+		FROM friends f
+		JOIN rankings r
+		ON (
+				(f.user_id   = $1 AND f.friend_id = r.user_id)
+			OR (f.friend_id = $1 AND f.user_id   = r.user_id)
+			)
+		JOIN song_artists sa ON sa.song_id = r.song_id AND sa.artist_id = $2
+		JOIN songs s ON s.song_id = r.song_id
+		GROUP BY s.song_id
+		ORDER BY
+			avg_rank      DESC,
+			ratings_count DESC
+		LIMIT $3
+	`
+	return dao.queryArtistSongs(ctx, query, userID, artistID, limit)
+}
+
+func (dao *ArtistsDAO) queryArtistSongs(ctx context.Context, query string, args ...any) ([]model.ArtistSong, error) {
+	rows, err := dao.DB.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	songs := []model.ArtistSong{}
+	for rows.Next() {
+		var song model.ArtistSong
+		if err := rows.Scan(
+			&song.SongID,
+			&song.SpotifyID,
+			&song.Title,
+			&song.Artist,
+			&song.Album,
+			&song.ReleaseDate,
+			&song.Genre,
+			&song.CoverURI,
+			&song.PreviewURI,
+			&song.CreatedAt,
+			&song.AvgRank,
+			&song.RatingsCount,
+		); err != nil {
+			return nil, err
+		}
+		songs = append(songs, song)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return songs, nil
+}
@@ -6,6 +6,7 @@ import (
 	"fmt"
 
 	"github.com/ranktify/ranktify-be/internal/model"
+	"github.com/ranktify/ranktify-be/internal/rating"
 )
 
 type FriendsDAO struct {
@@ -165,6 +166,23 @@ func (dao *FriendsDAO) DeleteFriendByID(userID uint64, friendID uint64) error {
 	return nil
 }
 
+// AreFriends reports whether the two users are friends
+func (dao *FriendsDAO) AreFriends(ctx context.Context, userID uint64, otherUserID uint64) (bool, error) {
+	query := `
+		SELECT EXISTS (
+			SELECT 1
+			FROM friends
+			WHERE (user_id = $1 AND friend_id = $2)
+				OR (user_id = $2 AND friend_id = $1)
+		)
+	`
+	var friends bool
+	if err := dao.DB.QueryRowContext(ctx, query, userID, otherUserID).Scan(&friends); err != nil {
+		return false, err
+	}
+	return friends, nil
+}
+
 func (dao *FriendsDAO) SendFriendRequest(userID uint64, receiverID uint64) error {
 	query := `
 		INSERT INTO friend_requests (sender_id, receiver_id)
@@ -201,62 +219,47 @@ func (dao *FriendsDAO) DeleteFriendRequest(requestID uint64) error {
 	return nil
 }
 
-type topSongsStruct struct {
-	model.Song
-	AvgRank      float64 `json:"avg_rank"`
-	RatingsCount int     `json:"rating_count"`
-}
-
-func (dao *FriendsDAO) GetTopNTracksAmongFriends(ctx context.Context, userID uint64, limit int) ([]topSongsStruct, error) {
-	query := `
-		SELECT
-		s.song_id,
-		s.title,
-		s.artist,
-		cover_uri,
-		preview_uri,
-		AVG(r.rank) AS avg_rank,
-		COUNT(*)    AS ratings_count
-		FROM friends f
-		JOIN rankings r
-		ON (
-				(f.user_id   = $1 AND f.friend_id = r.user_id)
-			OR (f.friend_id = $1 AND f.user_id   = r.user_id)
-			)
-		JOIN songs s
-		ON s.song_id = r.song_id
-		GROUP BY
-		s.song_id,
-		s.title,
-		s.artist
-		ORDER BY
-		avg_rank      DESC,
-		ratings_count DESC
-		LIMIT $2;
-    `
+// friendRankings narrows chartScores to the rankings of the friends of the user in $7
+const friendRankings = `
+	r.user_id IN (
+		SELECT friend_id FROM friends WHERE user_id = $7
+		UNION
+		SELECT user_id FROM friends WHERE friend_id = $7
+	)
+`
 
-	rows, err := dao.DB.QueryContext(ctx, query, userID, limit)
+// GetTopTracksRankedByFriends returns the limit songs the user's friends ranked the
+// highest, scored and ordered by the database like the charts are
+func (dao *FriendsDAO) GetTopTracksRankedByFriends(ctx context.Context, userID uint64, scorer rating.Scorer, prior rating.Prior, limit int) ([]model.SongRankingAggregate, error) {
+	query := chartScores(scorer, friendRankings) + `
+		SELECT song_id, avg_rank, ratings_count, score
+		FROM scores
+		ORDER BY ` + chartOrder + `
+		LIMIT $8
+	`
+	rows, err := dao.DB.QueryContext(ctx, query, nil, nil, "", "",
+		prior.Mean, prior.Weight, userID, limit,
+	)
 	if err != nil {
 		return nil, err
 	}
 	defer rows.Close()
 
-	var topSongs []topSongsStruct
+	var aggregates []model.SongRankingAggregate
 	for rows.Next() {
-
-		var topSong topSongsStruct
+		var aggregate model.SongRankingAggregate
 		if err := rows.Scan(
-			&topSong.SongID,
-			&topSong.Title,
-			&topSong.Artist,
-			&topSong.CoverURI,
-			&topSong.PreviewURI,
-			&topSong.AvgRank,
-			&topSong.RatingsCount,
+			&aggregate.SongID,
+			&aggregate.AvgRank,
+			&aggregate.RatingsCount,
+			&aggregate.Score,
 		); err != nil {
 			return nil, err
 		}
-		topSongs = append(topSongs, topSong)
+		aggregates = append(aggregates, aggregate)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
 	}
-	return topSongs, nil
+	return aggregates, nil
 }
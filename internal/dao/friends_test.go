@@ -0,0 +1,35 @@
+package dao
+
+import (
+	"context"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/ranktify/ranktify-be/internal/rating"
+	"github.com/ranktify/ranktify-be/internal/testutil"
+)
+
+func TestGetTopTracksRankedByFriends(t *testing.T) {
+	db, mock := testutil.MockDB(t)
+	scorer, _ := rating.ScorerByName("bayesian")
+	prior := rating.Prior{Mean: 3.5, Weight: 2}
+
+	// scored, ordered and limited by the database, the rows come back in chart order
+	mock.ExpectQuery(`SELECT friend_id FROM friends WHERE user_id = \$7(?s).*ORDER BY score DESC, ratings_count DESC, song_id\s+LIMIT \$8`).
+		WithArgs(nil, nil, "", "", prior.Mean, prior.Weight, 1, 5).
+		WillReturnRows(sqlmock.NewRows([]string{"song_id", "avg_rank", "ratings_count", "score"}).
+			AddRow(20, 5.0, 3, 4.4).
+			AddRow(10, 4.0, 1, 3.67),
+		)
+
+	aggregates, err := NewFriendsDAO(db).GetTopTracksRankedByFriends(context.Background(), 1, scorer, prior, 5)
+	if err != nil {
+		t.Fatalf("GetTopTracksRankedByFriends: %v", err)
+	}
+	if len(aggregates) != 2 || aggregates[0].SongID != 20 || aggregates[0].Score != 4.4 || aggregates[1].SongID != 10 {
+		t.Errorf("GetTopTracksRankedByFriends = %+v, want songs 20 and 10 with their scores", aggregates)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Error(err)
+	}
+}
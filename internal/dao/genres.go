@@ -0,0 +1,176 @@
+package dao
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+
+	"github.com/lib/pq"
+	"github.com/ranktify/ranktify-be/internal/model"
+)
+
+// ErrGenreTaken is returned when another genre already has the search key
+var ErrGenreTaken = errors.New("genre search key already taken")
+
+type GenresDAO struct {
+	DB *sql.DB
+}
+
+func NewGenresDAO(db *sql.DB) *GenresDAO {
+	return &GenresDAO{DB: db}
+}
+
+const genreColumns = `genre_id, search_key, name_en, name_es, markets, enabled, created_at, updated_at`
+
+type rowScanner interface {
+	Scan(dest ...any) error
+}
+
+func scanGenre(row rowScanner) (*model.Genre, error) {
+	var genre model.Genre
+	err := row.Scan(&genre.GenreID, &genre.SearchKey, &genre.NameEN, &genre.NameES,
+		pq.Array(&genre.Markets), &genre.Enabled, &genre.CreatedAt, &genre.UpdatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+	if genre.Markets == nil {
+		genre.Markets = []string{}
+	}
+	return &genre, nil
+}
+
+// GetGenres returns the genres offered in market (every genre when it's empty),
+// ordered by their English name. Disabled genres are left out unless includeDisabled.
+func (dao *GenresDAO) GetGenres(ctx context.Context, market string, includeDisabled bool) ([]model.Genre, error) {
+	query := `
+		SELECT ` + genreColumns + `
+		FROM genres
+		WHERE ($1 = '' OR cardinality(markets) = 0 OR $1 = ANY(markets))
+			AND (enabled OR $2)
+		ORDER BY name_en
+	`
+	rows, err := dao.DB.QueryContext(ctx, query, market, includeDisabled)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	genres := []model.Genre{}
+	for rows.Next() {
+		genre, err := scanGenre(rows)
+		if err != nil {
+			return nil, err
+		}
+		genres = append(genres, *genre)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+	return genres, nil
+}
+
+// GetGenreBySearchKey returns the genre with the search key, nil when there is none
+func (dao *GenresDAO) GetGenreBySearchKey(ctx context.Context, searchKey string) (*model.Genre, error) {
+	query := `
+		SELECT ` + genreColumns + `
+		FROM genres
+		WHERE search_key = $1
+	`
+	genre, err := scanGenre(dao.DB.QueryRowContext(ctx, query, searchKey))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return genre, nil
+}
+
+// GetRandomGenre returns a random enabled genre offered in market, nil when the
+// market has none
+func (dao *GenresDAO) GetRandomGenre(ctx context.Context, market string) (*model.Genre, error) {
+	query := `
+		SELECT ` + genreColumns + `
+		FROM genres
+		WHERE enabled
+			AND (cardinality(markets) = 0 OR $1 = ANY(markets))
+		ORDER BY random()
+		LIMIT 1
+	`
+	genre, err := scanGenre(dao.DB.QueryRowContext(ctx, query, market))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return genre, nil
+}
+
+// CreateGenre adds the genre to the catalog, ErrGenreTaken when the search key is in use
+func (dao *GenresDAO) CreateGenre(ctx context.Context, genre *model.Genre) error {
+	query := `
+		INSERT INTO genres (search_key, name_en, name_es, markets, enabled)
+		VALUES ($1, $2, $3, $4, $5)
+		RETURNING genre_id, created_at, updated_at
+	`
+	err := dao.DB.QueryRowContext(ctx, query, genre.SearchKey, genre.NameEN, genre.NameES,
+		pq.Array(genre.Markets), genre.Enabled,
+	).Scan(&genre.GenreID, &genre.CreatedAt, &genre.UpdatedAt)
+	if err != nil {
+		var pqErr *pq.Error
+		if errors.As(err, &pqErr) && pqErr.Code.Name() == "unique_violation" {
+			return ErrGenreTaken
+		}
+		return err
+	}
+	return nil
+}
+
+// UpdateGenre replaces the genre's search key, names and markets, SetGenreEnabled
+// toggles it. sql.ErrNoRows when it doesn't exist and ErrGenreTaken when the search
+// key is in use.
+func (dao *GenresDAO) UpdateGenre(ctx context.Context, genre *model.Genre) error {
+	query := `
+		UPDATE genres
+		SET search_key = $2, name_en = $3, name_es = $4, markets = $5, updated_at = NOW()
+		WHERE genre_id = $1
+		RETURNING enabled, created_at, updated_at
+	`
+	err := dao.DB.QueryRowContext(ctx, query, genre.GenreID, genre.SearchKey, genre.NameEN, genre.NameES,
+		pq.Array(genre.Markets),
+	).Scan(&genre.Enabled, &genre.CreatedAt, &genre.UpdatedAt)
+	if err != nil {
+		var pqErr *pq.Error
+		if errors.As(err, &pqErr) && pqErr.Code.Name() == "unique_violation" {
+			return ErrGenreTaken
+		}
+		return err
+	}
+	return nil
+}
+
+// SetGenreEnabled enables or disables the genre, sql.ErrNoRows when it doesn't exist
+func (dao *GenresDAO) SetGenreEnabled(ctx context.Context, genreID uint64, enabled bool) error {
+	query := `
+		UPDATE genres
+		SET enabled = $2, updated_at = NOW()
+		WHERE genre_id = $1
+	`
+	result, err := dao.DB.ExecContext(ctx, query, genreID, enabled)
+	if err != nil {
+		return err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rowsAffected == 0 {
+		return sql.ErrNoRows
+	}
+
+	return nil
+}
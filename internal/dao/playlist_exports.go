@@ -0,0 +1,53 @@
+package dao
+
+import (
+	"context"
+	"database/sql"
+
+	"github.com/ranktify/ranktify-be/internal/model"
+)
+
+type PlaylistExportsDAO struct {
+	DB *sql.DB
+}
+
+func NewPlaylistExportsDAO(db *sql.DB) *PlaylistExportsDAO {
+	return &PlaylistExportsDAO{DB: db}
+}
+
+// GetPlaylistExport returns the playlist the user exported their rankings of at least
+// minRank to, nil when they never did
+func (dao *PlaylistExportsDAO) GetPlaylistExport(ctx context.Context, userID uint64, minRank int) (*model.PlaylistExport, error) {
+	query := `
+		SELECT user_id, min_rank, playlist_id, track_count, exported_at
+		FROM spotify_playlist_exports
+		WHERE user_id = $1
+			AND min_rank = $2
+	`
+	var export model.PlaylistExport
+	err := dao.DB.QueryRowContext(ctx, query, userID, minRank).Scan(
+		&export.UserID, &export.MinRank, &export.PlaylistID, &export.TrackCount, &export.ExportedAt,
+	)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &export, nil
+}
+
+// SavePlaylistExport remembers the export, replacing the previous one for the same
+// minimum rank
+func (dao *PlaylistExportsDAO) SavePlaylistExport(ctx context.Context, export *model.PlaylistExport) error {
+	query := `
+		INSERT INTO spotify_playlist_exports (user_id, min_rank, playlist_id, track_count, exported_at)
+		VALUES ($1, $2, $3, $4, NOW())
+		ON CONFLICT (user_id, min_rank) DO UPDATE
+		SET playlist_id = EXCLUDED.playlist_id,
+			track_count = EXCLUDED.track_count,
+			exported_at = EXCLUDED.exported_at
+		RETURNING exported_at
+	`
+	return dao.DB.QueryRowContext(ctx, query, export.UserID, export.MinRank, export.PlaylistID, export.TrackCount).Scan(&export.ExportedAt)
+}
@@ -0,0 +1,134 @@
+package dao
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"github.com/lib/pq"
+	"github.com/ranktify/ranktify-be/internal/model"
+)
+
+type PreviewCacheDAO struct {
+	DB *sql.DB
+}
+
+func NewPreviewCacheDAO(db *sql.DB) *PreviewCacheDAO {
+	return &PreviewCacheDAO{DB: db}
+}
+
+// GetPreviews returns the unexpired cache entries of the tracks keyed by spotify id, a
+// nil preview means the track is known to have none. Tracks missing from the map
+// have to be resolved.
+func (dao *PreviewCacheDAO) GetPreviews(ctx context.Context, spotifyIDs []string) (map[string]*string, error) {
+	query := `
+		SELECT spotify_id, preview_uri
+		FROM preview_cache
+		WHERE spotify_id = ANY($1)
+			AND expires_at > NOW()
+	`
+	rows, err := dao.DB.QueryContext(ctx, query, pq.Array(spotifyIDs))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	previews := make(map[string]*string, len(spotifyIDs))
+	for rows.Next() {
+		var spotifyID string
+		var previewURI *string
+		if err := rows.Scan(&spotifyID, &previewURI); err != nil {
+			return nil, err
+		}
+		previews[spotifyID] = previewURI
+	}
+	return previews, rows.Err()
+}
+
+// SavePreview caches the track's preview (nil when it has none) for ttl and copies it
+// to the stored song, if there is one
+func (dao *PreviewCacheDAO) SavePreview(ctx context.Context, spotifyID string, previewURI *string, ttl time.Duration) (err error) {
+	tx, err := dao.DB.BeginTx(ctx, &sql.TxOptions{})
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err != nil {
+			tx.Rollback()
+		} else {
+			err = tx.Commit()
+		}
+	}()
+
+	_, err = tx.ExecContext(ctx, `
+		INSERT INTO preview_cache (spotify_id, preview_uri, resolved_at, expires_at)
+		VALUES ($1, $2, NOW(), NOW() + make_interval(secs => $3))
+		ON CONFLICT (spotify_id) DO UPDATE
+		SET preview_uri = EXCLUDED.preview_uri,
+			resolved_at = EXCLUDED.resolved_at,
+			expires_at  = EXCLUDED.expires_at
+	`, spotifyID, previewURI, ttl.Seconds())
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.ExecContext(ctx, `
+		UPDATE songs
+		SET preview_uri = $2
+		WHERE spotify_id = $1
+	`, spotifyID, previewURI)
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.ExecContext(ctx, `
+		DELETE FROM preview_failures
+		WHERE spotify_id = $1
+	`, spotifyID)
+	return err
+}
+
+// RecordPreviewFailure backs the track off the refresh job after a failed lookup, for
+// an hour after the first failure and twice as long after each one since, a week at most
+func (dao *PreviewCacheDAO) RecordPreviewFailure(ctx context.Context, spotifyID string) error {
+	_, err := dao.DB.ExecContext(ctx, `
+		INSERT INTO preview_failures (spotify_id, failures, failed_at, retry_at)
+		VALUES ($1, 1, NOW(), NOW() + INTERVAL '1 hour')
+		ON CONFLICT (spotify_id) DO UPDATE
+		SET failures  = preview_failures.failures + 1,
+			failed_at = EXCLUDED.failed_at,
+			retry_at  = NOW() + make_interval(hours => LEAST(power(2, preview_failures.failures), 168)::int)
+	`, spotifyID)
+	return err
+}
+
+// GetSongsWithStalePreviews returns up to limit stored songs whose preview was never
+// resolved or has expired, the longest expired first. Songs whose lookup failed wait
+// out their backoff and come after the rest, the fewest failures first.
+func (dao *PreviewCacheDAO) GetSongsWithStalePreviews(ctx context.Context, limit int) ([]model.Song, error) {
+	query := `
+		SELECT s.song_id, s.spotify_id, s.title, s.artist, s.preview_uri
+		FROM songs s
+		LEFT JOIN preview_cache pc ON pc.spotify_id = s.spotify_id
+		LEFT JOIN preview_failures pf ON pf.spotify_id = s.spotify_id
+		WHERE (pc.spotify_id IS NULL OR pc.expires_at <= NOW())
+			AND (pf.spotify_id IS NULL OR pf.retry_at <= NOW())
+		ORDER BY pf.failures NULLS FIRST, pc.expires_at NULLS FIRST, s.song_id
+		LIMIT $1
+	`
+	rows, err := dao.DB.QueryContext(ctx, query, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var songs []model.Song
+	for rows.Next() {
+		var song model.Song
+		if err := rows.Scan(&song.SongID, &song.SpotifyID, &song.Title, &song.Artist, &song.PreviewURI); err != nil {
+			return nil, err
+		}
+		songs = append(songs, song)
+	}
+	return songs, rows.Err()
+}
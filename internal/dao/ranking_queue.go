@@ -0,0 +1,167 @@
+package dao
+
+import (
+	"context"
+	"database/sql"
+
+	"github.com/lib/pq"
+	"github.com/ranktify/ranktify-be/internal/model"
+)
+
+type RankingQueueDAO struct {
+	DB *sql.DB
+}
+
+func NewRankingQueueDAO(db *sql.DB) *RankingQueueDAO {
+	return &RankingQueueDAO{DB: db}
+}
+
+// EnqueueSongs appends the songs to the end of the user's queue in the given order.
+// Songs already queued or ranked are skipped, returns how many were added.
+func (dao *RankingQueueDAO) EnqueueSongs(ctx context.Context, userID uint64, songIDs []uint64, source string) (added int64, err error) {
+	tx, err := dao.DB.BeginTx(ctx, &sql.TxOptions{})
+	if err != nil {
+		return 0, err
+	}
+	defer func() {
+		if err != nil {
+			tx.Rollback()
+		} else {
+			err = tx.Commit()
+		}
+	}()
+
+	// locks the user so concurrent imports don't interleave their positions
+	_, err = tx.ExecContext(ctx, `SELECT 1 FROM users WHERE id = $1 FOR UPDATE`, userID)
+	if err != nil {
+		return 0, err
+	}
+
+	var lastPosition int
+	err = tx.QueryRowContext(ctx, `
+		SELECT COALESCE(MAX(position), 0)
+		FROM ranking_queue
+		WHERE user_id = $1
+	`, userID).Scan(&lastPosition)
+	if err != nil {
+		return 0, err
+	}
+
+	ids := make([]int64, len(songIDs))
+	for i, id := range songIDs {
+		ids[i] = int64(id)
+	}
+	result, err := tx.ExecContext(ctx, `
+		INSERT INTO ranking_queue (user_id, song_id, position, source, added_at)
+		SELECT $1, q.song_id, $2 + q.ordinality, $3, NOW()
+		FROM unnest($4::int[]) WITH ORDINALITY AS q(song_id, ordinality)
+		WHERE NOT EXISTS (
+			SELECT 1
+			FROM rankings r
+			WHERE r.user_id = $1
+				AND r.song_id = q.song_id
+		)
+		ON CONFLICT (user_id, song_id) DO NOTHING
+	`, userID, lastPosition, source, pq.Array(ids))
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+// GetQueuedSongs returns the first limit songs of the user's queue, in order. Songs the
+// user ranked in the meantime are left out.
+func (dao *RankingQueueDAO) GetQueuedSongs(ctx context.Context, userID uint64, limit int) ([]model.QueuedSong, error) {
+	query := `
+		SELECT
+			s.song_id,
+			s.spotify_id,
+			s.title,
+			s.artist,
+			s.album,
+			s.release_date,
+			s.genre,
+			s.cover_uri,
+			s.preview_uri,
+			s.created_at,
+			q.position,
+			q.source,
+			q.added_at
+		FROM ranking_queue q
+		JOIN songs s ON s.song_id = q.song_id
+		WHERE q.user_id = $1
+			AND NOT EXISTS (
+				SELECT 1
+				FROM rankings r
+				WHERE r.user_id = q.user_id
+					AND r.song_id = q.song_id
+			)
+		ORDER BY q.position
+		LIMIT $2
+	`
+	rows, err := dao.DB.QueryContext(ctx, query, userID, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var songs []model.QueuedSong
+	for rows.Next() {
+		var song model.QueuedSong
+		if err := rows.Scan(
+			&song.SongID,
+			&song.SpotifyID,
+			&song.Title,
+			&song.Artist,
+			&song.Album,
+			&song.ReleaseDate,
+			&song.Genre,
+			&song.CoverURI,
+			&song.PreviewURI,
+			&song.CreatedAt,
+			&song.Position,
+			&song.Source,
+			&song.AddedAt,
+		); err != nil {
+			return nil, err
+		}
+		songs = append(songs, song)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return songs, nil
+}
+
+// CountQueuedSongs returns how many songs are left in the user's queue, leaving out the
+// ones the user ranked in the meantime like GetQueuedSongs does
+func (dao *RankingQueueDAO) CountQueuedSongs(ctx context.Context, userID uint64) (int, error) {
+	var count int
+	err := dao.DB.QueryRowContext(ctx, `
+		SELECT COUNT(*)
+		FROM ranking_queue q
+		WHERE q.user_id = $1
+			AND NOT EXISTS (
+				SELECT 1
+				FROM rankings r
+				WHERE r.user_id = q.user_id
+					AND r.song_id = q.song_id
+			)
+	`, userID).Scan(&count)
+	if err != nil {
+		return 0, err
+	}
+	return count, nil
+}
+
+// ClearQueue empties the user's queue, returning how many songs were removed
+func (dao *RankingQueueDAO) ClearQueue(ctx context.Context, userID uint64) (int64, error) {
+	result, err := dao.DB.ExecContext(ctx, `
+		DELETE FROM ranking_queue
+		WHERE user_id = $1
+	`, userID)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
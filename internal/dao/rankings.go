@@ -7,7 +7,9 @@ import (
 
 	"time"
 
+	"github.com/lib/pq"
 	"github.com/ranktify/ranktify-be/internal/model"
+	"github.com/ranktify/ranktify-be/internal/rating"
 )
 
 type RankingsDao struct {
@@ -49,6 +51,213 @@ func (dao *RankingsDao) GetRankedSongs(userID uint64) ([]model.Rankings, error)
 	return rankings, nil
 }
 
+// GetRankedSongsByPairwiseRating returns the user's rankings in the strict personal
+// order given by the pairwise ratings. Songs never compared fall back to a rating
+// seeded from their absolute rank.
+func (dao *RankingsDao) GetRankedSongsByPairwiseRating(userID uint64) ([]model.Rankings, error) {
+	query := `
+		SELECT
+			r.ranking_id,
+			r.song_id,
+			r.user_id,
+			r.rank,
+			COALESCE(p.rating, $2::float8 + (r.rank - 3) * $3::float8) AS rating
+		FROM rankings r
+		LEFT JOIN pairwise_ratings p
+			ON p.user_id = r.user_id AND p.song_id = r.song_id
+		WHERE r.user_id = $1
+		ORDER BY
+			rating DESC,
+			r.rank DESC,
+			r.updated_at DESC
+	`
+	rows, err := dao.DB.Query(query, userID, rating.InitialRating, rating.RankStep)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var rankings []model.Rankings
+	for rows.Next() {
+		var ranking model.Rankings
+		var songRating float64
+		if err := rows.Scan(
+			&ranking.RankingID,
+			&ranking.SongID,
+			&ranking.UserID,
+			&ranking.Rank,
+			&songRating,
+		); err != nil {
+			return nil, err
+		}
+		ranking.Rating = &songRating
+		rankings = append(rankings, ranking)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return rankings, nil
+}
+
+// GetPairwiseCandidates picks two of the user's ranked songs to compare. The first
+// is the song with the fewest comparisons, the second one with a close rating so
+// every answer is informative.
+func (dao *RankingsDao) GetPairwiseCandidates(ctx context.Context, userID uint64) ([]model.PairwiseSong, error) {
+	query := `
+		WITH candidates AS (
+			SELECT
+				r.song_id,
+				r.rank,
+				COALESCE(p.rating, $2::float8 + (r.rank - 3) * $3::float8) AS rating,
+				COALESCE(p.comparisons, 0)                 AS comparisons
+			FROM rankings r
+			LEFT JOIN pairwise_ratings p
+				ON p.user_id = r.user_id AND p.song_id = r.song_id
+			WHERE r.user_id = $1
+		), first_song AS (
+			SELECT *
+			FROM candidates
+			ORDER BY comparisons, RANDOM()
+			LIMIT 1
+		), second_song AS (
+			SELECT c.*
+			FROM candidates c, first_song f
+			WHERE c.song_id <> f.song_id
+			-- closest rating wins, jittered so the same pair doesn't keep coming back
+			ORDER BY ABS(c.rating - f.rating) * (0.5 + RANDOM()), c.comparisons
+			LIMIT 1
+		), pair AS (
+			SELECT * FROM first_song
+			UNION ALL
+			SELECT * FROM second_song
+		)
+		SELECT
+			s.song_id,
+			s.spotify_id,
+			s.title,
+			s.artist,
+			s.album,
+			s.release_date,
+			s.genre,
+			s.cover_uri,
+			s.preview_uri,
+			s.created_at,
+			pair.rank,
+			pair.rating,
+			pair.comparisons
+		FROM pair
+		JOIN songs s ON s.song_id = pair.song_id
+	`
+	rows, err := dao.DB.QueryContext(ctx, query, userID, rating.InitialRating, rating.RankStep)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var songs []model.PairwiseSong
+	for rows.Next() {
+		var song model.PairwiseSong
+		if err := rows.Scan(
+			&song.SongID,
+			&song.SpotifyID,
+			&song.Title,
+			&song.Artist,
+			&song.Album,
+			&song.ReleaseDate,
+			&song.Genre,
+			&song.CoverURI,
+			&song.PreviewURI,
+			&song.CreatedAt,
+			&song.Rank,
+			&song.Rating,
+			&song.Comparisons,
+		); err != nil {
+			return nil, err
+		}
+		songs = append(songs, song)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return songs, nil
+}
+
+// RecordPairwiseComparison applies the outcome of a "this or that" comparison to the
+// user's Elo ratings. Both songs must be ranked by the user, otherwise sql.ErrNoRows
+// is returned.
+func (dao *RankingsDao) RecordPairwiseComparison(ctx context.Context, userID uint64, winnerID uint64, loserID uint64) (err error) {
+	tx, err := dao.DB.BeginTx(ctx, &sql.TxOptions{})
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err != nil {
+			tx.Rollback()
+		} else {
+			err = tx.Commit()
+		}
+	}()
+
+	// lock both rankings so concurrent answers don't read stale ratings
+	rows, err := tx.QueryContext(ctx, `
+		SELECT
+			r.song_id,
+			COALESCE(p.rating, $4::float8 + (r.rank - 3) * $5::float8),
+			COALESCE(p.comparisons, 0)
+		FROM rankings r
+		LEFT JOIN pairwise_ratings p
+			ON p.user_id = r.user_id AND p.song_id = r.song_id
+		WHERE r.user_id = $1 AND r.song_id IN ($2, $3)
+		FOR UPDATE OF r
+	`, userID, winnerID, loserID, rating.InitialRating, rating.RankStep)
+	if err != nil {
+		return err
+	}
+	ratings := make(map[uint64]float64, 2)
+	comparisons := make(map[uint64]int, 2)
+	for rows.Next() {
+		var (
+			songID      uint64
+			songRating  float64
+			songMatches int
+		)
+		if err = rows.Scan(&songID, &songRating, &songMatches); err != nil {
+			rows.Close()
+			return err
+		}
+		ratings[songID] = songRating
+		comparisons[songID] = songMatches
+	}
+	rows.Close()
+	if err = rows.Err(); err != nil {
+		return err
+	}
+	if len(ratings) != 2 {
+		err = sql.ErrNoRows
+		return err
+	}
+
+	newWinner, newLoser := rating.Elo(
+		ratings[winnerID], ratings[loserID],
+		comparisons[winnerID], comparisons[loserID],
+	)
+
+	upsert := `
+		INSERT INTO pairwise_ratings (user_id, song_id, rating, comparisons, updated_at)
+		VALUES ($1, $2, $3, 1, NOW())
+		ON CONFLICT (user_id, song_id)
+		DO UPDATE
+			SET rating      = EXCLUDED.rating,
+				comparisons = pairwise_ratings.comparisons + 1,
+				updated_at  = NOW();
+	`
+	if _, err = tx.ExecContext(ctx, upsert, userID, winnerID, newWinner); err != nil {
+		return err
+	}
+	_, err = tx.ExecContext(ctx, upsert, userID, loserID, newLoser)
+	return err
+}
+
 func (dao *RankingsDao) GetFriendsRankedSongs(userID uint64) ([]model.Rankings, error) {
 	query := `
 		SELECT r.ranking_id, r.song_id, r.user_id, r.rank
@@ -84,54 +293,249 @@ func (dao *RankingsDao) GetFriendsRankedSongs(userID uint64) ([]model.Rankings,
 	return rankings, nil
 }
 
-func (dao *RankingsDao) GetTopWeeklyRankedSongs(ctx context.Context) ([]model.Song, error) {
-	query := `
-		-- get all the rank songs and their average rank and rating 
-		WITH RankedSongs AS (
+// chartScores starts every chart query: it aggregates the average rank and the number
+// of ratings of each song ranked within the window of $1 and $2 (NULL leaves that side
+// open) and narrowed by the genre and artist of $3 and $4, then scores them with scorer
+// given the prior of $5 and $6. Songs match the genre by their song_genres tags or the
+// legacy genre column. rankedBy, when set, is a further condition on the rankings r,
+// e.g. only the ones of the user's friends. updated_at is a TIMESTAMP written in UTC,
+// it's read as UTC explicitly rather than in the session's time zone.
+func chartScores(scorer rating.Scorer, rankedBy string) string {
+	if rankedBy == "" {
+		rankedBy = "TRUE"
+	}
+	return `
+		WITH prior AS (
+			SELECT $5::float8 AS mean, $6::float8 AS weight
+		), aggregates AS (
+			SELECT
+				r.song_id,
+				AVG(r.rank)::float8 AS avg_rank,
+				COUNT(*)            AS ratings_count
+			FROM rankings r
+			JOIN songs s ON s.song_id = r.song_id
+			WHERE
+				($1::timestamptz IS NULL OR r.updated_at AT TIME ZONE 'UTC' >= $1::timestamptz)
+				AND ($2::timestamptz IS NULL OR r.updated_at AT TIME ZONE 'UTC' < $2::timestamptz)
+				AND ($3::text = '' OR lower(s.genre) = lower($3) OR EXISTS (
+					SELECT 1
+					FROM song_genres sg
+					WHERE sg.song_id = s.song_id AND sg.genre = lower($3)
+				))
+				AND ($4::text = '' OR lower(s.artist) = lower($4) OR EXISTS (
+					SELECT 1
+					FROM song_artists sa
+					JOIN artists a ON a.artist_id = sa.artist_id
+					WHERE sa.song_id = s.song_id AND lower(a.name) = lower($4)
+				))
+				AND (` + rankedBy + `)
+			GROUP BY r.song_id
+		), scores AS (
 			SELECT
-				song_id,
-				AVG(rank) AS avg_rank,
-				COUNT(*)  AS ratings_count
-			FROM rankings
-			WHERE 
-				updated_at >= $1 AND updated_at < $2
-			GROUP BY song_id
-			ORDER BY
-				avg_rank DESC,      -- higher average rank is better
-				ratings_count DESC -- Higher rating count breaks ties (more popular)
-			LIMIT 5
+				a.song_id,
+				a.avg_rank,
+				a.ratings_count,
+				(` + scorer.SQL("a.avg_rank", "a.ratings_count", "prior.mean", "prior.weight") + `)::float8 AS score
+			FROM aggregates a
+			CROSS JOIN prior
 		)
+	`
+}
+
+// chart positions break score ties with the number of ratings (more popular first) and
+// then the song id so they are stable
+const chartOrder = `score DESC, ratings_count DESC, song_id`
+
+// GetChartPage scores the songs ranked within [start, end) and returns the page of
+// limit songs at offset in chart order, along with how many songs the chart has. A nil
+// start or end leaves that side of the window open.
+func (dao *RankingsDao) GetChartPage(ctx context.Context, start *time.Time, end *time.Time, filter model.ChartFilter, scorer rating.Scorer, prior rating.Prior, limit int, offset int) ([]model.SongRankingAggregate, int, error) {
+	query := chartScores(scorer, "") + `
+		SELECT
+			total.songs,
+			page.song_id,
+			page.avg_rank,
+			page.ratings_count,
+			page.score
+		FROM (SELECT COUNT(*) AS songs FROM scores) total
+		LEFT JOIN LATERAL (
+			SELECT song_id, avg_rank, ratings_count, score
+			FROM scores
+			ORDER BY ` + chartOrder + `
+			LIMIT $7 OFFSET $8
+		) page ON TRUE
+	`
+	rows, err := dao.DB.QueryContext(ctx, query, start, end, filter.Genre, filter.Artist,
+		prior.Mean, prior.Weight, limit, offset,
+	)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer rows.Close()
+
+	var (
+		total      int
+		aggregates []model.SongRankingAggregate
+	)
+	for rows.Next() {
+		var (
+			songID       *uint64
+			avgRank      *float64
+			ratingsCount *int
+			score        *float64
+		)
+		if err := rows.Scan(&total, &songID, &avgRank, &ratingsCount, &score); err != nil {
+			return nil, 0, err
+		}
+		// the page is empty past the last song
+		if songID == nil {
+			continue
+		}
+		aggregates = append(aggregates, model.SongRankingAggregate{
+			SongID:       *songID,
+			AvgRank:      *avgRank,
+			RatingsCount: *ratingsCount,
+			Score:        *score,
+		})
+	}
+	if err := rows.Err(); err != nil {
+		return nil, 0, err
+	}
+	return aggregates, total, nil
+}
+
+// GetChartPositions returns the position each of the songs had in the chart of
+// [start, end), songs that weren't charted are left out
+func (dao *RankingsDao) GetChartPositions(ctx context.Context, start *time.Time, end *time.Time, filter model.ChartFilter, scorer rating.Scorer, prior rating.Prior, songIDs []uint64) (map[uint64]int, error) {
+	query := chartScores(scorer, "") + `
+		SELECT song_id, position
+		FROM (
+			SELECT song_id, ROW_NUMBER() OVER (ORDER BY ` + chartOrder + `) AS position
+			FROM scores
+		) positions
+		WHERE song_id = ANY($7)
+	`
+	ids := make([]int64, len(songIDs))
+	for i, id := range songIDs {
+		ids[i] = int64(id)
+	}
+	rows, err := dao.DB.QueryContext(ctx, query, start, end, filter.Genre, filter.Artist,
+		prior.Mean, prior.Weight, pq.Array(ids),
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	positions := make(map[uint64]int, len(songIDs))
+	for rows.Next() {
+		var (
+			songID   uint64
+			position int
+		)
+		if err := rows.Scan(&songID, &position); err != nil {
+			return nil, err
+		}
+		positions[songID] = position
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return positions, nil
+}
+
+// GetRankingPrior derives the scoring prior from every ranking: the global average
+// rank and the average number of ratings per song.
+func (dao *RankingsDao) GetRankingPrior(ctx context.Context) (rating.Prior, error) {
+	query := `
+		SELECT
+			COALESCE(AVG(rank)::float8, 3),
+			COALESCE(COUNT(*)::float8 / NULLIF(COUNT(DISTINCT song_id), 0), 1)
+		FROM rankings
+	`
+	var prior rating.Prior
+	if err := dao.DB.QueryRowContext(ctx, query).Scan(&prior.Mean, &prior.Weight); err != nil {
+		return rating.Prior{}, err
+	}
+	return prior, nil
+}
+
+// GetSharedRankings returns the songs ranked by both users with each user's rank
+func (dao *RankingsDao) GetSharedRankings(ctx context.Context, userID uint64, otherUserID uint64) ([]model.SharedRanking, error) {
+	query := `
 		SELECT
 			s.song_id,
 			s.spotify_id,
 			s.title,
 			s.artist,
-			s.album,  
+			s.album,
 			s.release_date,
-			s.genre,  
+			s.genre,
 			s.cover_uri,
-			s.preview_uri
-		FROM songs s
-		JOIN RankedSongs rs ON s.song_id = rs.song_id
-		ORDER BY
-			rs.avg_rank DESC,      
-			rs.ratings_count DESC;
+			s.preview_uri,
+			s.created_at,
+			mine.rank,
+			theirs.rank
+		FROM rankings mine
+		JOIN rankings theirs
+			ON theirs.song_id = mine.song_id AND theirs.user_id = $2
+		JOIN songs s ON s.song_id = mine.song_id
+		WHERE mine.user_id = $1
 	`
-	now := time.Now()
-	// today at midnight
-	todayMid := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location())
-
-	// compute how many days since Monday:
-	// (Weekday()+6)%7 maps Monday→0, Tuesday→1, … Sunday→6
-	offset := (int(now.Weekday()) + 6) % 7
-
-	// this week’s Monday 00:00
-	startThisWeek := todayMid.AddDate(0, 0, -offset)
+	rows, err := dao.DB.QueryContext(ctx, query, userID, otherUserID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
 
-	// last week’s Monday 00:00
-	startLastWeek := startThisWeek.AddDate(0, 0, -7)
+	var shared []model.SharedRanking
+	for rows.Next() {
+		var ranking model.SharedRanking
+		if err := rows.Scan(
+			&ranking.SongID,
+			&ranking.SpotifyID,
+			&ranking.Title,
+			&ranking.Artist,
+			&ranking.Album,
+			&ranking.ReleaseDate,
+			&ranking.Genre,
+			&ranking.CoverURI,
+			&ranking.PreviewURI,
+			&ranking.CreatedAt,
+			&ranking.UserRank,
+			&ranking.OtherRank,
+		); err != nil {
+			return nil, err
+		}
+		shared = append(shared, ranking)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return shared, nil
+}
 
-	rows, err := dao.DB.QueryContext(ctx, query, startLastWeek, startThisWeek)
+func (dao *RankingsDao) GetSongsByIDs(ctx context.Context, songIDs []uint64) ([]model.Song, error) {
+	query := `
+		SELECT
+			song_id,
+			spotify_id,
+			title,
+			artist,
+			album,
+			release_date,
+			genre,
+			cover_uri,
+			preview_uri,
+			created_at
+		FROM songs
+		WHERE song_id = ANY($1)
+	`
+	ids := make([]int64, len(songIDs))
+	for i, id := range songIDs {
+		ids[i] = int64(id)
+	}
+	rows, err := dao.DB.QueryContext(ctx, query, pq.Array(ids))
 	if err != nil {
 		return nil, err
 	}
@@ -150,6 +554,7 @@ func (dao *RankingsDao) GetTopWeeklyRankedSongs(ctx context.Context) ([]model.So
 			&song.Genre,
 			&song.CoverURI,
 			&song.PreviewURI,
+			&song.CreatedAt,
 		); err != nil {
 			return nil, err
 		}
@@ -161,6 +566,39 @@ func (dao *RankingsDao) GetTopWeeklyRankedSongs(ctx context.Context) ([]model.So
 	return songs, nil
 }
 
+// GetRankedSpotifyIDs returns the spotify ids of up to limit songs the user ranked at
+// least minRank, best ranked first and most recently ranked first among ties
+func (dao *RankingsDao) GetRankedSpotifyIDs(ctx context.Context, userID uint64, minRank int, limit int) ([]string, error) {
+	query := `
+		SELECT s.spotify_id
+		FROM rankings r
+		JOIN songs s ON s.song_id = r.song_id
+		WHERE r.user_id = $1
+			AND r.rank >= $2
+			AND s.spotify_id IS NOT NULL
+		ORDER BY r.rank DESC, r.updated_at DESC, r.ranking_id DESC
+		LIMIT $3
+	`
+	rows, err := dao.DB.QueryContext(ctx, query, userID, minRank, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var spotifyIDs []string
+	for rows.Next() {
+		var spotifyID string
+		if err := rows.Scan(&spotifyID); err != nil {
+			return nil, err
+		}
+		spotifyIDs = append(spotifyIDs, spotifyID)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return spotifyIDs, nil
+}
+
 func (dao *RankingsDao) CheckIfSongIsRanked(spotifyId string, userID uint64) (bool, error) {
 	var exists bool
 	err := dao.DB.QueryRow(`
@@ -178,13 +616,56 @@ func (dao *RankingsDao) CheckIfSongIsRanked(spotifyId string, userID uint64) (bo
 	return exists, nil
 }
 
-func (dao *RankingsDao) GetFriendsRankedSongsWithNoUserRank(userID uint64) ([]map[string]interface{}, error) {
+// excludedByFeedback matches the songs s that user $1 gave recommendation feedback on,
+// or that any artist they excluded appears on
+const excludedByFeedback = `EXISTS (
+			SELECT 1
+			FROM recommendation_feedback rf
+			WHERE rf.user_id = $1
+				AND (rf.song_id = s.song_id OR rf.excluded_artist_id IN (
+					SELECT sa.artist_id
+					FROM song_artists sa
+					WHERE sa.song_id = s.song_id
+				))
+		)`
+
+// GetRankedSpotifyIDsAmong returns which of the spotify ids the user ranked, in a
+// single query
+func (dao *RankingsDao) GetRankedSpotifyIDsAmong(ctx context.Context, userID uint64, spotifyIDs []string) (map[string]bool, error) {
+	query := `
+		SELECT s.spotify_id
+		FROM rankings r
+		JOIN songs s ON s.song_id = r.song_id
+		WHERE r.user_id = $1
+			AND s.spotify_id = ANY($2)
+	`
+	rows, err := dao.DB.QueryContext(ctx, query, userID, pq.Array(spotifyIDs))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	ranked := make(map[string]bool)
+	for rows.Next() {
+		var spotifyID string
+		if err := rows.Scan(&spotifyID); err != nil {
+			return nil, err
+		}
+		ranked[spotifyID] = true
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return ranked, nil
+}
+
+// GetFriendsRankedSongsWithNoUserRank pages through the songs the user's friends ranked
+// and the user didn't nor gave feedback on, along with the highest rank a friend gave
+// each. Ordered by song so offsets are stable across calls.
+func (dao *RankingsDao) GetFriendsRankedSongsWithNoUserRank(ctx context.Context, userID uint64, limit int, offset int) ([]model.FriendRankedSong, error) {
 	query := `
 		SELECT DISTINCT ON (s.song_id)
-			r.song_id,
-			r.ranking_id,
-			r.user_id,
-			r.rank,
+			s.song_id,
 			s.spotify_id,
 			s.title,
 			s.artist,
@@ -193,8 +674,10 @@ func (dao *RankingsDao) GetFriendsRankedSongsWithNoUserRank(userID uint64) ([]ma
 			s.genre,
 			s.cover_uri,
 			s.preview_uri,
-			s.created_at
-			FROM friends f
+			s.created_at,
+			r.user_id,
+			r.rank
+		FROM friends f
 		JOIN users u ON
 			(f.user_id = $1 AND u.id = f.friend_id)
 			OR (f.friend_id = $1 AND u.id = f.user_id)
@@ -203,105 +686,378 @@ func (dao *RankingsDao) GetFriendsRankedSongsWithNoUserRank(userID uint64) ([]ma
 		WHERE r.song_id NOT IN (
 			SELECT song_id FROM rankings WHERE user_id = $1
 		)
+			AND NOT ` + excludedByFeedback + `
 		ORDER BY s.song_id, r.rank DESC  -- pick the friend who ranked it highest
-		LIMIT 5;
-		`
-	rows, err := dao.DB.Query(query, userID)
+		LIMIT $2 OFFSET $3
+	`
+	rows, err := dao.DB.QueryContext(ctx, query, userID, limit, offset)
 	if err != nil {
 		return nil, err
 	}
 	defer rows.Close()
 
-	columns, err := rows.Columns()
+	var songs []model.FriendRankedSong
+	for rows.Next() {
+		var song model.FriendRankedSong
+		if err := rows.Scan(
+			&song.SongID,
+			&song.SpotifyID,
+			&song.Title,
+			&song.Artist,
+			&song.Album,
+			&song.ReleaseDate,
+			&song.Genre,
+			&song.CoverURI,
+			&song.PreviewURI,
+			&song.CreatedAt,
+			&song.FriendID,
+			&song.FriendRank,
+		); err != nil {
+			return nil, err
+		}
+		songs = append(songs, song)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return songs, nil
+}
+
+// GetUserRankingForSong returns the user's ranking of the song
+func (dao *RankingsDao) GetUserRankingForSong(ctx context.Context, userID uint64, songID uint64) (*model.Rankings, error) {
+	query := `
+		SELECT ranking_id, song_id, user_id, rank, created_at, updated_at
+		FROM rankings
+		WHERE user_id = $1 AND song_id = $2
+	`
+	var ranking model.Rankings
+	err := dao.DB.QueryRowContext(ctx, query, userID, songID).Scan(
+		&ranking.RankingID,
+		&ranking.SongID,
+		&ranking.UserID,
+		&ranking.Rank,
+		&ranking.CreatedAt,
+		&ranking.UpdatedAt,
+	)
 	if err != nil {
 		return nil, err
 	}
+	return &ranking, nil
+}
 
-	var results []map[string]interface{}
-	for rows.Next() {
-		// Create a slice of interface{}'s to hold each column value
-		values := make([]interface{}, len(columns))
-		valuePtrs := make([]interface{}, len(columns))
+// GetRankingOwner returns the id of the user that owns the ranking
+func (dao *RankingsDao) GetRankingOwner(rankingID uint64) (uint64, error) {
+	var userID uint64
+	err := dao.DB.QueryRow(`
+		SELECT user_id
+		FROM rankings
+		WHERE ranking_id = $1
+	`, rankingID).Scan(&userID)
+	if err != nil {
+		return 0, err
+	}
+	return userID, nil
+}
 
-		for i := range values {
-			valuePtrs[i] = &values[i]
+// RankSong upserts the user's rank for a song, keyed on (user_id, song_id), and records
+// the change in the ranking history, source is the endpoint that made the change.
+// Ranking a song again with the same rank leaves it untouched.
+func (dao *RankingsDao) RankSong(ctx context.Context, songID uint64, userID uint64, rank int, source string, streaks *StreaksDAO) (result *model.RankSongResult, err error) {
+	tx, err := dao.DB.BeginTx(ctx, &sql.TxOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("error ranking song: %v", err)
+	}
+	defer func() {
+		if err != nil {
+			tx.Rollback()
+		} else {
+			err = tx.Commit()
 		}
+	}()
 
-		if err := rows.Scan(valuePtrs...); err != nil {
-			return nil, err
+	result = &model.RankSongResult{}
+	// credits the song towards today's streak unless it already was, re-posting the
+	// same rank included. Streak days are Puerto Rico days, taken from the DB's clock.
+	err = tx.QueryRowContext(ctx, `
+		INSERT INTO streak_credits (user_id, song_id, credited_on)
+		VALUES ($1, $2, (NOW() AT TIME ZONE 'America/Puerto_Rico')::date)
+		ON CONFLICT (user_id, song_id) DO UPDATE
+		SET credited_on = EXCLUDED.credited_on
+		WHERE streak_credits.credited_on < EXCLUDED.credited_on
+		RETURNING TRUE
+	`, userID, songID).Scan(&result.FirstRankToday)
+	if err != nil && err != sql.ErrNoRows {
+		return nil, fmt.Errorf("error ranking song: %v", err)
+	}
+	// counted in this transaction, a credit is never spent without the streak counting it
+	if result.FirstRankToday {
+		if err = streaks.RecordSongRank(ctx, tx, userID); err != nil {
+			return nil, fmt.Errorf("error recording streak: %v", err)
 		}
+	}
 
-		// Create map for this row
-		rowMap := make(map[string]interface{})
-		for i, col := range columns {
-			rowMap[col] = values[i]
-		}
+	// a ranked song leaves the user's ranking queue
+	_, err = tx.ExecContext(ctx, `
+		DELETE FROM ranking_queue
+		WHERE user_id = $1 AND song_id = $2
+	`, userID, songID)
+	if err != nil {
+		return nil, fmt.Errorf("error ranking song: %v", err)
+	}
 
-		results = append(results, rowMap)
+	err = tx.QueryRowContext(ctx, `
+		INSERT INTO rankings (song_id, user_id, rank, created_at, updated_at)
+		VALUES ($1, $2, $3, NOW(), NOW())
+		ON CONFLICT (user_id, song_id) DO NOTHING
+		RETURNING ranking_id
+	`, songID, userID, rank).Scan(&result.RankingID)
+	if err == nil {
+		result.Created = true
+		if err = recordRankingChange(ctx, tx, userID, songID, nil, &rank, source); err != nil {
+			return nil, err
+		}
+		return result, nil
+	}
+	if err != sql.ErrNoRows {
+		return nil, fmt.Errorf("error ranking song: %v", err)
 	}
 
-	if err := rows.Err(); err != nil {
+	// the user already ranked the song, update it instead
+	var oldRank int
+	err = tx.QueryRowContext(ctx, `
+		SELECT ranking_id, rank
+		FROM rankings
+		WHERE user_id = $1 AND song_id = $2
+		FOR UPDATE
+	`, userID, songID).Scan(&result.RankingID, &oldRank)
+	if err != nil {
+		return nil, fmt.Errorf("error ranking song: %v", err)
+	}
+	if oldRank == rank {
+		return result, nil
+	}
+	_, err = tx.ExecContext(ctx, `
+		UPDATE rankings
+		SET rank = $2, updated_at = NOW()
+		WHERE ranking_id = $1
+	`, result.RankingID, rank)
+	if err != nil {
+		return nil, fmt.Errorf("error ranking song: %v", err)
+	}
+	if err = recordRankingChange(ctx, tx, userID, songID, &oldRank, &rank, source); err != nil {
 		return nil, err
 	}
-
-	return results, nil
+	return result, nil
 }
 
-func (dao *RankingsDao) RankSong(songID uint64, userID uint64, rank int) error {
-	query := `
-		INSERT INTO rankings (song_id, user_id, rank, created_at, updated_at)
-		VALUES ($1, $2, $3, NOW(), NOW())
-	`
-	_, err := dao.DB.Exec(query, songID, userID, rank)
+// DeleteRanking removes a ranking, along with the user's pairwise rating of the song, and
+// records the deletion in the ranking history, source is the endpoint that made the change.
+func (dao *RankingsDao) DeleteRanking(ctx context.Context, rankingID uint64, source string) (err error) {
+	tx, err := dao.DB.BeginTx(ctx, &sql.TxOptions{})
 	if err != nil {
-		return fmt.Errorf("error ranking song: %v", err)
+		return fmt.Errorf("error deleting ranking: %v", err)
 	}
-	return nil
-}
+	defer func() {
+		if err != nil {
+			tx.Rollback()
+		} else {
+			err = tx.Commit()
+		}
+	}()
 
-func (dao *RankingsDao) DeleteRanking(rankingID uint64) error {
 	query := `
 		DELETE FROM rankings 
-		WHERE ranking_id = $1;
+		WHERE ranking_id = $1
+		RETURNING user_id, song_id, rank;
 	`
-	result, err := dao.DB.Exec(query, rankingID)
+	var (
+		userID  uint64
+		songID  uint64
+		oldRank int
+	)
+	err = tx.QueryRowContext(ctx, query, rankingID).Scan(&userID, &songID, &oldRank)
 	if err != nil {
+		if err == sql.ErrNoRows {
+			return err
+		}
 		return fmt.Errorf("error deleting ranking: %v", err)
 	}
-	rowsAffected, err := result.RowsAffected()
+
+	// the song's pairwise rating goes with it, ranking it again starts from scratch
+	_, err = tx.ExecContext(ctx, `
+		DELETE FROM pairwise_ratings
+		WHERE user_id = $1
+			AND song_id = $2
+	`, userID, songID)
 	if err != nil {
-		return fmt.Errorf("error checking rows affected (Rankings): %v", err)
-	}
-	if rowsAffected == 0 {
-		return sql.ErrNoRows
+		return fmt.Errorf("error deleting pairwise rating: %v", err)
 	}
-	return nil
+	return recordRankingChange(ctx, tx, userID, songID, &oldRank, nil, source)
 }
 
-func (dao *RankingsDao) UpdateRanking(rankingID uint64, rank int) error {
+// UpdateRanking changes the rank of an existing ranking and records the change in the
+// ranking history, source is the endpoint that made the change.
+func (dao *RankingsDao) UpdateRanking(ctx context.Context, rankingID uint64, rank int, source string) (err error) {
+	tx, err := dao.DB.BeginTx(ctx, &sql.TxOptions{})
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err != nil {
+			tx.Rollback()
+		} else {
+			err = tx.Commit()
+		}
+	}()
+
+	var (
+		userID  uint64
+		songID  uint64
+		oldRank int
+	)
+	err = tx.QueryRowContext(ctx, `
+		SELECT user_id, song_id, rank
+		FROM rankings
+		WHERE ranking_id = $1
+		FOR UPDATE
+	`, rankingID).Scan(&userID, &songID, &oldRank)
+	if err != nil {
+		return err
+	}
+
 	query := `
 		UPDATE rankings
-		SET rank = $2
+		SET rank = $2, updated_at = NOW()
 		WHERE ranking_id = $1;
 	`
-	_, err := dao.DB.Exec(query, rankingID, rank)
-	return err
+	if _, err = tx.ExecContext(ctx, query, rankingID, rank); err != nil {
+		return err
+	}
+	return recordRankingChange(ctx, tx, userID, songID, &oldRank, &rank, source)
 }
 
-func (dao *RankingsDao) StoreSongInDB(spotifyID string, title string, artist *string, album *string,
-	releaseDate *time.Time, genre *string, coverURI *string, previewURI *string) error {
+// recordRankingChange appends an entry to the ranking history within the caller's transaction
+func recordRankingChange(ctx context.Context, tx *sql.Tx, userID uint64, songID uint64, oldRank *int, newRank *int, source string) error {
 	query := `
-		INSERT INTO songs (spotify_id, title, artist, album, release_date,
-			genre, cover_uri, preview_uri, created_at)
-		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, NOW())
+		INSERT INTO ranking_history (user_id, song_id, old_rank, new_rank, source, changed_at)
+		VALUES ($1, $2, $3, $4, $5, NOW())
 	`
-	_, err := dao.DB.Exec(query, spotifyID, title, artist, album, releaseDate, genre, coverURI, previewURI)
+	_, err := tx.ExecContext(ctx, query, userID, songID, oldRank, newRank, source)
 	if err != nil {
-		return fmt.Errorf("error storing song: %v", err)
+		return fmt.Errorf("error recording ranking history: %v", err)
 	}
 	return nil
 }
 
+// GetRankingHistory returns the ranking history of a song for the user, oldest first
+func (dao *RankingsDao) GetRankingHistory(ctx context.Context, userID uint64, songID uint64) ([]model.RankingHistory, error) {
+	query := `
+		SELECT history_id, user_id, song_id, old_rank, new_rank, source, changed_at
+		FROM ranking_history
+		WHERE user_id = $1 AND song_id = $2
+		ORDER BY changed_at, history_id
+	`
+	rows, err := dao.DB.QueryContext(ctx, query, userID, songID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var history []model.RankingHistory
+	for rows.Next() {
+		var entry model.RankingHistory
+		if err := rows.Scan(
+			&entry.HistoryID,
+			&entry.UserID,
+			&entry.SongID,
+			&entry.OldRank,
+			&entry.NewRank,
+			&entry.Source,
+			&entry.ChangedAt,
+		); err != nil {
+			return nil, err
+		}
+		history = append(history, entry)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return history, nil
+}
+
+// GetFriendsRankingChanges returns the latest times the user's friends changed their
+// mind about a song, that is re-ranked it with a different rank.
+func (dao *RankingsDao) GetFriendsRankingChanges(ctx context.Context, userID uint64, limit int) ([]model.RankingChange, error) {
+	query := `
+		SELECT
+			h.history_id,
+			h.user_id,
+			h.song_id,
+			h.old_rank,
+			h.new_rank,
+			h.source,
+			h.changed_at,
+			u.username,
+			s.song_id,
+			s.spotify_id,
+			s.title,
+			s.artist,
+			s.album,
+			s.release_date,
+			s.genre,
+			s.cover_uri,
+			s.preview_uri,
+			s.created_at
+		FROM friends f
+		JOIN users u ON (f.user_id = $1 AND u.id = f.friend_id)
+					OR (f.friend_id = $1 AND u.id = f.user_id)
+		JOIN ranking_history h ON h.user_id = u.id
+		JOIN songs s ON s.song_id = h.song_id
+		WHERE h.old_rank IS NOT NULL
+		  AND h.new_rank IS NOT NULL
+		  AND h.old_rank <> h.new_rank
+		ORDER BY h.changed_at DESC, h.history_id DESC
+		LIMIT $2
+	`
+	rows, err := dao.DB.QueryContext(ctx, query, userID, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var changes []model.RankingChange
+	for rows.Next() {
+		var change model.RankingChange
+		if err := rows.Scan(
+			&change.HistoryID,
+			&change.UserID,
+			&change.SongID,
+			&change.OldRank,
+			&change.NewRank,
+			&change.Source,
+			&change.ChangedAt,
+			&change.Username,
+			&change.Song.SongID,
+			&change.Song.SpotifyID,
+			&change.Song.Title,
+			&change.Song.Artist,
+			&change.Song.Album,
+			&change.Song.ReleaseDate,
+			&change.Song.Genre,
+			&change.Song.CoverURI,
+			&change.Song.PreviewURI,
+			&change.Song.CreatedAt,
+		); err != nil {
+			return nil, err
+		}
+		changes = append(changes, change)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return changes, nil
+}
+
 func (dao *RankingsDao) GetSongBySpotifyID(spotifyID string) (model.Song, error) {
 	var song model.Song
 	const query = `
@@ -0,0 +1,186 @@
+package dao
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/ranktify/ranktify-be/internal/model"
+	"github.com/ranktify/ranktify-be/internal/rating"
+	"github.com/ranktify/ranktify-be/internal/testutil"
+)
+
+const (
+	streakCreditQuery  = `INSERT INTO streak_credits`
+	streakSelectQuery  = `SELECT daily_count, streak_count, last_count_date\s+FROM streaks`
+	queueDeleteQuery   = `DELETE FROM ranking_queue`
+	rankingInsertQuery = `INSERT INTO rankings`
+	rankingSelectQuery = `SELECT ranking_id, rank\s+FROM rankings`
+	rankingUpdateQuery = `UPDATE rankings`
+	historyInsertQuery = `INSERT INTO ranking_history`
+)
+
+func TestRankSong(t *testing.T) {
+	const userID, songID, rankingID = 1, 2, 7
+	nowAST := time.Now().In(puertoRicoLoc)
+	today := time.Date(nowAST.Year(), nowAST.Month(), nowAST.Day(), 0, 0, 0, 0, puertoRicoLoc)
+
+	// the streak credit, nil when the song already counted today
+	credit := func(mock sqlmock.Sqlmock, firstToday bool) {
+		rows := sqlmock.NewRows([]string{"bool"})
+		if firstToday {
+			rows.AddRow(true)
+		}
+		mock.ExpectQuery(streakCreditQuery).WithArgs(userID, songID).WillReturnRows(rows)
+	}
+	streak := func(mock sqlmock.Sqlmock, dailyCount, streakCount int, lastCountDate any) {
+		mock.ExpectQuery(streakSelectQuery).WithArgs(userID).WillReturnRows(
+			sqlmock.NewRows([]string{"daily_count", "streak_count", "last_count_date"}).
+				AddRow(dailyCount, streakCount, lastCountDate),
+		)
+	}
+	dequeue := func(mock sqlmock.Sqlmock) {
+		mock.ExpectExec(queueDeleteQuery).WithArgs(userID, songID).WillReturnResult(sqlmock.NewResult(0, 0))
+	}
+	existing := func(mock sqlmock.Sqlmock, rank int) {
+		mock.ExpectQuery(rankingInsertQuery).WithArgs(songID, userID, 4).WillReturnRows(sqlmock.NewRows([]string{"ranking_id"}))
+		mock.ExpectQuery(rankingSelectQuery).WithArgs(userID, songID).
+			WillReturnRows(sqlmock.NewRows([]string{"ranking_id", "rank"}).AddRow(rankingID, rank))
+	}
+
+	tests := []struct {
+		name        string
+		expect      func(mock sqlmock.Sqlmock)
+		wantErr     bool
+		wantCreated bool
+		wantFirst   bool
+	}{
+		{
+			name: "first ranking starts the streak",
+			expect: func(mock sqlmock.Sqlmock) {
+				credit(mock, true)
+				mock.ExpectQuery(streakSelectQuery).WithArgs(userID).WillReturnRows(
+					sqlmock.NewRows([]string{"daily_count", "streak_count", "last_count_date"}))
+				mock.ExpectExec(`INSERT INTO streaks`).WillReturnResult(sqlmock.NewResult(0, 1))
+				mock.ExpectExec(`UPDATE streaks`).WithArgs(userID, 1, today, sqlmock.AnyArg()).WillReturnResult(sqlmock.NewResult(0, 1))
+				dequeue(mock)
+				mock.ExpectQuery(rankingInsertQuery).WithArgs(songID, userID, 4).
+					WillReturnRows(sqlmock.NewRows([]string{"ranking_id"}).AddRow(rankingID))
+				mock.ExpectExec(historyInsertQuery).WithArgs(userID, songID, nil, 4, "rank").WillReturnResult(sqlmock.NewResult(0, 1))
+				mock.ExpectCommit()
+			},
+			wantCreated: true,
+			wantFirst:   true,
+		},
+		{
+			name: "tenth song of the day extends the streak",
+			expect: func(mock sqlmock.Sqlmock) {
+				credit(mock, true)
+				streak(mock, 9, 2, today)
+				mock.ExpectExec(`UPDATE streaks`).WithArgs(userID, 3, 10, today, sqlmock.AnyArg()).WillReturnResult(sqlmock.NewResult(0, 1))
+				dequeue(mock)
+				existing(mock, 2)
+				mock.ExpectExec(rankingUpdateQuery).WithArgs(rankingID, 4).WillReturnResult(sqlmock.NewResult(0, 1))
+				mock.ExpectExec(historyInsertQuery).WithArgs(userID, songID, 2, 4, "rank").WillReturnResult(sqlmock.NewResult(0, 1))
+				mock.ExpectCommit()
+			},
+			wantFirst: true,
+		},
+		{
+			name: "re-ranking the same day doesn't count again",
+			expect: func(mock sqlmock.Sqlmock) {
+				credit(mock, false)
+				dequeue(mock)
+				existing(mock, 2)
+				mock.ExpectExec(rankingUpdateQuery).WithArgs(rankingID, 4).WillReturnResult(sqlmock.NewResult(0, 1))
+				mock.ExpectExec(historyInsertQuery).WithArgs(userID, songID, 2, 4, "rank").WillReturnResult(sqlmock.NewResult(0, 1))
+				mock.ExpectCommit()
+			},
+		},
+		{
+			name: "posting the same rank again changes nothing",
+			expect: func(mock sqlmock.Sqlmock) {
+				credit(mock, false)
+				dequeue(mock)
+				existing(mock, 4)
+				mock.ExpectCommit()
+			},
+		},
+		{
+			name: "failing to count the streak rolls back the credit",
+			expect: func(mock sqlmock.Sqlmock) {
+				credit(mock, true)
+				mock.ExpectQuery(streakSelectQuery).WithArgs(userID).WillReturnError(errors.New("connection reset"))
+				mock.ExpectRollback()
+			},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db, mock := testutil.MockDB(t)
+			mock.ExpectBegin()
+			tt.expect(mock)
+
+			result, err := NewRankingsDAO(db).RankSong(context.Background(), songID, userID, 4, "rank", NewStreaksDAO(db))
+			if tt.wantErr {
+				if err == nil {
+					t.Fatal("RankSong should fail")
+				}
+			} else {
+				if err != nil {
+					t.Fatalf("RankSong: %v", err)
+				}
+				if result.RankingID != rankingID || result.Created != tt.wantCreated || result.FirstRankToday != tt.wantFirst {
+					t.Errorf("RankSong = %+v, want created %v and first today %v", result, tt.wantCreated, tt.wantFirst)
+				}
+			}
+			if err := mock.ExpectationsWereMet(); err != nil {
+				t.Error(err)
+			}
+		})
+	}
+}
+
+func TestDeleteRankingDeletesPairwiseRating(t *testing.T) {
+	db, mock := testutil.MockDB(t)
+	mock.ExpectBegin()
+	mock.ExpectQuery(`DELETE FROM rankings`).WithArgs(7).
+		WillReturnRows(sqlmock.NewRows([]string{"user_id", "song_id", "rank"}).AddRow(1, 2, 4))
+	mock.ExpectExec(`DELETE FROM pairwise_ratings`).WithArgs(1, 2).WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec(historyInsertQuery).WithArgs(1, 2, 4, nil, "delete").WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+
+	if err := NewRankingsDAO(db).DeleteRanking(context.Background(), 7, "delete"); err != nil {
+		t.Fatalf("DeleteRanking: %v", err)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestGetChartPageReadsUpdatedAtAsUTC(t *testing.T) {
+	db, mock := testutil.MockDB(t)
+	scorer, _ := rating.ScorerByName("mean")
+	prior := rating.Prior{Mean: 3, Weight: 1}
+	start := time.Date(2026, time.October, 1, 0, 0, 0, 0, puertoRicoLoc)
+	end := start.AddDate(0, 1, 0)
+
+	mock.ExpectQuery(`r.updated_at AT TIME ZONE 'UTC' >= \$1::timestamptz\)\s+AND \(\$2::timestamptz IS NULL OR r.updated_at AT TIME ZONE 'UTC' < \$2::timestamptz`).
+		WithArgs(&start, &end, "", "", prior.Mean, prior.Weight, 10, 0).
+		WillReturnRows(sqlmock.NewRows([]string{"songs", "song_id", "avg_rank", "ratings_count", "score"}).
+			AddRow(1, 5, 4.0, 2, 4.0))
+
+	aggregates, total, err := NewRankingsDAO(db).GetChartPage(context.Background(), &start, &end, model.ChartFilter{}, scorer, prior, 10, 0)
+	if err != nil {
+		t.Fatalf("GetChartPage: %v", err)
+	}
+	if total != 1 || len(aggregates) != 1 || aggregates[0].SongID != 5 {
+		t.Errorf("GetChartPage = %+v of %d, want song 5 of 1", aggregates, total)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Error(err)
+	}
+}
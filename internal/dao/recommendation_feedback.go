@@ -0,0 +1,161 @@
+package dao
+
+import (
+	"context"
+	"database/sql"
+
+	"github.com/lib/pq"
+	"github.com/ranktify/ranktify-be/internal/model"
+)
+
+type RecommendationFeedbackDAO struct {
+	DB *sql.DB
+}
+
+func NewRecommendationFeedbackDAO(db *sql.DB) *RecommendationFeedbackDAO {
+	return &RecommendationFeedbackDAO{DB: db}
+}
+
+// SaveFeedback records the user's feedback on the song, replacing the previous one. A
+// non nil excludedArtistID keeps that artist's songs from being recommended too.
+func (dao *RecommendationFeedbackDAO) SaveFeedback(ctx context.Context, userID uint64, songID uint64, feedback string, excludedArtistID *uint64) error {
+	query := `
+		INSERT INTO recommendation_feedback (user_id, song_id, feedback, excluded_artist_id, created_at, updated_at)
+		VALUES ($1, $2, $3, $4, NOW(), NOW())
+		ON CONFLICT (user_id, song_id) DO UPDATE
+		SET feedback = EXCLUDED.feedback,
+			excluded_artist_id = EXCLUDED.excluded_artist_id,
+			updated_at = EXCLUDED.updated_at
+	`
+	_, err := dao.DB.ExecContext(ctx, query, userID, songID, feedback, excludedArtistID)
+	return err
+}
+
+// DeleteFeedback forgets the user's feedback on the song, sql.ErrNoRows when there was none
+func (dao *RecommendationFeedbackDAO) DeleteFeedback(ctx context.Context, userID uint64, songID uint64) error {
+	query := `
+		DELETE FROM recommendation_feedback
+		WHERE user_id = $1
+			AND song_id = $2
+	`
+	result, err := dao.DB.ExecContext(ctx, query, userID, songID)
+	if err != nil {
+		return err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rowsAffected == 0 {
+		return sql.ErrNoRows
+	}
+
+	return nil
+}
+
+// GetFeedback returns every feedback of the user along with its song, latest first
+func (dao *RecommendationFeedbackDAO) GetFeedback(ctx context.Context, userID uint64) ([]model.RecommendationFeedback, error) {
+	query := `
+		SELECT
+			s.song_id,
+			s.spotify_id,
+			s.title,
+			s.artist,
+			s.album,
+			s.release_date,
+			s.genre,
+			s.cover_uri,
+			s.preview_uri,
+			s.created_at,
+			f.feedback,
+			f.excluded_artist_id IS NOT NULL,
+			f.excluded_artist_id,
+			f.updated_at
+		FROM recommendation_feedback f
+		JOIN songs s ON s.song_id = f.song_id
+		WHERE f.user_id = $1
+		ORDER BY f.updated_at DESC, s.song_id
+	`
+	rows, err := dao.DB.QueryContext(ctx, query, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	feedback := []model.RecommendationFeedback{}
+	for rows.Next() {
+		var songFeedback model.RecommendationFeedback
+		if err := rows.Scan(
+			&songFeedback.SongID,
+			&songFeedback.SpotifyID,
+			&songFeedback.Title,
+			&songFeedback.Artist,
+			&songFeedback.Album,
+			&songFeedback.ReleaseDate,
+			&songFeedback.Genre,
+			&songFeedback.CoverURI,
+			&songFeedback.PreviewURI,
+			&songFeedback.CreatedAt,
+			&songFeedback.Feedback,
+			&songFeedback.ExcludeArtist,
+			&songFeedback.ExcludedArtistID,
+			&songFeedback.UpdatedAt,
+		); err != nil {
+			return nil, err
+		}
+		feedback = append(feedback, songFeedback)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return feedback, nil
+}
+
+// GetExclusions returns which of the songs, by spotify id, the user gave feedback on or
+// has an excluded artist on, and the spotify ids of the artists they excluded for the
+// songs that aren't stored yet
+func (dao *RecommendationFeedbackDAO) GetExclusions(ctx context.Context, userID uint64, spotifyIDs []string) (map[string]bool, map[string]bool, error) {
+	songs, err := dao.querySpotifyIDs(ctx, `
+		SELECT s.spotify_id
+		FROM songs s
+		WHERE s.spotify_id = ANY($2)
+			AND `+excludedByFeedback+`
+	`, userID, pq.Array(spotifyIDs))
+	if err != nil {
+		return nil, nil, err
+	}
+	artists, err := dao.querySpotifyIDs(ctx, `
+		SELECT a.spotify_id
+		FROM recommendation_feedback f
+		JOIN artists a ON a.artist_id = f.excluded_artist_id
+		WHERE f.user_id = $1
+			AND a.spotify_id IS NOT NULL
+	`, userID)
+	if err != nil {
+		return nil, nil, err
+	}
+	return songs, artists, nil
+}
+
+func (dao *RecommendationFeedbackDAO) querySpotifyIDs(ctx context.Context, query string, args ...any) (map[string]bool, error) {
+	rows, err := dao.DB.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	spotifyIDs := make(map[string]bool)
+	for rows.Next() {
+		var spotifyID string
+		if err := rows.Scan(&spotifyID); err != nil {
+			return nil, err
+		}
+		spotifyIDs[spotifyID] = true
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return spotifyIDs, nil
+}
@@ -0,0 +1,64 @@
+package dao
+
+import (
+	"context"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/lib/pq"
+	"github.com/ranktify/ranktify-be/internal/testutil"
+)
+
+func TestSaveFeedbackStoresExcludedArtistID(t *testing.T) {
+	artistID := uint64(30)
+	tests := []struct {
+		name             string
+		excludedArtistID *uint64
+		wantArg          any
+	}{
+		{name: "artist excluded", excludedArtistID: &artistID, wantArg: int64(artistID)},
+		{name: "song only", wantArg: nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db, mock := testutil.MockDB(t)
+			mock.ExpectExec(`INSERT INTO recommendation_feedback \(user_id, song_id, feedback, excluded_artist_id`).
+				WithArgs(1, 2, "not_interested", tt.wantArg).
+				WillReturnResult(sqlmock.NewResult(0, 1))
+
+			if err := NewRecommendationFeedbackDAO(db).SaveFeedback(context.Background(), 1, 2, "not_interested", tt.excludedArtistID); err != nil {
+				t.Fatalf("SaveFeedback: %v", err)
+			}
+			if err := mock.ExpectationsWereMet(); err != nil {
+				t.Error(err)
+			}
+		})
+	}
+}
+
+func TestGetExclusions(t *testing.T) {
+	db, mock := testutil.MockDB(t)
+	spotifyIDs := []string{"song-a", "song-b", "song-c"}
+
+	// stored songs match the excluded artists through song_artists, not the artist text
+	mock.ExpectQuery(`FROM songs s\s+WHERE s.spotify_id = ANY\(\$2\)(?s).*rf.excluded_artist_id IN \(\s+SELECT sa.artist_id\s+FROM song_artists sa`).
+		WithArgs(1, pq.Array(spotifyIDs)).
+		WillReturnRows(sqlmock.NewRows([]string{"spotify_id"}).AddRow("song-a"))
+	mock.ExpectQuery(`JOIN artists a ON a.artist_id = f.excluded_artist_id`).
+		WithArgs(1).
+		WillReturnRows(sqlmock.NewRows([]string{"spotify_id"}).AddRow("artist-x"))
+
+	songs, artists, err := NewRecommendationFeedbackDAO(db).GetExclusions(context.Background(), 1, spotifyIDs)
+	if err != nil {
+		t.Fatalf("GetExclusions: %v", err)
+	}
+	if len(songs) != 1 || !songs["song-a"] {
+		t.Errorf("songs = %v, want song-a", songs)
+	}
+	if len(artists) != 1 || !artists["artist-x"] {
+		t.Errorf("artists = %v, want artist-x", artists)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Error(err)
+	}
+}
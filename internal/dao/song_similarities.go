@@ -0,0 +1,189 @@
+package dao
+
+import (
+	"context"
+	"database/sql"
+
+	"github.com/ranktify/ranktify-be/internal/model"
+)
+
+type SongSimilaritiesDAO struct {
+	DB *sql.DB
+}
+
+func NewSongSimilaritiesDAO(db *sql.DB) *SongSimilaritiesDAO {
+	return &SongSimilaritiesDAO{DB: db}
+}
+
+// RebuildSimilarities recomputes the song to song similarities from the rankings and
+// replaces the previous ones, returning how many were stored. Songs are similar when
+// the users that ranked both deviate from their average rank the same way (adjusted
+// cosine), pairs need minCoRankers users in common, similarities are shrunk by
+// co_rankers / (co_rankers + shrinkage) and each song keeps its neighbors most similar.
+// Readers see the previous similarities until it commits.
+func (dao *SongSimilaritiesDAO) RebuildSimilarities(ctx context.Context, minCoRankers int, shrinkage float64, neighbors int) (stored int64, err error) {
+	tx, err := dao.DB.BeginTx(ctx, &sql.TxOptions{})
+	if err != nil {
+		return 0, err
+	}
+	defer func() {
+		if err != nil {
+			tx.Rollback()
+		} else {
+			err = tx.Commit()
+		}
+	}()
+
+	if _, err = tx.ExecContext(ctx, `DELETE FROM song_similarities`); err != nil {
+		return 0, err
+	}
+
+	// every pair of songs a user ranked is visited, fine while users rank hundreds of
+	// songs rather than tens of thousands
+	query := `
+		WITH centered AS (
+			SELECT user_id, song_id, rank - AVG(rank) OVER (PARTITION BY user_id) AS deviation
+			FROM rankings
+			WHERE rank IS NOT NULL
+		),
+		pairs AS (
+			SELECT
+				a.song_id,
+				b.song_id AS similar_song_id,
+				SUM(a.deviation * b.deviation) AS dot,
+				SQRT(SUM(a.deviation * a.deviation)) * SQRT(SUM(b.deviation * b.deviation)) AS norms,
+				COUNT(*) AS co_rankers
+			FROM centered a
+			JOIN centered b ON b.user_id = a.user_id AND b.song_id <> a.song_id
+			GROUP BY a.song_id, b.song_id
+			HAVING COUNT(*) >= $1
+		),
+		scored AS (
+			SELECT
+				song_id,
+				similar_song_id,
+				dot / norms * co_rankers / (co_rankers + $2::float8) AS similarity,
+				co_rankers
+			FROM pairs
+			WHERE norms > 0
+		),
+		ranked AS (
+			SELECT *, ROW_NUMBER() OVER (PARTITION BY song_id ORDER BY similarity DESC, similar_song_id) AS position
+			FROM scored
+			WHERE similarity > 0
+		)
+		INSERT INTO song_similarities (song_id, similar_song_id, similarity, co_rankers, computed_at)
+		SELECT song_id, similar_song_id, similarity, co_rankers, NOW()
+		FROM ranked
+		WHERE position <= $3
+	`
+	result, err := tx.ExecContext(ctx, query, minCoRankers, shrinkage, neighbors)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+// GetSimilarUnrankedSongs pages through the songs similar to the ones the user ranked
+// at least minRank that the user hasn't ranked nor gave feedback on, most similar
+// first. Each song's score adds up its similarity to every such ranked song, weighted
+// by the rank, minus its similarity to the songs the user skipped or wasn't interested
+// in. BecauseOf is the ranked song that contributed the most.
+func (dao *SongSimilaritiesDAO) GetSimilarUnrankedSongs(ctx context.Context, userID uint64, minRank int, limit int, offset int) ([]model.SimilarSong, error) {
+	query := `
+		WITH candidates AS (
+			SELECT
+				ss.similar_song_id AS song_id,
+				ss.song_id AS because_song_id,
+				ss.similarity * r.rank / 5.0 AS weight
+			FROM rankings r
+			JOIN song_similarities ss ON ss.song_id = r.song_id
+			WHERE r.user_id = $1
+				AND r.rank >= $2
+			UNION ALL
+			-- songs like the ones the user turned down count against, "already know" says
+			-- nothing about their taste
+			SELECT
+				ss.similar_song_id,
+				NULL,
+				-ss.similarity * CASE f.feedback WHEN 'not_interested' THEN 1.0 ELSE 0.5 END
+			FROM recommendation_feedback f
+			JOIN song_similarities ss ON ss.song_id = f.song_id
+			WHERE f.user_id = $1
+				AND f.feedback IN ('skip', 'not_interested')
+		),
+		scored AS (
+			SELECT
+				c.song_id,
+				SUM(c.weight) AS score,
+				(ARRAY_AGG(c.because_song_id ORDER BY c.weight DESC, c.because_song_id)
+					FILTER (WHERE c.because_song_id IS NOT NULL))[1] AS because_song_id
+			FROM candidates c
+			JOIN songs s ON s.song_id = c.song_id
+			WHERE c.song_id NOT IN (
+				SELECT song_id FROM rankings WHERE user_id = $1
+			)
+				AND NOT ` + excludedByFeedback + `
+			GROUP BY c.song_id
+			HAVING COUNT(c.because_song_id) > 0
+				AND SUM(c.weight) > 0
+		)
+		SELECT
+			s.song_id,
+			s.spotify_id,
+			s.title,
+			s.artist,
+			s.album,
+			s.release_date,
+			s.genre,
+			s.cover_uri,
+			s.preview_uri,
+			s.created_at,
+			sc.score,
+			b.song_id,
+			b.spotify_id,
+			b.title,
+			b.artist,
+			b.cover_uri
+		FROM scored sc
+		JOIN songs s ON s.song_id = sc.song_id
+		JOIN songs b ON b.song_id = sc.because_song_id
+		ORDER BY sc.score DESC, s.song_id
+		LIMIT $3 OFFSET $4
+	`
+	rows, err := dao.DB.QueryContext(ctx, query, userID, minRank, limit, offset)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var songs []model.SimilarSong
+	for rows.Next() {
+		var song model.SimilarSong
+		if err := rows.Scan(
+			&song.SongID,
+			&song.SpotifyID,
+			&song.Title,
+			&song.Artist,
+			&song.Album,
+			&song.ReleaseDate,
+			&song.Genre,
+			&song.CoverURI,
+			&song.PreviewURI,
+			&song.CreatedAt,
+			&song.Score,
+			&song.BecauseOf.SongID,
+			&song.BecauseOf.SpotifyID,
+			&song.BecauseOf.Title,
+			&song.BecauseOf.Artist,
+			&song.BecauseOf.CoverURI,
+		); err != nil {
+			return nil, err
+		}
+		songs = append(songs, song)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return songs, nil
+}
@@ -0,0 +1,82 @@
+package dao
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/ranktify/ranktify-be/internal/testutil"
+)
+
+const rebuildSimilaritiesQuery = `JOIN centered b ON b.user_id = a.user_id AND b.song_id <> a.song_id(?s).*HAVING COUNT\(\*\) >= \$1` +
+	`.*co_rankers / \(co_rankers \+ \$2::float8\).*INSERT INTO song_similarities.*WHERE position <= \$3`
+
+func TestRebuildSimilarities(t *testing.T) {
+	tests := []struct {
+		name       string
+		insertErr  error
+		wantStored int64
+		wantErr    bool
+	}{
+		{name: "replaces the similarities", wantStored: 12},
+		{name: "failure keeps the previous similarities", insertErr: errors.New("canceling statement due to statement timeout"), wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db, mock := testutil.MockDB(t)
+			mock.ExpectBegin()
+			mock.ExpectExec(`DELETE FROM song_similarities`).WillReturnResult(sqlmock.NewResult(0, 30))
+			insert := mock.ExpectExec(rebuildSimilaritiesQuery).WithArgs(2, 5.0, 50)
+			if tt.insertErr != nil {
+				insert.WillReturnError(tt.insertErr)
+				mock.ExpectRollback()
+			} else {
+				insert.WillReturnResult(sqlmock.NewResult(0, tt.wantStored))
+				mock.ExpectCommit()
+			}
+
+			stored, err := NewSongSimilaritiesDAO(db).RebuildSimilarities(context.Background(), 2, 5, 50)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("RebuildSimilarities error = %v, want error %v", err, tt.wantErr)
+			}
+			if !tt.wantErr && stored != tt.wantStored {
+				t.Errorf("stored %d, want %d", stored, tt.wantStored)
+			}
+			if err := mock.ExpectationsWereMet(); err != nil {
+				t.Error(err)
+			}
+		})
+	}
+}
+
+func TestGetSimilarUnrankedSongs(t *testing.T) {
+	db, mock := testutil.MockDB(t)
+	// the ranked songs weigh in by rank, the turned down ones count against and songs
+	// the user ranked or gave feedback on are left out
+	mock.ExpectQuery(`ss.similarity \* r.rank / 5.0 AS weight(?s).*-ss.similarity \* CASE f.feedback WHEN 'not_interested' THEN 1.0 ELSE 0.5 END`+
+		`.*AND NOT EXISTS \(\s+SELECT 1\s+FROM recommendation_feedback rf.*ORDER BY sc.score DESC, s.song_id\s+LIMIT \$3 OFFSET \$4`).
+		WithArgs(1, 4, 10, 20).
+		WillReturnRows(sqlmock.NewRows([]string{
+			"song_id", "spotify_id", "title", "artist", "album", "release_date", "genre", "cover_uri", "preview_uri", "created_at",
+			"score", "song_id", "spotify_id", "title", "artist", "cover_uri",
+		}).AddRow(
+			5, "similar", "Similar song", "Artist", "Album", nil, "reggaeton", nil, nil, time.Now(),
+			1.6, 3, "ranked", "Ranked song", "Artist", nil,
+		))
+
+	songs, err := NewSongSimilaritiesDAO(db).GetSimilarUnrankedSongs(context.Background(), 1, 4, 10, 20)
+	if err != nil {
+		t.Fatalf("GetSimilarUnrankedSongs: %v", err)
+	}
+	if len(songs) != 1 {
+		t.Fatalf("got %d songs, want 1", len(songs))
+	}
+	if song := songs[0]; song.SongID != 5 || song.Score != 1.6 || song.BecauseOf.SongID != 3 || song.BecauseOf.Title != "Ranked song" {
+		t.Errorf("song = %+v, want song 5 because of song 3", song)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Error(err)
+	}
+}
@@ -0,0 +1,508 @@
+package dao
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/lib/pq"
+	"github.com/ranktify/ranktify-be/internal/model"
+)
+
+type SongsDAO struct {
+	DB *sql.DB
+}
+
+func NewSongsDAO(db *sql.DB) *SongsDAO {
+	return &SongsDAO{DB: db}
+}
+
+// StoreSong upserts a song by its spotify id along with its album and every one of its
+// artists, returning the song id.
+func (dao *SongsDAO) StoreSong(ctx context.Context, song model.Song) (uint64, error) {
+	songIDs, err := dao.StoreSongs(ctx, []model.Song{song})
+	if err != nil {
+		return 0, err
+	}
+	return songIDs[0], nil
+}
+
+// StoreSongs upserts the songs like StoreSong does in a handful of statements, returning
+// the song id of each of them in order. A song listed twice gets the same id.
+func (dao *SongsDAO) StoreSongs(ctx context.Context, songs []model.Song) (songIDs []uint64, err error) {
+	if len(songs) == 0 {
+		return []uint64{}, nil
+	}
+	tx, err := dao.DB.BeginTx(ctx, &sql.TxOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("error storing songs: %v", err)
+	}
+	defer func() {
+		if err != nil {
+			tx.Rollback()
+		} else {
+			err = tx.Commit()
+		}
+	}()
+
+	// an upsert can't touch the same row twice, so every entity goes in once
+	var (
+		albumIDs, albumNames, albumDates, albumCovers          []*string
+		songSpotifyIDs, titles, artistNames, albums, albumRefs []*string
+		releaseDates, genres, coverURIs, previewURIs           []*string
+		artistSpotifyIDs, artistEntityNames                    []*string
+		linkSongs, linkArtists                                 []*string
+		linkPositions                                          []int64
+		tagSongs, tagGenres                                    []*string
+	)
+	seenAlbums := make(map[string]bool)
+	seenSongs := make(map[string]bool)
+	seenArtists := make(map[string]bool)
+	seenLinks := make(map[[2]string]bool)
+	for _, song := range songs {
+		var albumRef *string
+		if album := song.AlbumDetails; album != nil && album.SpotifyID != "" {
+			albumRef = &album.SpotifyID
+			if !seenAlbums[album.SpotifyID] {
+				seenAlbums[album.SpotifyID] = true
+				albumIDs = append(albumIDs, &album.SpotifyID)
+				albumNames = append(albumNames, &album.Name)
+				albumDates = append(albumDates, formatDate(album.ReleaseDate))
+				albumCovers = append(albumCovers, album.CoverURI)
+			}
+		}
+
+		if seenSongs[song.SpotifyID] {
+			continue
+		}
+		seenSongs[song.SpotifyID] = true
+		spotifyID, title := song.SpotifyID, song.Title
+		songSpotifyIDs = append(songSpotifyIDs, &spotifyID)
+		titles = append(titles, &title)
+		artistNames = append(artistNames, song.Artist)
+		albums = append(albums, song.Album)
+		albumRefs = append(albumRefs, albumRef)
+		releaseDates = append(releaseDates, formatDate(song.ReleaseDate))
+		genres = append(genres, song.Genre)
+		coverURIs = append(coverURIs, song.CoverURI)
+		previewURIs = append(previewURIs, song.PreviewURI)
+
+		for position, artist := range song.Artists {
+			if artist.SpotifyID == "" {
+				continue
+			}
+			artistID, name := artist.SpotifyID, artist.Name
+			if !seenArtists[artistID] {
+				seenArtists[artistID] = true
+				artistSpotifyIDs = append(artistSpotifyIDs, &artistID)
+				artistEntityNames = append(artistEntityNames, &name)
+			}
+			if link := [2]string{spotifyID, artistID}; !seenLinks[link] {
+				seenLinks[link] = true
+				linkSongs = append(linkSongs, &spotifyID)
+				linkArtists = append(linkArtists, &artistID)
+				linkPositions = append(linkPositions, int64(position))
+			}
+		}
+		for _, genre := range song.Genres {
+			tagSongs = append(tagSongs, &spotifyID)
+			tagGenres = append(tagGenres, &genre)
+		}
+	}
+
+	if len(albumIDs) > 0 {
+		_, err = tx.ExecContext(ctx, `
+			INSERT INTO albums (spotify_id, name, release_date, cover_uri, created_at)
+			SELECT spotify_id, name, release_date, cover_uri, NOW()
+			FROM unnest($1::text[], $2::text[], $3::date[], $4::text[])
+				AS a(spotify_id, name, release_date, cover_uri)
+			ON CONFLICT (spotify_id)
+			DO UPDATE
+				SET name         = EXCLUDED.name,
+					release_date = COALESCE(EXCLUDED.release_date, albums.release_date),
+					cover_uri    = COALESCE(EXCLUDED.cover_uri, albums.cover_uri)
+		`, pq.Array(albumIDs), pq.Array(albumNames), pq.Array(albumDates), pq.Array(albumCovers))
+		if err != nil {
+			return nil, fmt.Errorf("error storing albums: %v", err)
+		}
+	}
+
+	rows, err := tx.QueryContext(ctx, `
+		INSERT INTO songs (spotify_id, title, artist, album, album_id, release_date,
+			genre, cover_uri, preview_uri, created_at)
+		SELECT s.spotify_id, s.title, s.artist, s.album, al.album_id, s.release_date,
+			s.genre, s.cover_uri, s.preview_uri, NOW()
+		FROM unnest($1::text[], $2::text[], $3::text[], $4::text[], $5::text[], $6::date[],
+				$7::text[], $8::text[], $9::text[])
+			AS s(spotify_id, title, artist, album, album_spotify_id, release_date, genre, cover_uri, preview_uri)
+		LEFT JOIN albums al ON al.spotify_id = s.album_spotify_id
+		ON CONFLICT (spotify_id)
+		DO UPDATE
+			SET title        = EXCLUDED.title,
+				artist       = EXCLUDED.artist,
+				album        = EXCLUDED.album,
+				album_id     = COALESCE(EXCLUDED.album_id, songs.album_id),
+				release_date = COALESCE(EXCLUDED.release_date, songs.release_date),
+				genre        = COALESCE(songs.genre, EXCLUDED.genre),
+				cover_uri    = COALESCE(EXCLUDED.cover_uri, songs.cover_uri),
+				preview_uri  = COALESCE(EXCLUDED.preview_uri, songs.preview_uri)
+		RETURNING spotify_id, song_id
+	`, pq.Array(songSpotifyIDs), pq.Array(titles), pq.Array(artistNames), pq.Array(albums),
+		pq.Array(albumRefs), pq.Array(releaseDates), pq.Array(genres), pq.Array(coverURIs),
+		pq.Array(previewURIs))
+	if err != nil {
+		return nil, fmt.Errorf("error storing songs: %v", err)
+	}
+	idsBySpotifyID := make(map[string]uint64, len(songSpotifyIDs))
+	for rows.Next() {
+		var (
+			spotifyID string
+			songID    uint64
+		)
+		if err = rows.Scan(&spotifyID, &songID); err != nil {
+			rows.Close()
+			return nil, fmt.Errorf("error storing songs: %v", err)
+		}
+		idsBySpotifyID[spotifyID] = songID
+	}
+	rows.Close()
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("error storing songs: %v", err)
+	}
+
+	if len(artistSpotifyIDs) > 0 {
+		_, err = tx.ExecContext(ctx, `
+			INSERT INTO artists (spotify_id, name, created_at)
+			SELECT spotify_id, name, NOW()
+			FROM unnest($1::text[], $2::text[]) AS a(spotify_id, name)
+			ON CONFLICT (spotify_id)
+			DO UPDATE SET name = EXCLUDED.name
+		`, pq.Array(artistSpotifyIDs), pq.Array(artistEntityNames))
+		if err != nil {
+			return nil, fmt.Errorf("error storing artists: %v", err)
+		}
+		_, err = tx.ExecContext(ctx, `
+			INSERT INTO song_artists (song_id, artist_id, position)
+			SELECT s.song_id, a.artist_id, l.position
+			FROM unnest($1::text[], $2::text[], $3::int[]) AS l(song_spotify_id, artist_spotify_id, position)
+			JOIN songs s ON s.spotify_id = l.song_spotify_id
+			JOIN artists a ON a.spotify_id = l.artist_spotify_id
+			ON CONFLICT (song_id, artist_id)
+			DO UPDATE SET position = EXCLUDED.position
+		`, pq.Array(linkSongs), pq.Array(linkArtists), pq.Array(linkPositions))
+		if err != nil {
+			return nil, fmt.Errorf("error storing song artists: %v", err)
+		}
+	}
+
+	if len(tagSongs) > 0 {
+		_, err = tx.ExecContext(ctx, `
+			INSERT INTO song_genres (song_id, genre, source)
+			SELECT s.song_id, t.genre, 'search'
+			FROM unnest($1::text[], $2::text[]) AS t(song_spotify_id, genre)
+			JOIN songs s ON s.spotify_id = t.song_spotify_id
+			ON CONFLICT (song_id, genre) DO NOTHING
+		`, pq.Array(tagSongs), pq.Array(tagGenres))
+		if err != nil {
+			return nil, fmt.Errorf("error storing song genres: %v", err)
+		}
+	}
+
+	songIDs = make([]uint64, len(songs))
+	for i, song := range songs {
+		songIDs[i] = idsBySpotifyID[song.SpotifyID]
+	}
+	return songIDs, nil
+}
+
+// formatDate formats a date for a date[] parameter, nil stays NULL
+func formatDate(date *time.Time) *string {
+	if date == nil {
+		return nil
+	}
+	formatted := date.Format(time.DateOnly)
+	return &formatted
+}
+
+// GetSongsToEnrichGenres returns up to limit songs whose artists' genres haven't been
+// resolved yet, oldest first, with the spotify id of each of their artists
+func (dao *SongsDAO) GetSongsToEnrichGenres(ctx context.Context, limit int) ([]model.Song, error) {
+	query := `
+		WITH pending AS (
+			SELECT song_id, spotify_id, title, created_at
+			FROM songs
+			WHERE genres_enriched_at IS NULL
+			  AND EXISTS (SELECT 1 FROM song_artists sa WHERE sa.song_id = songs.song_id)
+			ORDER BY created_at, song_id
+			LIMIT $1
+		)
+		SELECT p.song_id, p.spotify_id, p.title, a.artist_id, a.spotify_id, a.name
+		FROM pending p
+		JOIN song_artists sa ON sa.song_id = p.song_id
+		JOIN artists a ON a.artist_id = sa.artist_id
+		ORDER BY p.created_at, p.song_id, sa.position
+	`
+	rows, err := dao.DB.QueryContext(ctx, query, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var songs []model.Song
+	for rows.Next() {
+		var (
+			song   model.Song
+			artist model.Artist
+		)
+		if err := rows.Scan(
+			&song.SongID,
+			&song.SpotifyID,
+			&song.Title,
+			&artist.ArtistID,
+			&artist.SpotifyID,
+			&artist.Name,
+		); err != nil {
+			return nil, err
+		}
+		// rows of the same song are contiguous
+		if len(songs) == 0 || songs[len(songs)-1].SongID != song.SongID {
+			songs = append(songs, song)
+		}
+		last := &songs[len(songs)-1]
+		last.Artists = append(last.Artists, artist)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return songs, nil
+}
+
+// SaveArtistGenres tags the song with its artists' genres and marks it as enriched,
+// even when no genres were found so the job doesn't pick it up again
+func (dao *SongsDAO) SaveArtistGenres(ctx context.Context, songID uint64, genres []string) (err error) {
+	tx, err := dao.DB.BeginTx(ctx, &sql.TxOptions{})
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err != nil {
+			tx.Rollback()
+		} else {
+			err = tx.Commit()
+		}
+	}()
+
+	for _, genre := range genres {
+		_, err = tx.ExecContext(ctx, `
+			INSERT INTO song_genres (song_id, genre, source)
+			VALUES ($1, $2, 'artist')
+			ON CONFLICT (song_id, genre) DO NOTHING
+		`, songID, genre)
+		if err != nil {
+			return err
+		}
+	}
+
+	var firstGenre *string
+	if len(genres) > 0 {
+		firstGenre = &genres[0]
+	}
+	_, err = tx.ExecContext(ctx, `
+		UPDATE songs
+		SET genre              = COALESCE(genre, $2),
+			genres_enriched_at = NOW()
+		WHERE song_id = $1
+	`, songID, firstGenre)
+	return err
+}
+
+// GetSongGenres returns every genre of the song, the ones found through genre search first
+func (dao *SongsDAO) GetSongGenres(ctx context.Context, songID uint64) ([]string, error) {
+	query := `
+		SELECT genre
+		FROM song_genres
+		WHERE song_id = $1
+		ORDER BY source DESC, genre
+	`
+	rows, err := dao.DB.QueryContext(ctx, query, songID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var genres []string
+	for rows.Next() {
+		var genre string
+		if err := rows.Scan(&genre); err != nil {
+			return nil, err
+		}
+		genres = append(genres, genre)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return genres, nil
+}
+
+// GetSongArtists returns every artist of the song, main artist first
+func (dao *SongsDAO) GetSongArtists(ctx context.Context, songID uint64) ([]model.Artist, error) {
+	query := `
+		SELECT a.artist_id, a.spotify_id, a.name
+		FROM song_artists sa
+		JOIN artists a ON a.artist_id = sa.artist_id
+		WHERE sa.song_id = $1
+		ORDER BY sa.position
+	`
+	rows, err := dao.DB.QueryContext(ctx, query, songID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var artists []model.Artist
+	for rows.Next() {
+		var artist model.Artist
+		if err := rows.Scan(&artist.ArtistID, &artist.SpotifyID, &artist.Name); err != nil {
+			return nil, err
+		}
+		artists = append(artists, artist)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return artists, nil
+}
+
+// GetSongAlbum returns the album of the song, nil when the song has no album stored
+func (dao *SongsDAO) GetSongAlbum(ctx context.Context, songID uint64) (*model.Album, error) {
+	query := `
+		SELECT a.album_id, a.spotify_id, a.name, a.release_date, a.cover_uri
+		FROM songs s
+		JOIN albums a ON a.album_id = s.album_id
+		WHERE s.song_id = $1
+	`
+	var album model.Album
+	err := dao.DB.QueryRowContext(ctx, query, songID).Scan(
+		&album.AlbumID,
+		&album.SpotifyID,
+		&album.Name,
+		&album.ReleaseDate,
+		&album.CoverURI,
+	)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &album, nil
+}
+
+func (dao *SongsDAO) GetSongByID(ctx context.Context, songID uint64) (*model.Song, error) {
+	query := `
+		SELECT
+			song_id,
+			spotify_id,
+			title,
+			artist,
+			album,
+			release_date,
+			genre,
+			cover_uri,
+			preview_uri,
+			created_at
+		FROM songs
+		WHERE song_id = $1
+	`
+	var song model.Song
+	err := dao.DB.QueryRowContext(ctx, query, songID).Scan(
+		&song.SongID,
+		&song.SpotifyID,
+		&song.Title,
+		&song.Artist,
+		&song.Album,
+		&song.ReleaseDate,
+		&song.Genre,
+		&song.CoverURI,
+		&song.PreviewURI,
+		&song.CreatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+	return &song, nil
+}
+
+// GetSongRankingStats returns the rank histogram, average rank and number of
+// ratings of a song across every user
+func (dao *SongsDAO) GetSongRankingStats(ctx context.Context, songID uint64) (*model.SongRankingStats, error) {
+	query := `
+		SELECT rank, COUNT(*)
+		FROM rankings
+		WHERE song_id = $1
+		GROUP BY rank
+	`
+	rows, err := dao.DB.QueryContext(ctx, query, songID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	stats := &model.SongRankingStats{
+		Histogram: map[int]int{1: 0, 2: 0, 3: 0, 4: 0, 5: 0},
+	}
+	var total int
+	for rows.Next() {
+		var rank, count int
+		if err := rows.Scan(&rank, &count); err != nil {
+			return nil, err
+		}
+		stats.Histogram[rank] = count
+		stats.RatingsCount += count
+		total += rank * count
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	if stats.RatingsCount > 0 {
+		avg := float64(total) / float64(stats.RatingsCount)
+		stats.AvgRank = &avg
+	}
+	return stats, nil
+}
+
+// GetFriendsRankingsForSong returns how each of the user's friends ranked the song
+func (dao *SongsDAO) GetFriendsRankingsForSong(ctx context.Context, userID uint64, songID uint64) ([]model.FriendRanking, error) {
+	query := `
+		SELECT u.id, u.username, r.rank, r.updated_at
+		FROM friends f
+		JOIN users u ON (f.user_id = $1 AND u.id = f.friend_id)
+					OR (f.friend_id = $1 AND u.id = f.user_id)
+		JOIN rankings r ON r.user_id = u.id AND r.song_id = $2
+		ORDER BY r.rank DESC, u.username
+	`
+	rows, err := dao.DB.QueryContext(ctx, query, userID, songID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	friendsRankings := []model.FriendRanking{}
+	for rows.Next() {
+		var ranking model.FriendRanking
+		if err := rows.Scan(
+			&ranking.UserID,
+			&ranking.Username,
+			&ranking.Rank,
+			&ranking.UpdatedAt,
+		); err != nil {
+			return nil, err
+		}
+		friendsRankings = append(friendsRankings, ranking)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return friendsRankings, nil
+}
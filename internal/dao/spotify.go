@@ -1,56 +1,83 @@
 package dao
 
 import (
+	"context"
 	"database/sql"
 
 	"github.com/ranktify/ranktify-be/internal/model"
+	"github.com/ranktify/ranktify-be/internal/secrets"
 )
 
+// SpotifyDAO stores the Spotify refresh tokens encrypted with the Keyring
 type SpotifyDAO struct {
-	DB *sql.DB
+	DB      *sql.DB
+	Keyring *secrets.Keyring
 }
 
 func NewSpotifyDAO(db *sql.DB) *SpotifyDAO {
-	return &SpotifyDAO{DB: db}
+	return &SpotifyDAO{DB: db, Keyring: secrets.DefaultKeyring()}
 }
 
+// Saves the user's refresh token, replacing the one stored from a previous authorization
 func (dao *SpotifyDAO) SaveRefreshToken(rt model.SpotifyRefreshToken) error {
 	query := `
-		INSERT INTO spotify_refresh_tokens (user_id, refresh_token, created_at)
-		VALUES ($1, $2, NOW())
+		INSERT INTO spotify_refresh_tokens (user_id, refresh_token, key_id, created_at)
+		VALUES ($1, $2, $3, NOW())
+		ON CONFLICT (user_id) DO UPDATE
+		SET refresh_token = EXCLUDED.refresh_token,
+			key_id = EXCLUDED.key_id,
+			created_at = NOW()
 	`
-	_, err := dao.DB.Exec(query, rt.UserID, rt.Token)
+	ciphertext, keyID, err := dao.Keyring.Encrypt(rt.Token)
+	if err != nil {
+		return err
+	}
+	_, err = dao.DB.Exec(query, rt.UserID, ciphertext, keyID)
 	return err
 }
 
 func (dao *SpotifyDAO) GetRefreshToken(userID uint64) (string, error) {
 	var token string
+	var keyID sql.NullString
 	query := `
-		SELECT refresh_token 
+		SELECT refresh_token, key_id
 		FROM spotify_refresh_tokens
 		WHERE user_id = $1
 		LIMIT 1
 	`
 
-	err := dao.DB.QueryRow(query, userID).Scan(&token)
+	err := dao.DB.QueryRow(query, userID).Scan(&token, &keyID)
 	if err != nil {
 		return "", err
 	}
-	return token, nil
+	return openToken(dao.Keyring, token, keyID)
 }
 
 func (dao *SpotifyDAO) UpdateRefreshToken(userID uint64, newRefreshToken string) error {
 	query := `
 		UPDATE spotify_refresh_tokens
-		SET refresh_token = $1
-		WHERE user_id = $2
+		SET refresh_token = $1, key_id = $2
+		WHERE user_id = $3
 	`
 
-	err := dao.DB.QueryRow(query, newRefreshToken, userID).Scan()
+	ciphertext, keyID, err := dao.Keyring.Encrypt(newRefreshToken)
+	if err != nil {
+		return err
+	}
+	result, err := dao.DB.Exec(query, ciphertext, keyID, userID)
+	if err != nil {
+		return err
+	}
+
+	rowsAffected, err := result.RowsAffected()
 	if err != nil {
 		return err
 	}
 
+	if rowsAffected == 0 {
+		return sql.ErrNoRows
+	}
+
 	return nil
 }
 
@@ -60,10 +87,24 @@ func (dao *SpotifyDAO) DeleteRefreshToken(userID uint64) error {
 		WHERE user_id = $1
 	`
 
-	err := dao.DB.QueryRow(query, userID).Scan()
+	result, err := dao.DB.Exec(query, userID)
+	if err != nil {
+		return err
+	}
+
+	rowsAffected, err := result.RowsAffected()
 	if err != nil {
 		return err
 	}
 
+	if rowsAffected == 0 {
+		return sql.ErrNoRows
+	}
+
 	return nil
 }
+
+// Re-encrypts the stored refresh tokens under the current key, returns how many changed
+func (dao *SpotifyDAO) ReencryptRefreshTokens(ctx context.Context) (int, error) {
+	return reencryptTokens(ctx, dao.DB, dao.Keyring, "spotify_refresh_tokens")
+}
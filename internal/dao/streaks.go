@@ -43,23 +43,9 @@ func (dao *StreaksDAO) GetStreaksByUserID(userID uint64) (int, error) {
 	return streakCount, nil
 }
 
-func (dao *StreaksDAO) RecordSongRank(ctx context.Context, userID uint64) error {
-	// Start a transaction
-	tx, err := dao.DB.BeginTx(ctx, &sql.TxOptions{})
-	if err != nil {
-		return err
-	}
-	defer func() {
-		if p := recover(); p != nil {
-			tx.Rollback()
-			log.Println(p)
-		} else if err != nil {
-			tx.Rollback()
-		} else {
-			err = tx.Commit()
-		}
-	}()
-
+// RecordSongRank counts a ranked song towards the user's daily streak, in the
+// transaction that ranked it so the song's streak credit and the count stay together
+func (dao *StreaksDAO) RecordSongRank(ctx context.Context, tx *sql.Tx, userID uint64) (err error) {
 	nowAST := time.Now().In(puertoRicoLoc)
 	y, m, d := nowAST.Date()
 	today := time.Date(y, m, d, 0, 0, 0, 0, puertoRicoLoc)
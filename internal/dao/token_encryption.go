@@ -0,0 +1,86 @@
+package dao
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+
+	"github.com/ranktify/ranktify-be/internal/secrets"
+)
+
+// Decrypts a stored refresh token, rows written before tokens were encrypted have no
+// key id and hold the plaintext
+func openToken(keyring *secrets.Keyring, stored string, keyID sql.NullString) (string, error) {
+	if !keyID.Valid {
+		return stored, nil
+	}
+	return keyring.Decrypt(stored, keyID.String)
+}
+
+type storedToken struct {
+	id    uint64
+	token string
+	keyID sql.NullString
+}
+
+// Moves every refresh token of the table that isn't under the keyring's current key to
+// it, plaintext rows get encrypted. Returns how many rows were rewritten.
+func reencryptTokens(ctx context.Context, db *sql.DB, keyring *secrets.Keyring, table string) (count int, err error) {
+	tx, err := db.BeginTx(ctx, &sql.TxOptions{})
+	if err != nil {
+		return 0, err
+	}
+	defer func() {
+		if err != nil {
+			tx.Rollback()
+		} else {
+			err = tx.Commit()
+		}
+	}()
+
+	// table is one of ours, never user input
+	rows, err := tx.QueryContext(ctx, fmt.Sprintf(`
+		SELECT id, refresh_token, key_id
+		FROM %s
+		WHERE key_id IS DISTINCT FROM $1
+		FOR UPDATE
+	`, table), keyring.CurrentKeyID())
+	if err != nil {
+		return 0, err
+	}
+	var tokens []storedToken
+	for rows.Next() {
+		var t storedToken
+		if err = rows.Scan(&t.id, &t.token, &t.keyID); err != nil {
+			rows.Close()
+			return 0, err
+		}
+		tokens = append(tokens, t)
+	}
+	rows.Close()
+	if err = rows.Err(); err != nil {
+		return 0, err
+	}
+
+	for _, t := range tokens {
+		var ciphertext, keyID string
+		if t.keyID.Valid {
+			ciphertext, keyID, err = keyring.Rewrap(t.token, t.keyID.String)
+		} else {
+			ciphertext, keyID, err = keyring.Encrypt(t.token)
+		}
+		if err != nil {
+			return 0, fmt.Errorf("%s row %d: %w", table, t.id, err)
+		}
+
+		_, err = tx.ExecContext(ctx, fmt.Sprintf(`
+			UPDATE %s
+			SET refresh_token = $1, key_id = $2
+			WHERE id = $3
+		`, table), ciphertext, keyID, t.id)
+		if err != nil {
+			return 0, err
+		}
+	}
+	return len(tokens), nil
+}
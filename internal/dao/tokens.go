@@ -1,29 +1,38 @@
 package dao
 
 import (
+	"context"
 	"database/sql"
 
 	"github.com/ranktify/ranktify-be/internal/model"
+	"github.com/ranktify/ranktify-be/internal/secrets"
 )
 
+// TokensDAO stores the JWT refresh tokens encrypted with the Keyring
 type TokensDAO struct {
-	DB *sql.DB
+	DB      *sql.DB
+	Keyring *secrets.Keyring
 }
 
 func NewTokensDAO(db *sql.DB) *TokensDAO {
-	return &TokensDAO{DB: db}
+	return &TokensDAO{DB: db, Keyring: secrets.DefaultKeyring()}
 }
 
 func (dao *TokensDAO) SaveJWTRefreshToken(jwtTokenStruct *model.JWTRefreshToken) error {
 	query := `
 		INSERT INTO 
-			public.jwt_refresh_tokens (user_id, jti, refresh_token, expires_at, created_at)
-		VALUES ($1, $2, $3, $4, NOW())
+			public.jwt_refresh_tokens (user_id, jti, refresh_token, key_id, expires_at, created_at)
+		VALUES ($1, $2, $3, $4, $5, NOW())
 	`
-	_, err := dao.DB.Exec(query,
+	ciphertext, keyID, err := dao.Keyring.Encrypt(jwtTokenStruct.RefreshToken)
+	if err != nil {
+		return err
+	}
+	_, err = dao.DB.Exec(query,
 		jwtTokenStruct.UserID,
 		jwtTokenStruct.JTI,
-		jwtTokenStruct.RefreshToken,
+		ciphertext,
+		keyID,
 		jwtTokenStruct.ExpiresAt,
 	)
 	return err
@@ -32,7 +41,7 @@ func (dao *TokensDAO) SaveJWTRefreshToken(jwtTokenStruct *model.JWTRefreshToken)
 func (dao *TokensDAO) GetJWTRefreshTokenByJTI(jti string) (*model.JWTRefreshToken, error) {
 	query := `
 		SELECT
-			user_id, jti, refresh_token, expires_at, created_at
+			user_id, jti, refresh_token, key_id, expires_at, created_at
 		FROM
 			public.jwt_refresh_tokens
 		WHERE
@@ -40,16 +49,22 @@ func (dao *TokensDAO) GetJWTRefreshTokenByJTI(jti string) (*model.JWTRefreshToke
 	`
 	row := dao.DB.QueryRow(query, jti)
 	var jwtTokenStruct model.JWTRefreshToken
+	var keyID sql.NullString
 	err := row.Scan(
 		&jwtTokenStruct.UserID,
 		&jwtTokenStruct.JTI,
 		&jwtTokenStruct.RefreshToken,
+		&keyID,
 		&jwtTokenStruct.ExpiresAt,
 		&jwtTokenStruct.CreatedAt,
 	)
 	if err != nil {
 		return nil, err
 	}
+	jwtTokenStruct.RefreshToken, err = openToken(dao.Keyring, jwtTokenStruct.RefreshToken, keyID)
+	if err != nil {
+		return nil, err
+	}
 	return &jwtTokenStruct, nil
 }
 
@@ -58,23 +73,28 @@ func (dao *TokensDAO) UpdateJWTRefreshTokenByJTI(oldJti string, rt *model.JWTRef
 		UPDATE
 			public.jwt_refresh_tokens
 		SET
-			user_id = $1, jti = $2, refresh_token = $3, expires_at = $4, created_at = NOW()
+			user_id = $1, jti = $2, refresh_token = $3, key_id = $4, expires_at = $5, created_at = NOW()
 		WHERE
-			jti = $5
+			jti = $6
 		RETURNING
-			user_id, jti, refresh_token, expires_at, created_at
+			user_id, jti, expires_at, created_at
 	`
 
-	err := dao.DB.QueryRow(query,
+	ciphertext, keyID, err := dao.Keyring.Encrypt(rt.RefreshToken)
+	if err != nil {
+		return err
+	}
+	// the stored refresh_token is ciphertext, rt keeps the plaintext
+	err = dao.DB.QueryRow(query,
 		rt.UserID,
 		rt.JTI,
-		rt.RefreshToken,
+		ciphertext,
+		keyID,
 		rt.ExpiresAt,
 		oldJti,
 	).Scan(
 		&rt.UserID,
 		&rt.JTI,
-		&rt.RefreshToken,
 		&rt.ExpiresAt,
 		&rt.CreatedAt,
 	)
@@ -92,3 +112,8 @@ func (dao *TokensDAO) DeleteJWTRefreshTokenByJTI(jti string) error {
 	_, err := dao.DB.Exec(query, jti)
 	return err
 }
+
+// Re-encrypts the stored refresh tokens under the current key, returns how many changed
+func (dao *TokensDAO) ReencryptRefreshTokens(ctx context.Context) (int, error) {
+	return reencryptTokens(ctx, dao.DB, dao.Keyring, "jwt_refresh_tokens")
+}
@@ -1,13 +1,23 @@
 package dao
 
 import (
+	"context"
 	"database/sql"
+	"errors"
 
+	"github.com/lib/pq"
 	"github.com/ranktify/ranktify-be/internal/model"
 
 	"fmt"
 )
 
+var (
+	// ErrSpotifyAccountTaken is returned when linking a Spotify account another user has
+	ErrSpotifyAccountTaken = errors.New("spotify account linked to another user")
+	ErrUsernameTaken       = errors.New("username already taken")
+	ErrEmailTaken          = errors.New("email already taken")
+)
+
 type UserDAO struct {
 	DB *sql.DB
 }
@@ -54,9 +64,69 @@ func (dao *UserDAO) CreateUser(user *model.User) error {
 	return nil
 }
 
+// Returns the user the Spotify account is linked to, nil when there is none
+func (dao *UserDAO) GetUserBySpotifyID(ctx context.Context, spotifyID string) (*model.User, error) {
+	query := `
+		SELECT id, username, password, first_name, last_name, email, role,
+			spotify_id, spotify_display_name, spotify_profile_uri, spotify_profile_picture_uri,
+			market, locale, created_at
+		FROM public.users
+		WHERE spotify_id = $1
+	`
+	var user model.User
+	err := dao.DB.QueryRowContext(ctx, query, spotifyID).Scan(
+		&user.Id, &user.Username, &user.Password, &user.FirstName,
+		&user.LastName, &user.Email, &user.Role,
+		&user.SpotifyID, &user.SpotifyDisplayName, &user.SpotifyProfileURI, &user.SpotifyProfilePictureURI,
+		&user.Market, &user.Locale, &user.CreatedAt,
+	)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &user, nil
+}
+
+// Creates a user signed up through Spotify, the unique columns that clash are reported
+// as ErrUsernameTaken, ErrEmailTaken or ErrSpotifyAccountTaken
+func (dao *UserDAO) CreateSpotifyUser(ctx context.Context, user *model.User) error {
+	query := `
+		INSERT INTO public.users (username, password, first_name, last_name, email, role,
+			spotify_id, spotify_display_name, spotify_profile_uri, spotify_profile_picture_uri,
+			market, created_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, NOW())
+		RETURNING id, created_at
+	`
+	err := dao.DB.QueryRowContext(ctx, query, user.Username, user.Password, user.FirstName,
+		user.LastName, user.Email, user.Role,
+		user.SpotifyID, user.SpotifyDisplayName, user.SpotifyProfileURI, user.SpotifyProfilePictureURI,
+		user.Market,
+	).Scan(&user.Id, &user.CreatedAt)
+	if err != nil {
+		var pqErr *pq.Error
+		if errors.As(err, &pqErr) && pqErr.Code.Name() == "unique_violation" {
+			switch pqErr.Constraint {
+			case "users_username_key":
+				return ErrUsernameTaken
+			case "users_email_key":
+				return ErrEmailTaken
+			case "users_spotify_id_key":
+				return ErrSpotifyAccountTaken
+			}
+		}
+		return err
+	}
+
+	return nil
+}
+
 func (dao *UserDAO) GetUserByID(id uint64) (*model.User, error) {
 	query := `
-		SELECT id, username, password, first_name, last_name, email
+		SELECT id, username, password, first_name, last_name, email,
+			spotify_id, spotify_display_name, spotify_profile_uri, spotify_profile_picture_uri,
+			market, locale
 		FROM public.users
 		WHERE id = $1
 	`
@@ -64,6 +134,8 @@ func (dao *UserDAO) GetUserByID(id uint64) (*model.User, error) {
 	err := dao.DB.QueryRow(query, id).Scan(
 		&user.Id, &user.Username, &user.Password, &user.FirstName,
 		&user.LastName, &user.Email,
+		&user.SpotifyID, &user.SpotifyDisplayName, &user.SpotifyProfileURI, &user.SpotifyProfilePictureURI,
+		&user.Market, &user.Locale,
 	)
 	if err != nil {
 		return nil, err
@@ -188,3 +260,114 @@ func (dao *UserDAO) SearchForUsername(userID uint64, username string) ([]model.U
 
 	return users, nil
 }
+
+// Links the Spotify account to the user, refreshing the profile when it's already
+// linked. The account's country becomes the user's market unless they picked one.
+// Fails with ErrSpotifyAccountTaken when another user has it.
+func (dao *UserDAO) LinkSpotifyAccount(ctx context.Context, userID uint64, account model.SpotifyAccount) error {
+	query := `
+		UPDATE public.users
+		SET spotify_id = $2, spotify_display_name = $3,
+			spotify_profile_uri = $4, spotify_profile_picture_uri = $5,
+			market = COALESCE(market, $6)
+		WHERE id = $1
+	`
+	result, err := dao.DB.ExecContext(ctx, query, userID, account.SpotifyID, account.DisplayName,
+		account.ProfileURI, account.ProfilePictureURI, account.Country,
+	)
+	if err != nil {
+		var pqErr *pq.Error
+		if errors.As(err, &pqErr) && pqErr.Code.Name() == "unique_violation" {
+			return ErrSpotifyAccountTaken
+		}
+		return err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rowsAffected == 0 {
+		return sql.ErrNoRows
+	}
+
+	return nil
+}
+
+func (dao *UserDAO) UnlinkSpotifyAccount(ctx context.Context, userID uint64) error {
+	query := `
+		UPDATE public.users
+		SET spotify_id = NULL, spotify_display_name = NULL,
+			spotify_profile_uri = NULL, spotify_profile_picture_uri = NULL
+		WHERE id = $1
+	`
+	result, err := dao.DB.ExecContext(ctx, query, userID)
+	if err != nil {
+		return err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rowsAffected == 0 {
+		return sql.ErrNoRows
+	}
+
+	return nil
+}
+
+// Returns the user's role, nil for regular users and sql.ErrNoRows when the user
+// doesn't exist
+func (dao *UserDAO) GetUserRole(ctx context.Context, userID uint64) (*string, error) {
+	query := `
+		SELECT role
+		FROM public.users
+		WHERE id = $1
+	`
+	var role *string
+	if err := dao.DB.QueryRowContext(ctx, query, userID).Scan(&role); err != nil {
+		return nil, err
+	}
+	return role, nil
+}
+
+// Returns the user's market and locale, sql.ErrNoRows when the user doesn't exist
+func (dao *UserDAO) GetUserPreferences(ctx context.Context, userID uint64) (*model.UserPreferences, error) {
+	query := `
+		SELECT market, locale
+		FROM public.users
+		WHERE id = $1
+	`
+	var preferences model.UserPreferences
+	err := dao.DB.QueryRowContext(ctx, query, userID).Scan(&preferences.Market, &preferences.Locale)
+	if err != nil {
+		return nil, err
+	}
+	return &preferences, nil
+}
+
+func (dao *UserDAO) UpdateUserPreferences(ctx context.Context, userID uint64, preferences model.UserPreferences) error {
+	query := `
+		UPDATE public.users
+		SET market = $2, locale = $3
+		WHERE id = $1
+	`
+	result, err := dao.DB.ExecContext(ctx, query, userID, preferences.Market, preferences.Locale)
+	if err != nil {
+		return err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rowsAffected == 0 {
+		return sql.ErrNoRows
+	}
+
+	return nil
+}
@@ -0,0 +1,43 @@
+package handler
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/ranktify/ranktify-be/internal/service"
+)
+
+type ArtistsHandler struct {
+	Service *service.ArtistsService
+}
+
+func NewArtistsHandler(service *service.ArtistsService) *ArtistsHandler {
+	return &ArtistsHandler{Service: service}
+}
+
+func (h *ArtistsHandler) GetArtist(c *gin.Context) {
+	artistID, err := strconv.ParseUint(c.Param("artist_id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid artist ID"})
+		return
+	}
+	statusCode, content := h.Service.GetArtist(c.Request.Context(), artistID)
+	c.JSON(statusCode, content)
+}
+
+func (h *ArtistsHandler) GetTopArtistSongsAmongFriends(c *gin.Context) {
+	rawUserID, ok := c.Get("userId")
+	if !ok {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid user ID"})
+		return
+	}
+	artistID, err := strconv.ParseUint(c.Param("artist_id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid artist ID"})
+		return
+	}
+	userID := rawUserID.(uint64)
+	statusCode, content := h.Service.GetTopArtistSongsAmongFriends(c.Request.Context(), userID, artistID)
+	c.JSON(statusCode, content)
+}
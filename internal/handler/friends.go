@@ -8,22 +8,34 @@ import (
 
 	"github.com/gin-gonic/gin"
 	"github.com/ranktify/ranktify-be/internal/dao"
+	"github.com/ranktify/ranktify-be/internal/rating"
+	"github.com/ranktify/ranktify-be/internal/service"
 )
 
 type FriendHandler struct {
-	DAO *dao.FriendsDAO
+	DAO     *dao.FriendsDAO
+	Service *service.FriendsService
 }
 
-func NewFriendHandler(dao *dao.FriendsDAO) *FriendHandler {
-	return &FriendHandler{DAO: dao}
+func NewFriendHandler(dao *dao.FriendsDAO, service *service.FriendsService) *FriendHandler {
+	return &FriendHandler{DAO: dao, Service: service}
 }
 
 func (h *FriendHandler) GetFriends(c *gin.Context) {
+	rawCallerID, ok := c.Get("userId")
+	if !ok {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid user ID"})
+		return
+	}
 	userID, err := strconv.ParseUint(c.Param("user_id"), 10, 64)
 	if err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid user ID"})
 		return
 	}
+	if statusCode, content, ok := service.AuthorizeSelf(rawCallerID.(uint64), userID); !ok {
+		c.JSON(statusCode, content)
+		return
+	}
 	friends, err := h.DAO.GetFriends(userID)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve friends"})
@@ -71,6 +83,11 @@ func (h *FriendHandler) GetFriendRequestsSent(c *gin.Context) {
 }
 
 func (h *FriendHandler) DeleteFriendByID(c *gin.Context) {
+	rawCallerID, ok := c.Get("userId")
+	if !ok {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid user ID"})
+		return
+	}
 	userID, err := strconv.ParseUint(c.Param("user_id"), 10, 64)
 	if err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid user ID"})
@@ -81,6 +98,11 @@ func (h *FriendHandler) DeleteFriendByID(c *gin.Context) {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid friend ID"})
 		return
 	}
+	// either side of the friendship can end it
+	if statusCode, content, ok := service.AuthorizeSelf(rawCallerID.(uint64), userID, friendID); !ok {
+		c.JSON(statusCode, content)
+		return
+	}
 	err = h.DAO.DeleteFriendByID(userID, friendID)
 	if err != nil {
 		if err == sql.ErrNoRows {
@@ -94,6 +116,11 @@ func (h *FriendHandler) DeleteFriendByID(c *gin.Context) {
 }
 
 func (h *FriendHandler) SendFriendRequest(c *gin.Context) {
+	rawCallerID, ok := c.Get("userId")
+	if !ok {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid user ID"})
+		return
+	}
 	userID, err := strconv.ParseUint(c.Param("user_id"), 10, 64)
 	if err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid user ID"})
@@ -104,6 +131,10 @@ func (h *FriendHandler) SendFriendRequest(c *gin.Context) {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid friend ID"})
 		return
 	}
+	if statusCode, content, ok := service.AuthorizeSelf(rawCallerID.(uint64), userID); !ok {
+		c.JSON(statusCode, content)
+		return
+	}
 	err = h.DAO.SendFriendRequest(userID, friendID)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to send friend request"})
@@ -118,23 +149,8 @@ func (h *FriendHandler) AcceptFriendRequest(c *gin.Context) {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request ID"})
 		return
 	}
-	friendRequest, err := h.DAO.GetFriendRequestsByRequestID(requestID)
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get the friend request"})
-		return
-	}
-	err = h.DAO.AcceptFriendRequest(friendRequest.SenderID, friendRequest.ReceiverID)
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to accept the friend request"})
-		return
-	}
-	err = h.DAO.DeleteFriendRequest(requestID)
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete the friend request"})
-		return
-	}
-	c.JSON(http.StatusOK, gin.H{"message": "Friend request accepted"})
-
+	statusCode, content := h.Service.AcceptFriendRequest(c.GetUint64("userId"), requestID)
+	c.JSON(statusCode, content)
 }
 
 func (h *FriendHandler) DeclineFriendRequest(c *gin.Context) {
@@ -143,13 +159,8 @@ func (h *FriendHandler) DeclineFriendRequest(c *gin.Context) {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request ID"})
 		return
 	}
-	err = h.DAO.DeleteFriendRequest(requestID)
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to decline the friend request"})
-		return
-	}
-	c.JSON(http.StatusOK, gin.H{"message": "Friend request declined "})
-
+	statusCode, content := h.Service.DeclineFriendRequest(c.GetUint64("userId"), requestID)
+	c.JSON(statusCode, content)
 }
 
 func (h *FriendHandler) DeleteFriendRequest(c *gin.Context) {
@@ -158,16 +169,8 @@ func (h *FriendHandler) DeleteFriendRequest(c *gin.Context) {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request ID"})
 		return
 	}
-	err = h.DAO.DeleteFriendRequest(requestID)
-	if err != nil {
-		if err == sql.ErrNoRows {
-			c.JSON(http.StatusNotFound, gin.H{"error": "Friend request not found or already canceled"})
-		} else {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to cancel friend request"})
-		}
-		return
-	}
-	c.JSON(http.StatusOK, gin.H{"message": "Friend request canceled successfully"})
+	statusCode, content := h.Service.CancelFriendRequest(c.GetUint64("userId"), requestID)
+	c.JSON(statusCode, content)
 }
 
 func (h *FriendHandler) GetTop5TracksAmongFriends(c *gin.Context) {
@@ -178,7 +181,13 @@ func (h *FriendHandler) GetTop5TracksAmongFriends(c *gin.Context) {
 	}
 	userID := userIDAny.(uint64)
 
-	topTracks, err := h.DAO.GetTopNTracksAmongFriends(c.Request.Context(), userID, 5)
+	scorer, ok := rating.ScorerByName(c.Query("scoring"))
+	if !ok {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid scoring, expected mean, bayesian or wilson"})
+		return
+	}
+
+	topTracks, err := h.Service.GetTopTracksAmongFriends(c.Request.Context(), userID, scorer)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve top tracks among friends"})
 		return
@@ -0,0 +1,78 @@
+package handler
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/ranktify/ranktify-be/internal/model"
+	"github.com/ranktify/ranktify-be/internal/service"
+)
+
+type GenresHandler struct {
+	Service *service.GenresService
+}
+
+func NewGenresHandler(service *service.GenresService) *GenresHandler {
+	return &GenresHandler{Service: service}
+}
+
+// Lists the enabled genres, the optional market and locale query parameters narrow
+// them to a market and name them in the locale
+func (h *GenresHandler) GetGenres(c *gin.Context) {
+	statusCode, content := h.Service.ListGenres(c.Request.Context(), c.Query("market"), c.Query("locale"))
+	c.JSON(statusCode, content)
+}
+
+// Lists every genre of the catalog, disabled ones included
+func (h *GenresHandler) GetAllGenres(c *gin.Context) {
+	statusCode, content := h.Service.ListAllGenres(c.Request.Context())
+	c.JSON(statusCode, content)
+}
+
+func (h *GenresHandler) CreateGenre(c *gin.Context) {
+	// new genres are enabled unless the body says otherwise
+	genre := model.Genre{Enabled: true}
+	if err := c.ShouldBindJSON(&genre); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid input data"})
+		return
+	}
+
+	statusCode, content := h.Service.CreateGenre(c.Request.Context(), genre)
+	c.JSON(statusCode, content)
+}
+
+func (h *GenresHandler) UpdateGenre(c *gin.Context) {
+	genreID, err := strconv.ParseUint(c.Param("genre_id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid genre ID"})
+		return
+	}
+	var genre model.Genre
+	if err := c.ShouldBindJSON(&genre); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid input data"})
+		return
+	}
+
+	statusCode, content := h.Service.UpdateGenre(c.Request.Context(), genreID, genre)
+	c.JSON(statusCode, content)
+}
+
+func (h *GenresHandler) EnableGenre(c *gin.Context) {
+	h.setGenreEnabled(c, true)
+}
+
+func (h *GenresHandler) DisableGenre(c *gin.Context) {
+	h.setGenreEnabled(c, false)
+}
+
+func (h *GenresHandler) setGenreEnabled(c *gin.Context, enabled bool) {
+	genreID, err := strconv.ParseUint(c.Param("genre_id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid genre ID"})
+		return
+	}
+
+	statusCode, content := h.Service.SetGenreEnabled(c.Request.Context(), genreID, enabled)
+	c.JSON(statusCode, content)
+}
@@ -0,0 +1,61 @@
+package handler
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/ranktify/ranktify-be/internal/model"
+	"github.com/ranktify/ranktify-be/internal/pagination"
+)
+
+// bounds of the :limit path parameter of the song listing endpoints
+const (
+	minSongsLimit = 1
+	maxSongsLimit = 50
+)
+
+// Reads the :limit path parameter and, when the request carries one, decodes the
+// cursor query parameter into state. Writes the error response when either is invalid.
+func pageParams(c *gin.Context, state any) (limit int, hasCursor bool, ok bool) {
+	limit, err := strconv.Atoi(c.Param("limit"))
+	if err != nil || limit < minSongsLimit || limit > maxSongsLimit {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid limit, expected a number between 1 and 50"})
+		return 0, false, false
+	}
+
+	cursor := c.Query("cursor")
+	if cursor == "" {
+		return limit, false, true
+	}
+	if err := pagination.DecodeCursor(cursor, state); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid cursor"})
+		return 0, false, false
+	}
+	return limit, true, true
+}
+
+// Encodes the state a listing continues from as an opaque cursor, empty when state
+// is nil
+func encodeCursor[T any](state *T) string {
+	if state == nil {
+		return ""
+	}
+	cursor, err := pagination.EncodeCursor(state)
+	if err != nil {
+		return ""
+	}
+	return cursor
+}
+
+// The envelope every song listing answers with, next_cursor is null on the last page
+func songsPage(songs []model.Song, nextCursor string, limit int) gin.H {
+	if songs == nil {
+		songs = []model.Song{}
+	}
+	page := gin.H{"songs": songs, "next_cursor": nil, "limit": limit}
+	if nextCursor != "" {
+		page["next_cursor"] = nextCursor
+	}
+	return page
+}
@@ -0,0 +1,34 @@
+package handler
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/ranktify/ranktify-be/internal/service"
+)
+
+type PlaylistExportHandler struct {
+	Service *service.PlaylistExportService
+}
+
+func NewPlaylistExportHandler(service *service.PlaylistExportService) *PlaylistExportHandler {
+	return &PlaylistExportHandler{Service: service}
+}
+
+// Exports the songs the user ranked at least min_rank (defaults to 4) to a playlist on
+// their Spotify account
+func (h *PlaylistExportHandler) ExportRankings(c *gin.Context) {
+	userID, accessToken, ok := spotifyCaller(c)
+	if !ok {
+		return
+	}
+	minRank, err := strconv.Atoi(c.DefaultQuery("min_rank", "4"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid min_rank"})
+		return
+	}
+
+	statusCode, content := h.Service.ExportRankings(c.Request.Context(), userID, accessToken, minRank)
+	writeServiceResponse(c, statusCode, content)
+}
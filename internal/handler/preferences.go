@@ -0,0 +1,34 @@
+package handler
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/ranktify/ranktify-be/internal/model"
+	"github.com/ranktify/ranktify-be/internal/service"
+)
+
+type PreferencesHandler struct {
+	Service *service.PreferencesService
+}
+
+func NewPreferencesHandler(service *service.PreferencesService) *PreferencesHandler {
+	return &PreferencesHandler{Service: service}
+}
+
+// Returns the authenticated user's market and locale
+func (h *PreferencesHandler) GetPreferences(c *gin.Context) {
+	statusCode, content := h.Service.GetPreferences(c.Request.Context(), c.GetUint64("userId"))
+	c.JSON(statusCode, content)
+}
+
+func (h *PreferencesHandler) UpdatePreferences(c *gin.Context) {
+	var preferences model.UserPreferences
+	if err := c.ShouldBindJSON(&preferences); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid input data"})
+		return
+	}
+
+	statusCode, content := h.Service.UpdatePreferences(c.Request.Context(), c.GetUint64("userId"), preferences)
+	c.JSON(statusCode, content)
+}
@@ -0,0 +1,62 @@
+package handler
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/ranktify/ranktify-be/internal/service"
+)
+
+type RankingQueueHandler struct {
+	Service *service.RankingQueueService
+}
+
+func NewRankingQueueHandler(service *service.RankingQueueService) *RankingQueueHandler {
+	return &RankingQueueHandler{Service: service}
+}
+
+// Queues every track of the Spotify playlist to be ranked
+func (h *RankingQueueHandler) ImportPlaylist(c *gin.Context) {
+	userID, accessToken, ok := spotifyCaller(c)
+	if !ok {
+		return
+	}
+	playlistID := c.Param("playlist_id")
+	if playlistID == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid playlist ID"})
+		return
+	}
+
+	statusCode, content := h.Service.ImportPlaylist(c.Request.Context(), userID, accessToken, spotifyRegion(c).Market, playlistID)
+	writeServiceResponse(c, statusCode, content)
+}
+
+// Queues the user's liked songs to be ranked
+func (h *RankingQueueHandler) ImportLikedSongs(c *gin.Context) {
+	h.importLibrary(c, service.QueueSourceLiked)
+}
+
+// Queues the user's recently played tracks to be ranked
+func (h *RankingQueueHandler) ImportRecentlyPlayed(c *gin.Context) {
+	h.importLibrary(c, service.QueueSourceRecent)
+}
+
+func (h *RankingQueueHandler) importLibrary(c *gin.Context, source string) {
+	userID, accessToken, ok := spotifyCaller(c)
+	if !ok {
+		return
+	}
+
+	statusCode, content := h.Service.ImportLibrary(c.Request.Context(), userID, accessToken, spotifyRegion(c).Market, source)
+	writeServiceResponse(c, statusCode, content)
+}
+
+func (h *RankingQueueHandler) GetQueue(c *gin.Context) {
+	statusCode, content := h.Service.GetQueue(c.Request.Context(), c.GetUint64("userId"))
+	c.JSON(statusCode, content)
+}
+
+func (h *RankingQueueHandler) ClearQueue(c *gin.Context) {
+	statusCode, content := h.Service.ClearQueue(c.Request.Context(), c.GetUint64("userId"))
+	c.JSON(statusCode, content)
+}
@@ -6,6 +6,7 @@ import (
 	"strconv"
 
 	"github.com/gin-gonic/gin"
+	"github.com/ranktify/ranktify-be/internal/model"
 	"github.com/ranktify/ranktify-be/internal/service"
 )
 
@@ -24,7 +25,7 @@ func (h *RankingsHandler) GetRankedSongs(c *gin.Context) {
 		return
 	}
 	userID := rawUserID.(uint64)
-	statusCode, content := h.Service.GetRankedSongs(userID)
+	statusCode, content := h.Service.GetRankedSongs(userID, c.Query("order"))
 	c.JSON(statusCode, content)
 }
 
@@ -47,7 +48,39 @@ func (h *RankingsHandler) GetFriendsRankedSongsWithNoUserRank(c *gin.Context) {
 		return
 	}
 	userID := rawUserID.(uint64)
-	statusCode, content := h.Service.GetFriendsRankedSongsWithNoUserRank(userID)
+	statusCode, content := h.Service.GetFriendsRankedSongsWithNoUserRank(c.Request.Context(), userID)
+	c.JSON(statusCode, content)
+}
+
+func (h *RankingsHandler) GetPairwiseComparison(c *gin.Context) {
+	rawUserID, ok := c.Get("userId")
+	if !ok {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid user ID"})
+		return
+	}
+	userID := rawUserID.(uint64)
+	statusCode, content := h.Service.GetPairwiseComparison(c.Request.Context(), userID)
+	c.JSON(statusCode, content)
+}
+
+func (h *RankingsHandler) RecordPairwiseComparison(c *gin.Context) {
+	rawUserID, ok := c.Get("userId")
+	if !ok {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid user ID"})
+		return
+	}
+	winnerID, err := strconv.ParseUint(c.Param("winner_id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid winner song ID"})
+		return
+	}
+	loserID, err := strconv.ParseUint(c.Param("loser_id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid loser song ID"})
+		return
+	}
+	userID := rawUserID.(uint64)
+	statusCode, content := h.Service.RecordPairwiseComparison(c.Request.Context(), userID, winnerID, loserID)
 	c.JSON(statusCode, content)
 }
 
@@ -68,21 +101,32 @@ func (h *RankingsHandler) RankSong(c *gin.Context) {
 		return
 	}
 	userID := rawUserID.(uint64)
-	statusCode, content := h.Service.RankSong(songID, userID, rank)
+	statusCode, content := h.Service.RankSong(c.Request.Context(), songID, userID, rank, requestSource(c))
 	c.JSON(statusCode, content)
 }
 
 func (h *RankingsHandler) DeleteRanking(c *gin.Context) {
+	rawUserID, ok := c.Get("userId")
+	if !ok {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid user ID"})
+		return
+	}
 	rankingID, err := strconv.ParseUint(c.Param("ranking_id"), 10, 64)
 	if err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid ranking ID"})
 		return
 	}
-	statusCode, content := h.Service.DeleteRanking(rankingID)
+	userID := rawUserID.(uint64)
+	statusCode, content := h.Service.DeleteRanking(c.Request.Context(), userID, rankingID, requestSource(c))
 	c.JSON(statusCode, content)
 }
 
 func (h *RankingsHandler) UpdateRanking(c *gin.Context) {
+	rawUserID, ok := c.Get("userId")
+	if !ok {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid user ID"})
+		return
+	}
 	rankingID, err := strconv.ParseUint(c.Param("ranking_id"), 10, 64)
 	if err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid ranking ID"})
@@ -93,7 +137,71 @@ func (h *RankingsHandler) UpdateRanking(c *gin.Context) {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid rank"})
 		return
 	}
-	statusCode, content := h.Service.UpdateRanking(rankingID, rank)
+	userID := rawUserID.(uint64)
+	statusCode, content := h.Service.UpdateRanking(c.Request.Context(), userID, rankingID, rank, requestSource(c))
+	c.JSON(statusCode, content)
+}
+
+func (h *RankingsHandler) GetRankingHistory(c *gin.Context) {
+	rawUserID, ok := c.Get("userId")
+	if !ok {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid user ID"})
+		return
+	}
+	songID, err := strconv.ParseUint(c.Param("song_id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid song ID"})
+		return
+	}
+	userID := rawUserID.(uint64)
+	statusCode, content := h.Service.GetRankingHistory(c.Request.Context(), userID, songID)
+	c.JSON(statusCode, content)
+}
+
+func (h *RankingsHandler) GetFriendsRankingChanges(c *gin.Context) {
+	rawUserID, ok := c.Get("userId")
+	if !ok {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid user ID"})
+		return
+	}
+	userID := rawUserID.(uint64)
+	statusCode, content := h.Service.GetFriendsRankingChanges(c.Request.Context(), userID)
+	c.JSON(statusCode, content)
+}
+
+func (h *RankingsHandler) GetChart(c *gin.Context) {
+	period := c.DefaultQuery("period", service.PeriodWeekly)
+	pageSize, err := strconv.Atoi(c.DefaultQuery("limit", "10"))
+	if err != nil || pageSize < 1 || pageSize > 50 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid limit, expected a number between 1 and 50"})
+		return
+	}
+	page, err := strconv.Atoi(c.DefaultQuery("page", "1"))
+	if err != nil || page < 1 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid page"})
+		return
+	}
+	filter := model.ChartFilter{
+		Genre:  c.Query("genre"),
+		Artist: c.Query("artist"),
+	}
+	statusCode, content := h.Service.GetChart(c.Request.Context(), period, c.Query("scoring"), filter, page, pageSize)
+	c.JSON(statusCode, content)
+}
+
+func (h *RankingsHandler) GetCompatibility(c *gin.Context) {
+	rawUserID, ok := c.Get("userId")
+	if !ok {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid user ID"})
+		return
+	}
+	otherUserID, err := strconv.ParseUint(c.Param("user_id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid user ID"})
+		return
+	}
+	userID := rawUserID.(uint64)
+	statusCode, content := h.Service.GetCompatibility(c.Request.Context(), userID, otherUserID)
 	c.JSON(statusCode, content)
 }
 
@@ -109,3 +217,8 @@ func (h *RankingsHandler) GetTopWeeklyTracks(c *gin.Context) {
 	}
 	c.JSON(http.StatusOK, songs)
 }
+
+// requestSource identifies the endpoint that made a ranking change for the ranking history
+func requestSource(c *gin.Context) string {
+	return c.Request.Method + " " + c.FullPath()
+}
@@ -0,0 +1,54 @@
+package handler
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/ranktify/ranktify-be/internal/model"
+	"github.com/ranktify/ranktify-be/internal/service"
+)
+
+type RecommendationFeedbackHandler struct {
+	Service *service.RecommendationFeedbackService
+}
+
+func NewRecommendationFeedbackHandler(service *service.RecommendationFeedbackService) *RecommendationFeedbackHandler {
+	return &RecommendationFeedbackHandler{Service: service}
+}
+
+// Lists the authenticated user's feedback on recommended songs
+func (h *RecommendationFeedbackHandler) GetFeedback(c *gin.Context) {
+	statusCode, content := h.Service.GetFeedback(c.Request.Context(), c.GetUint64("userId"))
+	c.JSON(statusCode, content)
+}
+
+// Records the user's feedback on a recommended song, the body holds the feedback and
+// optionally exclude_artist
+func (h *RecommendationFeedbackHandler) SaveFeedback(c *gin.Context) {
+	songID, err := strconv.ParseUint(c.Param("song_id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid song ID"})
+		return
+	}
+	var feedback model.RecommendationFeedback
+	if err := c.ShouldBindJSON(&feedback); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid input data"})
+		return
+	}
+
+	statusCode, content := h.Service.SaveFeedback(c.Request.Context(), c.GetUint64("userId"), songID, feedback.Feedback, feedback.ExcludeArtist)
+	c.JSON(statusCode, content)
+}
+
+// Forgets the user's feedback on the song so it can be recommended again
+func (h *RecommendationFeedbackHandler) DeleteFeedback(c *gin.Context) {
+	songID, err := strconv.ParseUint(c.Param("song_id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid song ID"})
+		return
+	}
+
+	statusCode, content := h.Service.DeleteFeedback(c.Request.Context(), c.GetUint64("userId"), songID)
+	c.JSON(statusCode, content)
+}
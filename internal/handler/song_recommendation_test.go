@@ -0,0 +1,204 @@
+package handler
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/ranktify/ranktify-be/internal/dao"
+	"github.com/ranktify/ranktify-be/internal/model"
+	"github.com/ranktify/ranktify-be/internal/recommendation"
+	"github.com/ranktify/ranktify-be/internal/service"
+	"github.com/ranktify/ranktify-be/internal/spotify/spotifytest"
+)
+
+const (
+	randomGenreQuery  = `FROM genres\s+WHERE enabled`
+	friendsSongsQuery = `SELECT DISTINCT ON \(s.song_id\)`
+)
+
+// the song a friend ranked, already stored so it isn't stored again
+const friendSongID = 42
+
+// expectDiscovery answers the queries Discovery makes on the first page: no genres in
+// the market, so only the random search runs, and a song a friend ranked
+func (env *testEnv) expectDiscovery(userID uint64, friendsShare int) {
+	env.mock.ExpectQuery(randomGenreQuery).WithArgs("PR").WillReturnRows(sqlmock.NewRows(nil))
+	env.mock.ExpectQuery(friendsSongsQuery).WithArgs(userID, friendsShare, 0).WillReturnRows(
+		sqlmock.NewRows(append(append([]string{}, songColumns...), "friend_id", "friend_rank")).AddRow(
+			friendSongID, "friend-ranked", "Friend's song", "Artist", "Album", nil,
+			"salsa", nil, nil, time.Now(), 9, 5,
+		),
+	)
+}
+
+// expectStoreSongs answers storing the recommended songs from the search in one
+// transaction, ids are given to every fixture track since which ones are recommended
+// is random
+func (env *testEnv) expectStoreSongs() {
+	ids := sqlmock.NewRows([]string{"spotify_id", "song_id"})
+	for i, spotifyID := range fixtureTrackIDs {
+		ids.AddRow(spotifyID, 100+i)
+	}
+	env.mock.ExpectBegin()
+	env.mock.ExpectExec(`INSERT INTO albums`).WillReturnResult(sqlmock.NewResult(0, 1))
+	env.mock.ExpectQuery(`INSERT INTO songs`).WillReturnRows(ids)
+	env.mock.ExpectExec(`INSERT INTO artists`).WillReturnResult(sqlmock.NewResult(0, 1))
+	env.mock.ExpectExec(`INSERT INTO song_artists`).WillReturnResult(sqlmock.NewResult(0, 1))
+	env.mock.ExpectCommit()
+}
+
+type recommendationPage struct {
+	Songs      []model.Recommendation `json:"songs"`
+	NextCursor *string                `json:"next_cursor"`
+	Limit      int                    `json:"limit"`
+}
+
+func TestSongRecommendation(t *testing.T) {
+	tests := []struct {
+		name         string
+		userID       uint64
+		accessToken  string
+		target       string
+		fail         int
+		failStatus   int
+		wantStatus   int
+		wantSearches int
+	}{
+		{
+			name:         "first page",
+			userID:       3001,
+			accessToken:  spotifytest.AccessToken,
+			target:       "/song-recommendation/2",
+			wantStatus:   http.StatusOK,
+			wantSearches: 1,
+		},
+		{
+			name:         "expired token is refreshed",
+			userID:       3002,
+			accessToken:  spotifytest.ExpiredAccessToken,
+			target:       "/song-recommendation/2",
+			wantStatus:   http.StatusOK,
+			wantSearches: 2,
+		},
+		{
+			name:        "invalid limit",
+			userID:      3003,
+			accessToken: spotifytest.AccessToken,
+			target:      "/song-recommendation/500",
+			wantStatus:  http.StatusBadRequest,
+		},
+		{
+			name:        "invalid cursor",
+			userID:      3004,
+			accessToken: spotifytest.AccessToken,
+			target:      "/song-recommendation/2?cursor=not-a-cursor",
+			wantStatus:  http.StatusBadRequest,
+		},
+		{
+			name:         "Spotify rejecting the search",
+			userID:       3005,
+			accessToken:  spotifytest.AccessToken,
+			target:       "/song-recommendation/2",
+			fail:         1,
+			failStatus:   http.StatusForbidden,
+			wantStatus:   http.StatusInternalServerError,
+			wantSearches: 1,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			env := newTestEnv(t)
+			blender := recommendation.NewBlender(nil, recommendation.WeightedStrategy{
+				Strategy: recommendation.NewDiscovery(env.spotify.Provider(), dao.NewRankingsDAO(env.db), dao.NewGenresDAO(env.db)),
+				Weight:   1,
+			})
+			handler := NewSongRecommendationHandler(
+				service.NewRecommendationService(blender, dao.NewSongsDAO(env.db), env.tokens),
+			)
+
+			if tt.wantSearches > 0 {
+				env.expectDiscovery(tt.userID, 1)
+			}
+			if tt.accessToken == spotifytest.ExpiredAccessToken {
+				env.expectRefresh(tt.userID)
+				// the page is built again with the new token
+				env.expectDiscovery(tt.userID, 1)
+			}
+			if tt.wantStatus == http.StatusOK {
+				env.expectStoreSongs()
+			}
+			env.spotify.FailNext(tt.fail, tt.failStatus, 0)
+
+			recorder := env.serve(tt.userID, tt.accessToken, http.MethodGet, "/song-recommendation/:limit", tt.target, handler.SongRecommendation)
+
+			if recorder.Code != tt.wantStatus {
+				t.Fatalf("status = %d, want %d: %s", recorder.Code, tt.wantStatus, recorder.Body)
+			}
+			if searches := env.spotify.Count("GET /v1/search"); searches != tt.wantSearches {
+				t.Errorf("searched %d times, want %d", searches, tt.wantSearches)
+			}
+			if err := env.mock.ExpectationsWereMet(); err != nil {
+				t.Error(err)
+			}
+			if tt.wantStatus != http.StatusOK {
+				return
+			}
+
+			var page recommendationPage
+			if err := json.Unmarshal(recorder.Body.Bytes(), &page); err != nil {
+				t.Fatalf("decoding %s: %v", recorder.Body, err)
+			}
+			if page.Limit != 2 || len(page.Songs) != 2 {
+				t.Fatalf("got %d songs with limit %d, want 2", len(page.Songs), page.Limit)
+			}
+			if page.NextCursor == nil {
+				t.Error("next_cursor = null, the search has more pages")
+			}
+
+			// the friend's song scores above the search results
+			friendSong, searchSong := page.Songs[0], page.Songs[1]
+			if friendSong.SongID != friendSongID || friendSong.Source != "discovery" || friendSong.Reason != "A friend ranked it 5" {
+				t.Errorf("first recommendation = %+v, want the song the friend ranked", friendSong)
+			}
+			if searchSong.SongID < 100 || searchSong.Reason != "Something new to rank" {
+				t.Errorf("second recommendation = %+v, want a stored search result", searchSong)
+			}
+		})
+	}
+}
+
+func TestSongRecommendationFollowsCursor(t *testing.T) {
+	env := newTestEnv(t)
+	blender := recommendation.NewBlender(nil, recommendation.WeightedStrategy{
+		Strategy: recommendation.NewDiscovery(env.spotify.Provider(), dao.NewRankingsDAO(env.db), dao.NewGenresDAO(env.db)),
+		Weight:   1,
+	})
+	handler := NewSongRecommendationHandler(
+		service.NewRecommendationService(blender, dao.NewSongsDAO(env.db), env.tokens),
+	)
+	const userID = 3006
+
+	env.expectDiscovery(userID, 1)
+	env.expectStoreSongs()
+	recorder := env.serve(userID, spotifytest.AccessToken, http.MethodGet, "/song-recommendation/:limit", "/song-recommendation/2", handler.SongRecommendation)
+	var first recommendationPage
+	if err := json.Unmarshal(recorder.Body.Bytes(), &first); err != nil || first.NextCursor == nil {
+		t.Fatalf("first page = %s, %v", recorder.Body, err)
+	}
+
+	// the next page continues the friends' songs after the first one and skips the genres
+	env.mock.ExpectQuery(friendsSongsQuery).WithArgs(userID, 1, 1).
+		WillReturnRows(sqlmock.NewRows(append(append([]string{}, songColumns...), "friend_id", "friend_rank")))
+	// both songs come from the search this time, stored together
+	env.expectStoreSongs()
+	recorder = env.serve(userID, spotifytest.AccessToken, http.MethodGet, "/song-recommendation/:limit", "/song-recommendation/2?cursor="+*first.NextCursor, handler.SongRecommendation)
+	if recorder.Code != http.StatusOK {
+		t.Fatalf("status = %d: %s", recorder.Code, recorder.Body)
+	}
+	if err := env.mock.ExpectationsWereMet(); err != nil {
+		t.Error(err)
+	}
+}
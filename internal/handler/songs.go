@@ -0,0 +1,49 @@
+package handler
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/ranktify/ranktify-be/internal/service"
+)
+
+type SongsHandler struct {
+	Service *service.SongsService
+}
+
+func NewSongsHandler(service *service.SongsService) *SongsHandler {
+	return &SongsHandler{Service: service}
+}
+
+func (h *SongsHandler) GetSong(c *gin.Context) {
+	rawUserID, ok := c.Get("userId")
+	if !ok {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid user ID"})
+		return
+	}
+	songID, err := strconv.ParseUint(c.Param("song_id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid song ID"})
+		return
+	}
+	userID := rawUserID.(uint64)
+	statusCode, content := h.Service.GetSongDetail(c.Request.Context(), userID, songID)
+	c.JSON(statusCode, content)
+}
+
+func (h *SongsHandler) GetSongBySpotifyID(c *gin.Context) {
+	rawUserID, ok := c.Get("userId")
+	if !ok {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid user ID"})
+		return
+	}
+	spotifyID := c.Param("spotify_id")
+	if spotifyID == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid spotify ID"})
+		return
+	}
+	userID := rawUserID.(uint64)
+	statusCode, content := h.Service.GetSongDetailBySpotifyID(c.Request.Context(), userID, spotifyID)
+	c.JSON(statusCode, content)
+}
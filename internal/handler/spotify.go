@@ -1,26 +1,37 @@
 package handler
 
 import (
-	"database/sql"
-	"fmt"
+	"errors"
+	"log"
 	"net/http"
-	"net/url"
+	"strconv"
 
 	"github.com/gin-gonic/gin"
 	"github.com/ranktify/ranktify-be/internal/dao"
 	"github.com/ranktify/ranktify-be/internal/model"
+	"github.com/ranktify/ranktify-be/internal/service"
 	"github.com/ranktify/ranktify-be/internal/spotify"
 )
 
+// how many songs GET /rank returns
+const songsToRank = 5
+
 type SpotifyHandler struct {
-	DAO *dao.SpotifyDAO
+	DAO      *dao.SpotifyDAO
+	Provider spotify.MusicProvider
+	Tokens   *service.SpotifyTokenService
+	Previews *service.PreviewService
+	Accounts *service.SpotifyAccountService
+	Queue    *service.RankingQueueService
+	Genres   *service.GenresService
 }
 
-func NewSpotifyHandler(dao *dao.SpotifyDAO) *SpotifyHandler {
-	return &SpotifyHandler{DAO: dao}
+func NewSpotifyHandler(dao *dao.SpotifyDAO, provider spotify.MusicProvider, tokens *service.SpotifyTokenService, previews *service.PreviewService, accounts *service.SpotifyAccountService, queue *service.RankingQueueService, genres *service.GenresService) *SpotifyHandler {
+	return &SpotifyHandler{DAO: dao, Provider: provider, Tokens: tokens, Previews: previews, Accounts: accounts, Queue: queue, Genres: genres}
 }
 
-// Receives the auth code to perform the final step of authorization code
+// Receives the auth code to perform the final step of authorization code, linking the
+// Spotify profile to the authenticated user
 func (h *SpotifyHandler) AuthCallback(c *gin.Context) {
 	var authCallbackResponse spotify.SpotifyAuthCallbackResponse
 
@@ -34,147 +45,251 @@ func (h *SpotifyHandler) AuthCallback(c *gin.Context) {
 		return
 	}
 
-	formData := url.Values{}
-	formData.Set("grant_type", "authorization_code")
-	formData.Set("code", authCallbackResponse.Code)
-	formData.Set("redirect_uri", spotify.GetSpotifyRedirectURI())
-
-	tokenResponse, err := spotify.FetchSpotifyToken(c.Request.Context(), formData)
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
-		return
-	}
-
-	rt := model.SpotifyRefreshToken{
-		UserID: authCallbackResponse.UserID,
-		Token:  tokenResponse.RefreshToken,
-	}
-	if err := h.DAO.SaveRefreshToken(rt); err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
-		return
-	}
+	status, response := h.Accounts.LinkAccount(c.Request.Context(), c.GetUint64("userId"), authCallbackResponse.Code)
+	writeServiceResponse(c, status, response)
+}
 
-	c.JSON(http.StatusOK, gin.H{"access_token": tokenResponse.AccessToken})
+// Unlinks the Spotify profile of the authenticated user and forgets its tokens
+func (h *SpotifyHandler) UnlinkAccount(c *gin.Context) {
+	status, response := h.Accounts.UnlinkAccount(c.Request.Context(), c.GetUint64("userId"))
+	c.JSON(status, response)
 }
 
-// Returns an access token given a user_id
+// Returns a fresh access token for the authenticated user. Clients don't need it anymore,
+// the backend refreshes tokens on its own, it stays for the ones that still send Spotify-Token
 func (h *SpotifyHandler) RefreshAccessToken(c *gin.Context) {
-	var refreshRequest spotify.SpotifyRefreshTokenRequest
-	if err := c.ShouldBindJSON(&refreshRequest); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
-		return
-	}
+	userID := c.GetUint64("userId")
 
-	refreshToken, err := h.DAO.GetRefreshToken(refreshRequest.UserID)
+	h.Tokens.Forget(userID)
+	accessToken, err := h.Tokens.Refresh(c.Request.Context(), userID)
 	if err != nil {
-		if err == sql.ErrNoRows {
+		if errors.Is(err, service.ErrSpotifyNotLinked) {
 			c.JSON(http.StatusNotFound, gin.H{"error": "refresh token not found"})
 		} else {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			writeSpotifyError(c, err)
 		}
 		return
 	}
-	fmt.Println(refreshToken)
 
-	formData := url.Values{}
-	formData.Set("grant_type", "refresh_token")
-	formData.Set("refresh_token", refreshToken)
+	// Return the new access token
+	c.JSON(http.StatusOK, gin.H{"access_token": accessToken})
+}
+
+// Get N tracks to rank, from the user's ranking queue first and their top tracks after
+func (h *SpotifyHandler) GetSongsToRank(c *gin.Context) {
+	userID, accessToken, ok := spotifyCaller(c)
+	if !ok {
+		return
+	}
 
-	response, err := spotify.FetchSpotifyToken(c.Request.Context(), formData)
+	songs, err := h.Queue.NextSongs(c.Request.Context(), userID, songsToRank)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve the ranking queue"})
 		return
 	}
-	fmt.Println(response)
-	// If the api returned a new rt then update it in the database
-	if response.RefreshToken != "" {
-		err := h.DAO.UpdateRefreshToken(refreshRequest.UserID, response.RefreshToken)
-		if err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
-			return
+
+	err = h.Tokens.WithAccessToken(c.Request.Context(), userID, accessToken, func(accessToken string) error {
+		if missing := songsToRank - len(songs); missing > 0 {
+			topTracks, err := h.Provider.TopTracks(c.Request.Context(), accessToken, songsToRank)
+			if err != nil {
+				return err
+			}
+			songs = appendMissingSongs(songs, topTracks, missing)
+		}
+		// songs without a preview are still worth ranking, don't fail the request
+		if err := h.Previews.FillPreviews(c.Request.Context(), accessToken, spotifyRegion(c).Market, songs); err != nil {
+			log.Printf("Couldn't fill the previews of the songs to rank: %v", err)
 		}
+		return nil
+	})
+	if err != nil {
+		writeSpotifyError(c, err)
+		return
 	}
 
-	// Return the new access token
-	c.JSON(http.StatusOK, gin.H{"access_token": response.AccessToken})
+	c.JSON(http.StatusOK, songs)
+}
 
+// Appends up to n of the candidates that aren't in songs yet
+func appendMissingSongs(songs []model.Song, candidates []model.Song, n int) []model.Song {
+	seen := make(map[string]bool, len(songs))
+	for _, song := range songs {
+		seen[song.SpotifyID] = true
+	}
+	for _, candidate := range candidates {
+		if n == 0 {
+			break
+		}
+		if seen[candidate.SpotifyID] {
+			continue
+		}
+		seen[candidate.SpotifyID] = true
+		songs = append(songs, candidate)
+		n--
+	}
+	return songs
 }
 
-// Get N tracks to rank
-func (h *SpotifyHandler) GetSongsToRank(c *gin.Context) {
-	rawToken, ok := c.Get("spotifyToken")
+// Pages through a random search, the cursor of the previous page continues it
+func (h *SpotifyHandler) GetRandomSongsToRank(c *gin.Context) {
+	userID, accessToken, ok := spotifyCaller(c)
 	if !ok {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "No access token provided"})
 		return
 	}
-	accessToken := rawToken.(string)
-
-	client := spotify.SpotifyClientFromAccessToken(c.Request.Context(), accessToken)
 
-	songs, err := spotify.GetTopNSongs(c.Request.Context(), client, 5)
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	var cursor spotify.SearchCursor
+	limit, hasCursor, ok := pageParams(c, &cursor)
+	if !ok {
+		return
+	}
+	if !hasCursor {
+		cursor = spotify.RandomSearch("")
+	} else if !cursor.Valid() || cursor.Genre != "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid cursor"})
 		return
 	}
 
-	c.JSON(http.StatusOK, songs)
+	h.writeSearchPage(c, userID, accessToken, cursor, limit, nil)
 }
 
-func (h *SpotifyHandler) GetRandomSongsToRank(c *gin.Context) {
-	rawToken, ok := c.Get("spotifyToken")
+// Pages through a random search of the genre, which must be enabled in the catalog
+func (h *SpotifyHandler) GetRandomSongsByGenreToRank(c *gin.Context) {
+	userID, accessToken, ok := spotifyCaller(c)
 	if !ok {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "No access token provided"})
 		return
 	}
-	accessToken := rawToken.(string)
 
-	songs, err := spotify.GetRandomSongs(c.Request.Context(), accessToken)
+	genre, err := h.Genres.GetEnabledGenre(c.Request.Context(), c.Param("genre"))
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve genre"})
 		return
 	}
-	c.JSON(http.StatusOK, songs)
+	if genre == nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid genre"})
+		return
+	}
+
+	var cursor spotify.SearchCursor
+	limit, hasCursor, ok := pageParams(c, &cursor)
+	if !ok {
+		return
+	}
+	if !hasCursor {
+		cursor = spotify.RandomSearch(genre.SearchKey)
+	} else if !cursor.Valid() || cursor.Genre != genre.SearchKey {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid cursor"})
+		return
+	}
+
+	h.writeSearchPage(c, userID, accessToken, cursor, limit, gin.H{"genre": genre.SearchKey})
 }
 
-func (h *SpotifyHandler) GetRandomSongsByGenreToRank(c *gin.Context) {
-	rawToken, ok := c.Get("spotifyToken")
+// Pages through a random search of a random genre of the user's market, the cursor
+// stays on the genre the first page picked
+func (h *SpotifyHandler) GetRandomSongsByRandomGenreToRank(c *gin.Context) {
+	userID, accessToken, ok := spotifyCaller(c)
 	if !ok {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "No access token provided"})
 		return
 	}
-	accessToken := rawToken.(string)
 
-	genre := c.Param("genre")
-	if genre == "" {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid genre"})
+	var cursor spotify.SearchCursor
+	limit, hasCursor, ok := pageParams(c, &cursor)
+	if !ok {
 		return
 	}
-	songs, err := spotify.GetRandomSongsByGenre(c.Request.Context(), accessToken, genre)
+	if hasCursor {
+		genre, err := h.Genres.GetEnabledGenre(c.Request.Context(), cursor.Genre)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve genre"})
+			return
+		}
+		if genre == nil || !cursor.Valid() {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid cursor"})
+			return
+		}
+	} else {
+		randomGenre, ok := pickRandomGenre(c, h.Genres, spotifyRegion(c))
+		if !ok {
+			return
+		}
+		cursor = spotify.RandomSearch(randomGenre)
+	}
+
+	h.writeSearchPage(c, userID, accessToken, cursor, limit, gin.H{"genre": cursor.Genre})
+}
+
+// Writes the page of the search the cursor points at, along with the extra fields
+func (h *SpotifyHandler) writeSearchPage(c *gin.Context, userID uint64, accessToken string, cursor spotify.SearchCursor, limit int, extra gin.H) {
+	var songs []model.Song
+	var next *spotify.SearchCursor
+	err := h.Tokens.WithAccessToken(c.Request.Context(), userID, accessToken, func(accessToken string) error {
+		var err error
+		songs, next, err = spotify.SearchPage(c.Request.Context(), h.Provider, accessToken, spotifyRegion(c), cursor, limit)
+		return err
+	})
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		writeSpotifyError(c, err)
 		return
 	}
-	c.JSON(http.StatusOK, songs)
+
+	page := songsPage(songs, encodeCursor(next), limit)
+	for key, value := range extra {
+		page[key] = value
+	}
+	c.JSON(http.StatusOK, page)
 }
 
-func (h *SpotifyHandler) GetRandomSongsByRandomGenreToRank(c *gin.Context) {
+// Reads the caller and the access token SpotifyTokenMiddleware resolved, writing the
+// error response when they are missing
+func spotifyCaller(c *gin.Context) (uint64, string, bool) {
+	rawUserID, ok := c.Get("userId")
+	if !ok {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid user ID"})
+		return 0, "", false
+	}
 	rawToken, ok := c.Get("spotifyToken")
 	if !ok {
 		c.JSON(http.StatusUnauthorized, gin.H{"error": "No access token provided"})
-		return
+		return 0, "", false
 	}
-	accessToken := rawToken.(string)
+	return rawUserID.(uint64), rawToken.(string), true
+}
 
-	randomGenre := spotify.GetRandomGenre()
+// Reads the market and locale SpotifyRegionMiddleware resolved, the defaults when it
+// didn't run
+func spotifyRegion(c *gin.Context) spotify.Region {
+	region, _ := c.Get("spotifyRegion")
+	if region, ok := region.(spotify.Region); ok {
+		return region
+	}
+	return spotify.Region{Market: spotify.MarketOrDefault("")}
+}
 
-	songs, err := spotify.GetRandomSongsByGenre(c.Request.Context(), accessToken, randomGenre)
+// Picks a random genre offered in the region, writing the error response when there
+// is none
+func pickRandomGenre(c *gin.Context, genres *service.GenresService, region spotify.Region) (string, bool) {
+	genre, err := genres.RandomGenre(c.Request.Context(), region.Market)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
-		return
+		if errors.Is(err, service.ErrNoGenres) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "No genres available in your market"})
+		} else {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve genres"})
+		}
+		return "", false
 	}
+	return genre, true
+}
 
-	c.JSON(http.StatusOK, gin.H{
-		"genre": randomGenre,
-		"songs": songs,
-	})
+// Writes a failed Spotify call, rate limits and outages tell the client when to retry
+func writeSpotifyError(c *gin.Context, err error) {
+	status, response := service.SpotifyErrorResponse(err)
+	writeServiceResponse(c, status, response)
+}
+
+// Writes a service response, mirroring its retry_after hint in the Retry-After header
+func writeServiceResponse(c *gin.Context, status int, response map[string]any) {
+	if seconds, ok := response["retry_after"].(int); ok {
+		c.Header("Retry-After", strconv.Itoa(seconds))
+	}
+	c.JSON(status, response)
 }
@@ -0,0 +1,237 @@
+package handler
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/gin-gonic/gin"
+	"github.com/ranktify/ranktify-be/internal/dao"
+	"github.com/ranktify/ranktify-be/internal/model"
+	"github.com/ranktify/ranktify-be/internal/service"
+	"github.com/ranktify/ranktify-be/internal/spotify"
+	"github.com/ranktify/ranktify-be/internal/spotify/spotifytest"
+	"github.com/ranktify/ranktify-be/internal/testutil"
+)
+
+// the tracks of the fake Spotify's fixtures, in order
+var fixtureTrackIDs = []string{
+	"4uLU6hMCjMI75M1A2tKUQC",
+	"6habFhsOp2NvshLv26DqMb",
+	"0VjIjW4GlUZAMYd2vXMi3b",
+	"2Fxmhks0bxGSBdJ92vM42m",
+	"3n3Ppam7vgaVa1iaRUc9Lp",
+}
+
+const (
+	queuedSongsQuery = `FROM ranking_queue q\s+JOIN songs s`
+	previewsQuery    = `SELECT spotify_id, preview_uri\s+FROM preview_cache`
+)
+
+var songColumns = []string{
+	"song_id", "spotify_id", "title", "artist", "album", "release_date",
+	"genre", "cover_uri", "preview_uri", "created_at",
+}
+
+func init() {
+	gin.SetMode(gin.TestMode)
+}
+
+// testEnv is a mocked database and a fake Spotify for the handlers to talk to
+type testEnv struct {
+	db      *sql.DB
+	mock    sqlmock.Sqlmock
+	spotify *spotifytest.Server
+	tokens  *service.SpotifyTokenService
+}
+
+func newTestEnv(t *testing.T) *testEnv {
+	db, mock := testutil.MockDB(t)
+	server := testutil.SpotifyServer(t)
+	return &testEnv{
+		db:      db,
+		mock:    mock,
+		spotify: server,
+		tokens:  service.NewSpotifyTokenService(&dao.SpotifyDAO{DB: db, Keyring: testutil.Keyring(t)}, server.Provider()),
+	}
+}
+
+// serve runs the request through a router that authenticates it as userID with the
+// Spotify accessToken, the way AuthMiddleware and SpotifyTokenMiddleware do
+func (env *testEnv) serve(userID uint64, accessToken string, method string, path string, target string, handle gin.HandlerFunc) *httptest.ResponseRecorder {
+	router := gin.New()
+	router.Use(func(c *gin.Context) {
+		c.Set("userId", userID)
+		c.Set("spotifyToken", accessToken)
+		c.Set("spotifyRegion", spotify.Region{Market: "PR"})
+	})
+	router.Handle(method, path, handle)
+
+	recorder := httptest.NewRecorder()
+	router.ServeHTTP(recorder, httptest.NewRequest(method, target, nil))
+	return recorder
+}
+
+func (env *testEnv) expectRefresh(userID uint64) {
+	env.tokens.Forget(userID)
+	testutil.ExpectRefreshToken(env.mock, userID, true)
+}
+
+// expectCachedPreviews answers the preview cache with every fixture track, none with
+// a preview, so nothing is scraped
+func (env *testEnv) expectCachedPreviews() {
+	rows := sqlmock.NewRows([]string{"spotify_id", "preview_uri"})
+	for _, spotifyID := range fixtureTrackIDs {
+		rows.AddRow(spotifyID, nil)
+	}
+	env.mock.ExpectQuery(previewsQuery).WillReturnRows(rows)
+}
+
+func queuedSongRows(n int) *sqlmock.Rows {
+	rows := sqlmock.NewRows(append(append([]string{}, songColumns...), "position", "source", "added_at"))
+	for i := 0; i < n; i++ {
+		rows.AddRow(
+			i+1, "queued-"+string(rune('a'+i)), "Queued song", "Artist", "Album", nil,
+			"pop", nil, "https://p.scdn.co/mp3-preview/queued", time.Now(),
+			i, "playlist", time.Now(),
+		)
+	}
+	return rows
+}
+
+func TestGetSongsToRank(t *testing.T) {
+	tests := []struct {
+		name            string
+		userID          uint64
+		accessToken     string
+		queued          int
+		queueErr        error
+		fail            int
+		failStatus      int
+		retryAfter      time.Duration
+		wantStatus      int
+		wantSongs       int
+		wantQueued      int
+		wantTopTracks   int
+		wantRetryHeader string
+	}{
+		{
+			name:          "top tracks fill an empty queue",
+			userID:        2001,
+			accessToken:   spotifytest.AccessToken,
+			wantStatus:    http.StatusOK,
+			wantSongs:     songsToRank,
+			wantTopTracks: 1,
+		},
+		{
+			name:          "queued songs come first",
+			userID:        2002,
+			accessToken:   spotifytest.AccessToken,
+			queued:        2,
+			wantStatus:    http.StatusOK,
+			wantSongs:     songsToRank,
+			wantQueued:    2,
+			wantTopTracks: 1,
+		},
+		{
+			name:        "a full queue doesn't need Spotify",
+			userID:      2003,
+			accessToken: spotifytest.AccessToken,
+			queued:      songsToRank,
+			wantStatus:  http.StatusOK,
+			wantSongs:   songsToRank,
+			wantQueued:  songsToRank,
+		},
+		{
+			name:          "expired token is refreshed",
+			userID:        2004,
+			accessToken:   spotifytest.ExpiredAccessToken,
+			wantStatus:    http.StatusOK,
+			wantSongs:     songsToRank,
+			wantTopTracks: 2,
+		},
+		{
+			name:            "rate limit tells the client when to retry",
+			userID:          2005,
+			accessToken:     spotifytest.AccessToken,
+			fail:            1,
+			failStatus:      http.StatusTooManyRequests,
+			retryAfter:      time.Minute,
+			wantStatus:      http.StatusTooManyRequests,
+			wantTopTracks:   1,
+			wantRetryHeader: "60",
+		},
+		{
+			name:        "queue failure",
+			userID:      2006,
+			accessToken: spotifytest.AccessToken,
+			queueErr:    errors.New("connection refused"),
+			wantStatus:  http.StatusInternalServerError,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			env := newTestEnv(t)
+			handler := NewSpotifyHandler(nil, env.spotify.Provider(), env.tokens,
+				service.NewPreviewService(dao.NewPreviewCacheDAO(env.db), env.spotify.Provider()), nil,
+				service.NewRankingQueueService(dao.NewRankingQueueDAO(env.db), dao.NewSongsDAO(env.db), env.spotify.Provider(), env.tokens),
+				nil,
+			)
+
+			queue := env.mock.ExpectQuery(queuedSongsQuery).WithArgs(tt.userID, songsToRank)
+			if tt.queueErr != nil {
+				queue.WillReturnError(tt.queueErr)
+			} else {
+				queue.WillReturnRows(queuedSongRows(tt.queued))
+			}
+			if tt.accessToken == spotifytest.ExpiredAccessToken {
+				env.expectRefresh(tt.userID)
+			}
+			if tt.wantStatus == http.StatusOK && tt.queued < songsToRank {
+				env.expectCachedPreviews()
+			}
+			env.spotify.FailNext(tt.fail, tt.failStatus, tt.retryAfter)
+
+			recorder := env.serve(tt.userID, tt.accessToken, http.MethodGet, "/api/rank", "/api/rank", handler.GetSongsToRank)
+
+			if recorder.Code != tt.wantStatus {
+				t.Fatalf("status = %d, want %d: %s", recorder.Code, tt.wantStatus, recorder.Body)
+			}
+			if got := recorder.Header().Get("Retry-After"); got != tt.wantRetryHeader {
+				t.Errorf("Retry-After = %q, want %q", got, tt.wantRetryHeader)
+			}
+			if topTracks := env.spotify.Count("GET /v1/me/top/tracks"); topTracks != tt.wantTopTracks {
+				t.Errorf("requested the top tracks %d times, want %d", topTracks, tt.wantTopTracks)
+			}
+			if err := env.mock.ExpectationsWereMet(); err != nil {
+				t.Error(err)
+			}
+			if tt.wantStatus != http.StatusOK {
+				return
+			}
+
+			var songs []model.Song
+			if err := json.Unmarshal(recorder.Body.Bytes(), &songs); err != nil {
+				t.Fatalf("decoding %s: %v", recorder.Body, err)
+			}
+			if len(songs) != tt.wantSongs {
+				t.Fatalf("got %d songs, want %d", len(songs), tt.wantSongs)
+			}
+			seen := make(map[string]bool)
+			for i, song := range songs {
+				if seen[song.SpotifyID] {
+					t.Errorf("%s returned twice", song.SpotifyID)
+				}
+				seen[song.SpotifyID] = true
+				if queued := song.SongID != 0; queued != (i < tt.wantQueued) {
+					t.Errorf("song %d (%s) queued = %v, want the %d queued songs first", i, song.SpotifyID, queued, tt.wantQueued)
+				}
+			}
+		})
+	}
+}
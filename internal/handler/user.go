@@ -7,6 +7,7 @@ import (
 	"github.com/gin-gonic/gin"
 	"github.com/ranktify/ranktify-be/internal/model"
 	"github.com/ranktify/ranktify-be/internal/service"
+	"github.com/ranktify/ranktify-be/internal/spotify"
 )
 
 type UserHandler struct {
@@ -40,6 +41,22 @@ func (h *UserHandler) ValidateUser(c *gin.Context) {
 	c.JSON(statusCode, content)
 }
 
+// Logs in with the authorization code of the Spotify login, signing up on the first one
+func (h *UserHandler) LoginWithSpotify(c *gin.Context) {
+	var authCallbackResponse spotify.SpotifyAuthCallbackResponse
+	if err := c.ShouldBind(&authCallbackResponse); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if authCallbackResponse.Err != "" {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": authCallbackResponse.Err})
+		return
+	}
+
+	statusCode, content := h.Service.LoginWithSpotify(c.Request.Context(), authCallbackResponse.Code)
+	writeServiceResponse(c, statusCode, content)
+}
+
 func (h *UserHandler) GetUserByID(c *gin.Context) {
 	userID, err := strconv.ParseUint(c.Param("id"), 10, 64)
 	if err != nil {
@@ -58,6 +75,11 @@ func (h *UserHandler) GetAllUsers(c *gin.Context) {
 }
 
 func (h *UserHandler) UpdateUserByID(c *gin.Context) {
+	rawCallerID, ok := c.Get("userId")
+	if !ok {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid user ID"})
+		return
+	}
 	userID, err := strconv.ParseUint(c.Param("id"), 10, 64)
 	if err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid user ID"})
@@ -68,19 +90,24 @@ func (h *UserHandler) UpdateUserByID(c *gin.Context) {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid input data"})
 		return
 	}
-	statusCode, content := h.Service.UpdateUserByID(userID, &user)
+	statusCode, content := h.Service.UpdateUserByID(rawCallerID.(uint64), userID, &user)
 
 	c.JSON(statusCode, content)
 }
 
 func (h *UserHandler) DeleteUserByID(c *gin.Context) {
+	rawCallerID, ok := c.Get("userId")
+	if !ok {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid user ID"})
+		return
+	}
 	userID, err := strconv.ParseUint(c.Param("id"), 10, 64)
 	if err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid user ID"})
 		return
 	}
 
-	statusCode, content := h.Service.DeleteUserByID(userID)
+	statusCode, content := h.Service.DeleteUserByID(rawCallerID.(uint64), userID)
 	c.JSON(statusCode, content)
 }
 
@@ -0,0 +1,19 @@
+package middleware
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/ranktify/ranktify-be/internal/dao"
+	"github.com/ranktify/ranktify-be/internal/service"
+)
+
+// Lets through only the users with the role. Needs AuthMiddleware first.
+func RoleMiddleware(userDAO *dao.UserDAO, role string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		status, response, ok := service.AuthorizeRole(c.Request.Context(), userDAO, c.GetUint64("userId"), role)
+		if !ok {
+			c.AbortWithStatusJSON(status, response)
+			return
+		}
+		c.Next()
+	}
+}
@@ -0,0 +1,29 @@
+package middleware
+
+import (
+	"database/sql"
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/ranktify/ranktify-be/internal/service"
+)
+
+// Sets the market and locale of the authenticated user's Spotify searches. Needs
+// AuthMiddleware first.
+func SpotifyRegionMiddleware(preferences *service.PreferencesService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		region, err := preferences.Region(c.Request.Context(), c.GetUint64("userId"))
+		if err != nil {
+			if errors.Is(err, sql.ErrNoRows) {
+				c.AbortWithStatusJSON(http.StatusNotFound, gin.H{"error": "User not found"})
+			} else {
+				c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve preferences"})
+			}
+			return
+		}
+
+		c.Set("spotifyRegion", region)
+		c.Next()
+	}
+}
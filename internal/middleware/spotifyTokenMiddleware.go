@@ -1,26 +1,53 @@
 package middleware
 
 import (
+	"errors"
 	"net/http"
+	"strconv"
 	"strings"
 
 	"github.com/gin-gonic/gin"
+	"github.com/ranktify/ranktify-be/internal/service"
 )
 
-func SpotifyTokenMiddleware() gin.HandlerFunc {
+// Resolves the caller's Spotify access token, a Spotify-Token header wins over the
+// token the backend manages for the authenticated user. Needs AuthMiddleware first.
+func SpotifyTokenMiddleware(tokens *service.SpotifyTokenService) gin.HandlerFunc {
 	return func(c *gin.Context) {
-		rawSpotifyToken := c.GetHeader("Spotify-Token")
-		if rawSpotifyToken == "" {
-			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "Spotify-Token header is required"})
-			return
-		}
-		accessToken := strings.TrimPrefix(rawSpotifyToken, "Bearer ")
+		accessToken := strings.TrimPrefix(c.GetHeader("Spotify-Token"), "Bearer ")
 		if accessToken == "" {
-			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "Spotify-Token header is required"})
-			return
+			userID := c.GetUint64("userId")
+			token, err := tokens.AccessToken(c.Request.Context(), userID)
+			if err != nil {
+				abortWithRefreshError(c, err)
+				return
+			}
+			accessToken = token
 		}
 
 		c.Set("spotifyToken", accessToken)
 		c.Next()
 	}
 }
+
+// Aborts with why the managed token couldn't be refreshed, without leaking the cause.
+// A refresh token Spotify rejects, revoked or expired, needs the account linked again.
+func abortWithRefreshError(c *gin.Context, err error) {
+	if errors.Is(err, service.ErrSpotifyNotLinked) {
+		c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "Spotify account not linked, authorize ranktify on Spotify first"})
+		return
+	}
+
+	status, response := service.SpotifyErrorResponse(err)
+	switch status {
+	case http.StatusUnauthorized, http.StatusBadRequest:
+		c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "Spotify authorization expired, authorize ranktify on Spotify again"})
+	case http.StatusTooManyRequests, http.StatusServiceUnavailable:
+		if seconds, ok := response["retry_after"].(int); ok {
+			c.Header("Retry-After", strconv.Itoa(seconds))
+		}
+		c.AbortWithStatusJSON(status, response)
+	default:
+		c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve the Spotify access token"})
+	}
+}
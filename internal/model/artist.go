@@ -0,0 +1,32 @@
+package model
+
+import "time"
+
+type Artist struct {
+	ArtistID  uint64 `json:"artist_id,omitempty"`
+	SpotifyID string `json:"spotify_id"`
+	Name      string `json:"name"`
+}
+
+type Album struct {
+	AlbumID     uint64     `json:"album_id,omitempty"`
+	SpotifyID   string     `json:"spotify_id"`
+	Name        string     `json:"name"`
+	ReleaseDate *time.Time `json:"release_date,omitempty"`
+	CoverURI    *string    `json:"cover_uri,omitempty"`
+}
+
+// ArtistSong is a song of an artist along with its ranking statistics
+type ArtistSong struct {
+	Song
+	AvgRank      *float64 `json:"avg_rank"` // nil when nobody ranked the song
+	RatingsCount int      `json:"rating_count"`
+}
+
+// ArtistDetail is an artist along with the ranking statistics of all of their songs
+type ArtistDetail struct {
+	Artist
+	AvgRank      *float64     `json:"avg_rank"`
+	RatingsCount int          `json:"rating_count"`
+	Songs        []ArtistSong `json:"songs"`
+}
@@ -0,0 +1,55 @@
+package model
+
+import (
+	"time"
+
+	"github.com/ranktify/ranktify-be/internal/rating"
+)
+
+// ChartEntry is a song placed in a chart along with its ranking statistics
+type ChartEntry struct {
+	Song
+	Position         int     `json:"position"`
+	AvgRank          float64 `json:"avg_rank"`
+	RatingsCount     int     `json:"rating_count"`
+	Score            float64 `json:"score"`
+	Confidence       float64 `json:"confidence"`        // 0-1, how much the score relies on the song's own ratings
+	PreviousPosition *int    `json:"previous_position"` // nil when it wasn't charted in the previous period
+	PositionChange   *int    `json:"position_change"`   // positive when the song moved up
+	NewEntry         bool    `json:"new_entry"`
+}
+
+type Chart struct {
+	Period   string       `json:"period"`
+	Start    *time.Time   `json:"start,omitempty"` // nil for the all-time chart
+	End      *time.Time   `json:"end,omitempty"`
+	Scoring  string       `json:"scoring"`
+	Prior    rating.Prior `json:"prior"`
+	Page     int          `json:"page"`
+	PageSize int          `json:"page_size"`
+	Total    int          `json:"total"`
+	Entries  []ChartEntry `json:"entries"`
+}
+
+// ChartFilter narrows a chart down to a genre and/or artist, empty fields don't filter
+type ChartFilter struct {
+	Genre  string
+	Artist string
+}
+
+// SongRankingAggregate holds the ranking statistics of a song over a period
+type SongRankingAggregate struct {
+	SongID       uint64
+	AvgRank      float64
+	RatingsCount int
+	Score        float64 // set by the chart's scorer
+}
+
+// TopTrack is one of the songs the user's friends ranked the highest
+type TopTrack struct {
+	Song
+	AvgRank      float64 `json:"avg_rank"`
+	RatingsCount int     `json:"rating_count"`
+	Score        float64 `json:"score"`
+	Confidence   float64 `json:"confidence"`
+}
@@ -0,0 +1,19 @@
+package model
+
+import "time"
+
+// Genre is a genre of the catalog songs to rank are searched by, SearchKey is what
+// goes in Spotify's genre: filter
+type Genre struct {
+	GenreID   uint64 `json:"genre_id"`
+	SearchKey string `json:"search_key"`
+	NameEN    string `json:"name_en"`
+	NameES    string `json:"name_es"`
+	// name in the requested locale, only set on listings
+	Name string `json:"name,omitempty"`
+	// ISO 3166-1 alpha-2 markets the genre is offered in, empty for every market
+	Markets   []string  `json:"markets"`
+	Enabled   bool      `json:"enabled"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
@@ -0,0 +1,13 @@
+package model
+
+import "time"
+
+// PlaylistExport is the Spotify playlist a user's rankings of at least MinRank are
+// exported to
+type PlaylistExport struct {
+	UserID     uint64    `json:"user_id"`
+	MinRank    int       `json:"min_rank"`
+	PlaylistID string    `json:"playlist_id"`
+	TrackCount int       `json:"track_count"`
+	ExportedAt time.Time `json:"exported_at"`
+}
@@ -0,0 +1,11 @@
+package model
+
+import "time"
+
+// QueuedSong is a song waiting in the user's ranking queue
+type QueuedSong struct {
+	Song
+	Position int       `json:"position"`
+	Source   string    `json:"source"`
+	AddedAt  time.Time `json:"added_at"`
+}
@@ -1,10 +1,69 @@
 package model
 
+import "time"
+
 type Rankings struct {
+	RankingID uint64   `json:"ranking_id"`
+	SongID    uint64   `json:"song_id"`
+	UserID    uint64   `json:"user_id"`
+	Rank      int      `json:"rank"`
+	Rating    *float64 `json:"rating,omitempty"` // pairwise Elo rating, only set for order=pairwise
+	CreatedAt string   `json:"created_at"`
+	UpdatedAt string   `json:"updated_at"`
+}
+
+// RankSongResult is the outcome of ranking a song, which either creates the user's
+// ranking or updates the one they already had
+type RankSongResult struct {
 	RankingID uint64 `json:"ranking_id"`
-	SongID    uint64 `json:"song_id"`
-	UserID    uint64 `json:"user_id"`
-	Rank      int    `json:"rank"`
-	CreatedAt string `json:"created_at"`
-	UpdatedAt string `json:"updated_at"`
+	Created   bool   `json:"created"`
+	// FirstRankToday is set when the song hadn't counted towards the user's streak
+	// yet today, only then the ranking counts towards it
+	FirstRankToday bool `json:"-"`
+}
+
+// PairwiseSong is one side of a "this or that" comparison
+type PairwiseSong struct {
+	Song
+	Rank        int     `json:"rank"`
+	Rating      float64 `json:"rating"`
+	Comparisons int     `json:"comparisons"`
+}
+
+// RankingHistory is one entry of the append-only audit trail of a user's ranking
+type RankingHistory struct {
+	HistoryID uint64    `json:"history_id"`
+	UserID    uint64    `json:"user_id"`
+	SongID    uint64    `json:"song_id"`
+	OldRank   *int      `json:"old_rank"` // nil when the song was ranked for the first time
+	NewRank   *int      `json:"new_rank"` // nil when the ranking was deleted
+	Source    string    `json:"source"`
+	ChangedAt time.Time `json:"changed_at"`
+}
+
+// RankingChange is a friend's ranking history entry along with the song it refers to
+type RankingChange struct {
+	RankingHistory
+	Username string `json:"username"`
+	Song     Song   `json:"song"`
+}
+
+// SharedRanking is a song ranked by two users along with both ranks
+type SharedRanking struct {
+	Song
+	UserRank  int `json:"user_rank"`
+	OtherRank int `json:"other_rank"`
+}
+
+// Compatibility measures how similar the taste of two users is from the songs they both ranked
+type Compatibility struct {
+	UserID        uint64          `json:"user_id"`
+	OtherUserID   uint64          `json:"other_user_id"`
+	Score         *float64        `json:"score"`      // -1 to 1, nil when they share too few songs
+	Percentage    *float64        `json:"percentage"` // the score mapped to 0-100
+	Method        string          `json:"method,omitempty"`
+	SharedSongs   int             `json:"shared_songs"`
+	MinOverlap    int             `json:"min_overlap"`
+	Agreements    []SharedRanking `json:"agreements"`
+	Disagreements []SharedRanking `json:"disagreements"`
 }
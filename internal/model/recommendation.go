@@ -0,0 +1,41 @@
+package model
+
+import "time"
+
+// FriendRankedSong is a song one of the user's friends ranked, FriendRank is the
+// highest rank a friend gave it
+type FriendRankedSong struct {
+	Song
+	FriendID   uint64 `json:"friend_id"`
+	FriendRank int    `json:"friend_rank"`
+}
+
+// SimilarSong is a song similar to the ones a user ranked high, BecauseOf is the
+// ranked song it is most similar to
+type SimilarSong struct {
+	Song
+	Score     float64 `json:"score"`
+	BecauseOf Song    `json:"because_of"`
+}
+
+// Recommendation is a song recommended to the user, Source names the strategy that
+// proposed it and Score how strongly it did, relative to the strategy's other songs
+type Recommendation struct {
+	Song
+	Source string  `json:"source"`
+	Score  float64 `json:"score"`
+	// why the song was recommended, e.g. "Because you ranked Song by Artist"
+	Reason string `json:"reason"`
+	// the ranked song it was recommended for, only set by the collaborative filter
+	BecauseOf *Song `json:"because_of,omitempty"`
+}
+
+// RecommendationFeedback is what the user told us about a recommended song, the song
+// isn't recommended again and neither are the songs of ExcludedArtistID when it's set
+type RecommendationFeedback struct {
+	Song
+	Feedback         string    `json:"feedback"`
+	ExcludeArtist    bool      `json:"exclude_artist"`
+	ExcludedArtistID *uint64   `json:"excluded_artist_id,omitempty"`
+	UpdatedAt        time.Time `json:"updated_at"`
+}
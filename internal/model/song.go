@@ -13,4 +13,9 @@ type Song struct {
 	CoverURI    *string    `json:"cover_uri,omitempty"`
 	PreviewURI  *string    `json:"preview_uri,omitempty"`
 	CreatedAt   time.Time  `json:"created_at"`
+	// every genre of the song, Genre is the first one found
+	Genres []string `json:"genres,omitempty"`
+	// every artist of the song, main artist first, and the album it belongs to
+	Artists      []Artist `json:"artists,omitempty"`
+	AlbumDetails *Album   `json:"album_details,omitempty"`
 }
@@ -0,0 +1,25 @@
+package model
+
+// SongRankingStats are the global ranking statistics of a song
+type SongRankingStats struct {
+	Histogram    map[int]int `json:"histogram"` // number of rankings per rank, 1 to 5
+	AvgRank      *float64    `json:"avg_rank"`  // nil when nobody ranked the song
+	RatingsCount int         `json:"rating_count"`
+}
+
+// FriendRanking is a friend's rank of a song
+type FriendRanking struct {
+	UserID    uint64 `json:"user_id"`
+	Username  string `json:"username"`
+	Rank      int    `json:"rank"`
+	UpdatedAt string `json:"updated_at"`
+}
+
+// SongDetail is a song along with how it has been ranked globally, by the user and
+// by the user's friends
+type SongDetail struct {
+	Song
+	Stats           SongRankingStats `json:"stats"`
+	UserRanking     *Rankings        `json:"user_ranking"` // nil when the user hasn't ranked the song
+	FriendsRankings []FriendRanking  `json:"friends_rankings"`
+}
@@ -14,5 +14,23 @@ type User struct {
 	SpotifyDisplayName       *string   `json:"spotify_display_name,omitempty"`
 	SpotifyProfileURI        *string   `json:"spotify_profile_uri,omitempty"`
 	SpotifyProfilePictureURI *string   `json:"spotify_profile_picture_uri,omitempty"`
+	Market                   *string   `json:"market,omitempty"`
+	Locale                   *string   `json:"locale,omitempty"`
 	CreatedAt                time.Time `json:"created_at"`
 }
+
+// SpotifyAccount is the Spotify profile linked to a user
+type SpotifyAccount struct {
+	SpotifyID         string  `json:"spotify_id"`
+	DisplayName       *string `json:"spotify_display_name,omitempty"`
+	ProfileURI        *string `json:"spotify_profile_uri,omitempty"`
+	ProfilePictureURI *string `json:"spotify_profile_picture_uri,omitempty"`
+	Country           *string `json:"country,omitempty"` // becomes the user's market when they haven't picked one
+}
+
+// UserPreferences are the user's Spotify search preferences, nil fields fall back to
+// the defaults
+type UserPreferences struct {
+	Market *string `json:"market"`
+	Locale *string `json:"locale"`
+}
@@ -0,0 +1,31 @@
+package pagination
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+)
+
+// ErrInvalidCursor is returned when a cursor a client sent back can't be decoded
+var ErrInvalidCursor = errors.New("invalid cursor")
+
+// EncodeCursor encodes the state a listing continues from as an opaque, url safe cursor
+func EncodeCursor(state any) (string, error) {
+	raw, err := json.Marshal(state)
+	if err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(raw), nil
+}
+
+// DecodeCursor decodes a cursor made by EncodeCursor into state
+func DecodeCursor(cursor string, state any) error {
+	raw, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return ErrInvalidCursor
+	}
+	if err := json.Unmarshal(raw, state); err != nil {
+		return ErrInvalidCursor
+	}
+	return nil
+}
@@ -0,0 +1,64 @@
+package pagination
+
+import (
+	"encoding/base64"
+	"errors"
+	"strings"
+	"testing"
+)
+
+type testState struct {
+	Query  string `json:"q"`
+	Offset int    `json:"o"`
+}
+
+func TestCursorRoundTrip(t *testing.T) {
+	tests := []testState{
+		{},
+		{Query: "%a%", Offset: 40},
+		{Query: "genre with spaces/and+symbols?&=", Offset: 999},
+	}
+	for _, state := range tests {
+		cursor, err := EncodeCursor(state)
+		if err != nil {
+			t.Fatalf("EncodeCursor(%+v): %v", state, err)
+		}
+		if strings.ContainsAny(cursor, "+/=?&% ") {
+			t.Errorf("EncodeCursor(%+v) = %q, not url safe", state, cursor)
+		}
+
+		var decoded testState
+		if err := DecodeCursor(cursor, &decoded); err != nil {
+			t.Fatalf("DecodeCursor(%q): %v", cursor, err)
+		}
+		if decoded != state {
+			t.Errorf("DecodeCursor(EncodeCursor(%+v)) = %+v", state, decoded)
+		}
+	}
+}
+
+func TestDecodeCursorRejects(t *testing.T) {
+	tests := []struct {
+		name   string
+		cursor string
+	}{
+		{name: "not base64", cursor: "not a cursor!"},
+		{name: "padded base64", cursor: base64.URLEncoding.EncodeToString([]byte(`{"q":"%a%"}`))},
+		{name: "not json", cursor: base64.RawURLEncoding.EncodeToString([]byte("offset=10"))},
+		{name: "wrong types", cursor: base64.RawURLEncoding.EncodeToString([]byte(`{"o":"ten"}`))},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var state testState
+			if err := DecodeCursor(tt.cursor, &state); !errors.Is(err, ErrInvalidCursor) {
+				t.Errorf("DecodeCursor(%q) error = %v, want %v", tt.cursor, err, ErrInvalidCursor)
+			}
+		})
+	}
+}
+
+func TestEncodeCursorError(t *testing.T) {
+	if _, err := EncodeCursor(func() {}); err == nil {
+		t.Error("EncodeCursor of a value json can't encode should fail")
+	}
+}
@@ -0,0 +1,38 @@
+package rating
+
+import "math"
+
+const (
+	// InitialRating is the rating a song starts at when its absolute rank is 3.
+	InitialRating float64 = 1500
+	// RankStep spreads the initial ratings by the absolute 1-5 rank so that the
+	// pairwise ordering starts from what the user already told us:
+	// InitialRating + (rank-3)*RankStep.
+	RankStep float64 = 100
+)
+
+// KFactor shrinks as a song accumulates comparisons, so the first few
+// head-to-heads move it quickly and later ones only fine tune the order.
+func KFactor(comparisons int) float64 {
+	k := 40 - 2*float64(comparisons)
+	if k < 16 {
+		return 16
+	}
+	return k
+}
+
+// expectedScore is the probability of a beating b under the Elo model.
+func expectedScore(a, b float64) float64 {
+	return 1 / (1 + math.Pow(10, (b-a)/400))
+}
+
+// Elo returns the new ratings of the winner and the loser of a comparison.
+// Each side uses its own K factor based on how many comparisons it has played.
+func Elo(winner, loser float64, winnerComparisons, loserComparisons int) (float64, float64) {
+	expectedWinner := expectedScore(winner, loser)
+	expectedLoser := 1 - expectedWinner
+
+	newWinner := winner + KFactor(winnerComparisons)*(1-expectedWinner)
+	newLoser := loser + KFactor(loserComparisons)*(0-expectedLoser)
+	return newWinner, newLoser
+}
@@ -0,0 +1,77 @@
+package rating
+
+import (
+	"math"
+	"testing"
+)
+
+func TestKFactor(t *testing.T) {
+	tests := []struct {
+		comparisons int
+		want        float64
+	}{
+		{comparisons: 0, want: 40},
+		{comparisons: 1, want: 38},
+		{comparisons: 5, want: 30},
+		{comparisons: 12, want: 16},
+		{comparisons: 13, want: 16},
+		{comparisons: 100, want: 16},
+	}
+	for _, tt := range tests {
+		if got := KFactor(tt.comparisons); got != tt.want {
+			t.Errorf("KFactor(%d) = %v, want %v", tt.comparisons, got, tt.want)
+		}
+	}
+}
+
+func TestElo(t *testing.T) {
+	tests := []struct {
+		name                                string
+		winner, loser                       float64
+		winnerComparisons, loserComparisons int
+		wantWinner, wantLoser               float64
+	}{
+		{
+			name:   "even ratings split the K factor",
+			winner: 1500, loser: 1500,
+			wantWinner: 1520, wantLoser: 1480,
+		},
+		{
+			name:   "each side uses its own K factor",
+			winner: 1500, loser: 1500,
+			winnerComparisons: 12, loserComparisons: 0,
+			wantWinner: 1508, wantLoser: 1480,
+		},
+		{
+			name:   "expected win barely moves the ratings",
+			winner: 1900, loser: 1500,
+			wantWinner: 1903.6364, wantLoser: 1496.3636,
+		},
+		{
+			name:   "upset moves the ratings the most",
+			winner: 1500, loser: 1900,
+			wantWinner: 1536.3636, wantLoser: 1863.6364,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			gotWinner, gotLoser := Elo(tt.winner, tt.loser, tt.winnerComparisons, tt.loserComparisons)
+			if math.Abs(gotWinner-tt.wantWinner) > 1e-3 || math.Abs(gotLoser-tt.wantLoser) > 1e-3 {
+				t.Errorf("Elo(%v, %v) = (%v, %v), want (%v, %v)",
+					tt.winner, tt.loser, gotWinner, gotLoser, tt.wantWinner, tt.wantLoser)
+			}
+		})
+	}
+}
+
+func TestEloConservesRatingWithEqualK(t *testing.T) {
+	for _, ratings := range [][2]float64{{1500, 1500}, {1700, 1300}, {1300, 1700}} {
+		winner, loser := Elo(ratings[0], ratings[1], 3, 3)
+		if math.Abs((winner+loser)-(ratings[0]+ratings[1])) > 1e-9 {
+			t.Errorf("Elo(%v, %v) changed the total rating to %v", ratings[0], ratings[1], winner+loser)
+		}
+		if winner <= ratings[0] || loser >= ratings[1] {
+			t.Errorf("Elo(%v, %v) = (%v, %v), the winner should gain and the loser lose", ratings[0], ratings[1], winner, loser)
+		}
+	}
+}
@@ -0,0 +1,117 @@
+package rating
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+)
+
+// Prior summarizes the global ranking distribution, it is what we believe about a
+// song before it has any ratings.
+type Prior struct {
+	Mean   float64 `json:"mean"`   // average rank across every ranking
+	Weight float64 `json:"weight"` // average number of ratings per song
+}
+
+// Scorer turns the average rank and the number of ratings of a song into the score
+// charts are ordered by. SQL returns the same score as a SQL expression over the
+// given column expressions, so charts can be ordered and paged by the database.
+type Scorer interface {
+	Name() string
+	Score(avgRank float64, count int, prior Prior) float64
+	SQL(avgRank string, count string, priorMean string, priorWeight string) string
+}
+
+const DefaultScorer = "bayesian"
+
+var scorers = map[string]Scorer{
+	"mean":     Mean{},
+	"bayesian": Bayesian{},
+	"wilson":   Wilson{Z: 1.96},
+}
+
+// ScorerByName returns the scorer registered under name, an empty name returns the
+// default scorer.
+func ScorerByName(name string) (Scorer, bool) {
+	if name == "" {
+		name = DefaultScorer
+	}
+	scorer, ok := scorers[name]
+	return scorer, ok
+}
+
+// Confidence is the share of the score that comes from the song's own ratings rather
+// than from the prior, from 0 (no ratings) towards 1 (many more ratings than usual).
+func Confidence(count int, prior Prior) float64 {
+	n := float64(count)
+	if n+prior.Weight == 0 {
+		return 0
+	}
+	return n / (n + prior.Weight)
+}
+
+// Mean scores a song by its plain average rank
+type Mean struct{}
+
+func (Mean) Name() string { return "mean" }
+
+func (Mean) Score(avgRank float64, count int, prior Prior) float64 {
+	return avgRank
+}
+
+func (Mean) SQL(avgRank string, count string, priorMean string, priorWeight string) string {
+	return avgRank
+}
+
+// Bayesian shrinks the average rank towards the global mean, as if every song had
+// prior.Weight extra ratings of prior.Mean. Songs with few ratings stay close to the
+// global mean until they earn more.
+type Bayesian struct{}
+
+func (Bayesian) Name() string { return "bayesian" }
+
+func (Bayesian) Score(avgRank float64, count int, prior Prior) float64 {
+	n := float64(count)
+	if n+prior.Weight == 0 {
+		return prior.Mean
+	}
+	return (prior.Weight*prior.Mean + n*avgRank) / (prior.Weight + n)
+}
+
+func (Bayesian) SQL(avgRank string, count string, priorMean string, priorWeight string) string {
+	return fmt.Sprintf(
+		"CASE WHEN %[2]s + %[4]s = 0 THEN %[3]s ELSE (%[4]s * %[3]s + %[2]s * %[1]s) / (%[4]s + %[2]s) END",
+		avgRank, count, priorMean, priorWeight,
+	)
+}
+
+// Wilson scores a song by the lower bound of the Wilson score interval, treating a
+// rank of r as (r-1)/4 of a positive vote. The result is mapped back to the 1-5 scale.
+type Wilson struct {
+	Z float64 // 1.96 for a 95% confidence interval
+}
+
+func (Wilson) Name() string { return "wilson" }
+
+func (w Wilson) Score(avgRank float64, count int, prior Prior) float64 {
+	if count == 0 {
+		return 1
+	}
+	n := float64(count)
+	p := (avgRank - 1) / 4
+	z2 := w.Z * w.Z
+
+	lower := (p + z2/(2*n) - w.Z*math.Sqrt((p*(1-p)+z2/(4*n))/n)) / (1 + z2/n)
+	return 1 + 4*lower
+}
+
+func (w Wilson) SQL(avgRank string, count string, priorMean string, priorWeight string) string {
+	z := strconv.FormatFloat(w.Z, 'g', -1, 64)
+	z2 := strconv.FormatFloat(w.Z*w.Z, 'g', -1, 64)
+	p := fmt.Sprintf("((%s - 1) / 4.0)", avgRank)
+	n := fmt.Sprintf("(%s)::float8", count)
+	return fmt.Sprintf(
+		"CASE WHEN %[2]s = 0 THEN 1 ELSE 1 + 4 * ((%[1]s + %[4]s / (2 * %[2]s) - %[3]s * sqrt((%[1]s * (1 - %[1]s) + %[4]s / (4 * %[2]s)) / %[2]s)) / (1 + %[4]s / %[2]s)) END",
+		p, n, z, z2,
+	)
+}
@@ -0,0 +1,133 @@
+package rating
+
+import (
+	"math"
+	"strings"
+	"testing"
+)
+
+func TestScorerByName(t *testing.T) {
+	tests := []struct {
+		name   string
+		want   string
+		wantOK bool
+	}{
+		{name: "", want: DefaultScorer, wantOK: true},
+		{name: "mean", want: "mean", wantOK: true},
+		{name: "bayesian", want: "bayesian", wantOK: true},
+		{name: "wilson", want: "wilson", wantOK: true},
+		{name: "median", wantOK: false},
+	}
+	for _, tt := range tests {
+		scorer, ok := ScorerByName(tt.name)
+		if ok != tt.wantOK {
+			t.Fatalf("ScorerByName(%q) ok = %v, want %v", tt.name, ok, tt.wantOK)
+		}
+		if ok && scorer.Name() != tt.want {
+			t.Errorf("ScorerByName(%q) = %q, want %q", tt.name, scorer.Name(), tt.want)
+		}
+	}
+}
+
+func TestConfidence(t *testing.T) {
+	tests := []struct {
+		count int
+		prior Prior
+		want  float64
+	}{
+		{count: 0, prior: Prior{Mean: 3, Weight: 10}, want: 0},
+		{count: 10, prior: Prior{Mean: 3, Weight: 10}, want: 0.5},
+		{count: 30, prior: Prior{Mean: 3, Weight: 10}, want: 0.75},
+		{count: 0, prior: Prior{}, want: 0},
+		{count: 4, prior: Prior{}, want: 1},
+	}
+	for _, tt := range tests {
+		if got := Confidence(tt.count, tt.prior); math.Abs(got-tt.want) > 1e-9 {
+			t.Errorf("Confidence(%d, %+v) = %v, want %v", tt.count, tt.prior, got, tt.want)
+		}
+	}
+}
+
+func TestBayesianScore(t *testing.T) {
+	tests := []struct {
+		name    string
+		avgRank float64
+		count   int
+		prior   Prior
+		want    float64
+	}{
+		{name: "no ratings is the prior mean", avgRank: 0, count: 0, prior: Prior{Mean: 3.2, Weight: 10}, want: 3.2},
+		{name: "empty prior is the prior mean", avgRank: 0, count: 0, prior: Prior{Mean: 3.2}, want: 3.2},
+		{name: "as many ratings as the prior weight meet halfway", avgRank: 5, count: 10, prior: Prior{Mean: 3, Weight: 10}, want: 4},
+		{name: "few ratings stay close to the prior", avgRank: 5, count: 1, prior: Prior{Mean: 3, Weight: 9}, want: 3.2},
+		{name: "no prior weight is the plain average", avgRank: 4.5, count: 2, prior: Prior{Mean: 3}, want: 4.5},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := (Bayesian{}).Score(tt.avgRank, tt.count, tt.prior); math.Abs(got-tt.want) > 1e-9 {
+				t.Errorf("Score(%v, %d, %+v) = %v, want %v", tt.avgRank, tt.count, tt.prior, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestWilsonScore(t *testing.T) {
+	wilson := Wilson{Z: 1.96}
+	tests := []struct {
+		name    string
+		avgRank float64
+		count   int
+		want    float64
+	}{
+		{name: "no ratings is the lowest score", avgRank: 0, count: 0, want: 1},
+		{name: "all ones is the lowest score", avgRank: 1, count: 10, want: 1},
+		{name: "all fives is bounded by the interval", avgRank: 5, count: 10, want: 1 + 4/(1+1.96*1.96/10)},
+		{name: "a single five is far from the top", avgRank: 5, count: 1, want: 1 + 4/(1+1.96*1.96)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := wilson.Score(tt.avgRank, tt.count, Prior{}); math.Abs(got-tt.want) > 1e-9 {
+				t.Errorf("Score(%v, %d) = %v, want %v", tt.avgRank, tt.count, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestWilsonScoreRewardsMoreRatings(t *testing.T) {
+	wilson := Wilson{Z: 1.96}
+	for _, avgRank := range []float64{2, 3.5, 4.8} {
+		previous := wilson.Score(avgRank, 1, Prior{})
+		for _, count := range []int{2, 10, 100, 1000} {
+			score := wilson.Score(avgRank, count, Prior{})
+			if score <= previous {
+				t.Errorf("Score(%v, %d) = %v, not above the %v of fewer ratings", avgRank, count, score, previous)
+			}
+			if score > avgRank {
+				t.Errorf("Score(%v, %d) = %v, above the average rank", avgRank, count, score)
+			}
+			previous = score
+		}
+	}
+}
+
+func TestScorerSQL(t *testing.T) {
+	tests := []struct {
+		scorer Scorer
+		want   []string
+	}{
+		{scorer: Mean{}, want: []string{"a.avg_rank"}},
+		{scorer: Bayesian{}, want: []string{"a.avg_rank", "a.ratings_count", "p.mean", "p.weight"}},
+		{scorer: Wilson{Z: 1.96}, want: []string{"a.avg_rank", "a.ratings_count", "1.96", "3.841"}},
+	}
+	for _, tt := range tests {
+		sql := tt.scorer.SQL("a.avg_rank", "a.ratings_count", "p.mean", "p.weight")
+		for _, want := range tt.want {
+			if !strings.Contains(sql, want) {
+				t.Errorf("%s SQL %q doesn't use %q", tt.scorer.Name(), sql, want)
+			}
+		}
+		if strings.Count(sql, "(") != strings.Count(sql, ")") {
+			t.Errorf("%s SQL %q has unbalanced parentheses", tt.scorer.Name(), sql)
+		}
+	}
+}
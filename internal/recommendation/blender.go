@@ -0,0 +1,117 @@
+package recommendation
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+
+	"github.com/ranktify/ranktify-be/internal/model"
+)
+
+// WeightedStrategy is a strategy along with its share of the recommendations
+type WeightedStrategy struct {
+	Strategy Strategy
+	Weight   float64
+}
+
+// Cursor holds the state of the strategies that have more to recommend, keyed by name
+type Cursor map[string]json.RawMessage
+
+// Blender mixes the recommendations of its strategies, each gets a share of the page
+// proportional to its weight and the strategies that run out leave their share to
+// the others
+type Blender struct {
+	Strategies []WeightedStrategy
+	// Filters apply to the candidates of every strategy, after the strategy's own
+	Filters []Filter
+}
+
+func NewBlender(filters []Filter, strategies ...WeightedStrategy) *Blender {
+	return &Blender{Strategies: strategies, Filters: filters}
+}
+
+// Blend builds a page of up to req.Limit recommendations, continuing from cursor
+// (nil on the first page). The returned cursor is nil once every strategy ran out.
+func (b *Blender) Blend(ctx context.Context, req Request, cursor Cursor) ([]model.Recommendation, Cursor, error) {
+	next := Cursor{}
+	candidates := make([][]Candidate, len(b.Strategies))
+	for i, weighted := range b.Strategies {
+		name := weighted.Strategy.Name()
+		state, ok := cursor[name]
+		if cursor != nil && !ok {
+			// the strategy ran out on a previous page
+			continue
+		}
+
+		strategyCandidates, nextState, err := b.candidates(ctx, req, weighted.Strategy, state)
+		if err != nil {
+			return nil, nil, fmt.Errorf("%s recommendations: %w", name, err)
+		}
+		candidates[i] = strategyCandidates
+		if nextState != nil {
+			next[name] = nextState
+		}
+	}
+	if len(next) == 0 {
+		next = nil
+	}
+
+	recommendations := make([]model.Recommendation, 0, req.Limit)
+	taken := make([]int, len(b.Strategies))
+	seen := make(map[string]bool)
+	for len(recommendations) < req.Limit {
+		// the strategy furthest behind its share goes next
+		pick := -1
+		for i, weighted := range b.Strategies {
+			if len(candidates[i]) == 0 || weighted.Weight <= 0 {
+				continue
+			}
+			if pick == -1 || float64(taken[i]+1)/weighted.Weight < float64(taken[pick]+1)/b.Strategies[pick].Weight {
+				pick = i
+			}
+		}
+		if pick == -1 {
+			break
+		}
+
+		candidate := candidates[pick][0]
+		candidates[pick] = candidates[pick][1:]
+		if seen[candidate.Song.SpotifyID] {
+			continue
+		}
+		seen[candidate.Song.SpotifyID] = true
+		taken[pick]++
+		recommendations = append(recommendations, model.Recommendation{
+			Song:      candidate.Song,
+			Source:    b.Strategies[pick].Strategy.Name(),
+			Score:     candidate.Score,
+			Reason:    candidate.Reason,
+			BecauseOf: candidate.BecauseOf,
+		})
+	}
+	return recommendations, next, nil
+}
+
+// candidates runs the strategy's steps and the blender's filters, best scored first
+func (b *Blender) candidates(ctx context.Context, req Request, strategy Strategy, state json.RawMessage) ([]Candidate, json.RawMessage, error) {
+	candidates, nextState, err := strategy.Generate(ctx, req, state)
+	if err != nil {
+		return nil, nil, err
+	}
+	if candidates, err = strategy.Filter(ctx, req, candidates); err != nil {
+		return nil, nil, err
+	}
+	for _, filter := range b.Filters {
+		if candidates, err = filter.Filter(ctx, req, candidates); err != nil {
+			return nil, nil, err
+		}
+	}
+	if err := strategy.Score(ctx, req, candidates); err != nil {
+		return nil, nil, err
+	}
+	sort.SliceStable(candidates, func(i, j int) bool {
+		return candidates[i].Score > candidates[j].Score
+	})
+	return candidates, nextState, nil
+}
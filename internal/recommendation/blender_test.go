@@ -0,0 +1,219 @@
+package recommendation
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"strconv"
+	"testing"
+
+	"github.com/ranktify/ranktify-be/internal/model"
+)
+
+// stubStrategy proposes pages of its songs, the state is the offset of the next page
+type stubStrategy struct {
+	name     string
+	songs    []string
+	pageSize int
+	err      error
+}
+
+func (s *stubStrategy) Name() string { return s.name }
+
+func (s *stubStrategy) Generate(ctx context.Context, req Request, state json.RawMessage) ([]Candidate, json.RawMessage, error) {
+	if s.err != nil {
+		return nil, nil, s.err
+	}
+	offset := 0
+	if state != nil {
+		if err := json.Unmarshal(state, &offset); err != nil {
+			return nil, nil, err
+		}
+	}
+	end := min(offset+s.pageSize, len(s.songs))
+	var candidates []Candidate
+	for i, id := range s.songs[offset:end] {
+		// earlier songs score higher, shuffled here to check the blender sorts them
+		candidates = append([]Candidate{{Song: model.Song{SpotifyID: id}, friendRank: len(s.songs) - offset - i}}, candidates...)
+	}
+	if end == len(s.songs) {
+		return candidates, nil, nil
+	}
+	return candidates, json.RawMessage(strconv.Itoa(end)), nil
+}
+
+func (s *stubStrategy) Score(ctx context.Context, req Request, candidates []Candidate) error {
+	for i := range candidates {
+		candidates[i].Score = float64(candidates[i].friendRank)
+	}
+	return nil
+}
+
+func (s *stubStrategy) Filter(ctx context.Context, req Request, candidates []Candidate) ([]Candidate, error) {
+	return candidates, nil
+}
+
+// dropFilter drops the given songs
+type dropFilter map[string]bool
+
+func (f dropFilter) Filter(ctx context.Context, req Request, candidates []Candidate) ([]Candidate, error) {
+	kept := candidates[:0]
+	for _, candidate := range candidates {
+		if !f[candidate.Song.SpotifyID] {
+			kept = append(kept, candidate)
+		}
+	}
+	return kept, nil
+}
+
+func songs(prefix string, n int) []string {
+	ids := make([]string, n)
+	for i := range ids {
+		ids[i] = fmt.Sprintf("%s%d", prefix, i)
+	}
+	return ids
+}
+
+func sources(recommendations []model.Recommendation) []string {
+	var got []string
+	for _, recommendation := range recommendations {
+		got = append(got, recommendation.Source+":"+recommendation.Song.SpotifyID)
+	}
+	return got
+}
+
+func TestBlend(t *testing.T) {
+	tests := []struct {
+		name       string
+		strategies []WeightedStrategy
+		filters    []Filter
+		limit      int
+		want       []string
+		wantNext   []string
+	}{
+		{
+			name: "shares follow the weights",
+			strategies: []WeightedStrategy{
+				{Strategy: &stubStrategy{name: "a", songs: songs("a", 10), pageSize: 10}, Weight: 3},
+				{Strategy: &stubStrategy{name: "b", songs: songs("b", 10), pageSize: 10}, Weight: 1},
+			},
+			limit: 4,
+			want:  []string{"a:a0", "a:a1", "a:a2", "b:b0"},
+		},
+		{
+			name: "a strategy that runs out leaves its share to the others",
+			strategies: []WeightedStrategy{
+				{Strategy: &stubStrategy{name: "a", songs: songs("a", 1), pageSize: 10}, Weight: 1},
+				{Strategy: &stubStrategy{name: "b", songs: songs("b", 10), pageSize: 10}, Weight: 1},
+			},
+			limit: 4,
+			want:  []string{"a:a0", "b:b0", "b:b1", "b:b2"},
+		},
+		{
+			name: "songs proposed twice are recommended once",
+			strategies: []WeightedStrategy{
+				{Strategy: &stubStrategy{name: "a", songs: []string{"x", "y"}, pageSize: 10}, Weight: 1},
+				{Strategy: &stubStrategy{name: "b", songs: []string{"x", "z"}, pageSize: 10}, Weight: 1},
+			},
+			limit: 4,
+			want:  []string{"a:x", "b:z", "a:y"},
+		},
+		{
+			name: "zero weight strategies are left out",
+			strategies: []WeightedStrategy{
+				{Strategy: &stubStrategy{name: "a", songs: songs("a", 3), pageSize: 10}, Weight: 0},
+				{Strategy: &stubStrategy{name: "b", songs: songs("b", 3), pageSize: 10}, Weight: 1},
+			},
+			limit: 5,
+			want:  []string{"b:b0", "b:b1", "b:b2"},
+		},
+		{
+			name: "filters apply to every strategy",
+			strategies: []WeightedStrategy{
+				{Strategy: &stubStrategy{name: "a", songs: songs("a", 3), pageSize: 10}, Weight: 1},
+				{Strategy: &stubStrategy{name: "b", songs: songs("b", 3), pageSize: 10}, Weight: 1},
+			},
+			filters: []Filter{dropFilter{"a0": true, "b1": true}},
+			limit:   4,
+			want:    []string{"a:a1", "b:b0", "a:a2", "b:b2"},
+		},
+		{
+			name: "strategies with more pages are in the cursor",
+			strategies: []WeightedStrategy{
+				{Strategy: &stubStrategy{name: "a", songs: songs("a", 5), pageSize: 2}, Weight: 1},
+				{Strategy: &stubStrategy{name: "b", songs: songs("b", 2), pageSize: 2}, Weight: 1},
+			},
+			limit:    2,
+			want:     []string{"a:a0", "b:b0"},
+			wantNext: []string{"a"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			blender := NewBlender(tt.filters, tt.strategies...)
+			recommendations, next, err := blender.Blend(context.Background(), Request{Limit: tt.limit}, nil)
+			if err != nil {
+				t.Fatalf("Blend: %v", err)
+			}
+			if got := sources(recommendations); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Blend = %v, want %v", got, tt.want)
+			}
+
+			var gotNext []string
+			for name := range next {
+				gotNext = append(gotNext, name)
+			}
+			if !reflect.DeepEqual(gotNext, tt.wantNext) {
+				t.Errorf("next cursor has %v, want %v", gotNext, tt.wantNext)
+			}
+		})
+	}
+}
+
+func TestBlendPagesThroughCursor(t *testing.T) {
+	blender := NewBlender(nil,
+		WeightedStrategy{Strategy: &stubStrategy{name: "a", songs: songs("a", 5), pageSize: 2}, Weight: 1},
+		WeightedStrategy{Strategy: &stubStrategy{name: "b", songs: songs("b", 3), pageSize: 2}, Weight: 1},
+	)
+
+	var got []string
+	var cursor Cursor
+	for pages := 0; ; pages++ {
+		if pages > 5 {
+			t.Fatal("the cursor never ran out")
+		}
+		recommendations, next, err := blender.Blend(context.Background(), Request{Limit: 4}, cursor)
+		if err != nil {
+			t.Fatalf("Blend: %v", err)
+		}
+		got = append(got, sources(recommendations)...)
+		if next == nil {
+			break
+		}
+		cursor = next
+	}
+
+	want := []string{
+		"a:a0", "b:b0", "a:a1", "b:b1",
+		"a:a2", "b:b2", "a:a3",
+		"a:a4",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("pages = %v, want %v", got, want)
+	}
+}
+
+func TestBlendStrategyError(t *testing.T) {
+	failure := errors.New("spotify is down")
+	blender := NewBlender(nil,
+		WeightedStrategy{Strategy: &stubStrategy{name: "a", songs: songs("a", 3), pageSize: 3}, Weight: 1},
+		WeightedStrategy{Strategy: &stubStrategy{name: "b", err: failure}, Weight: 1},
+	)
+
+	recommendations, next, err := blender.Blend(context.Background(), Request{Limit: 4}, nil)
+	if !errors.Is(err, failure) || recommendations != nil || next != nil {
+		t.Errorf("Blend = (%v, %v, %v), want the strategy's error", recommendations, next, err)
+	}
+}
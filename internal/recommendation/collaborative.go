@@ -0,0 +1,95 @@
+package recommendation
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"github.com/ranktify/ranktify-be/internal/dao"
+	"github.com/ranktify/ranktify-be/internal/model"
+	"github.com/ranktify/ranktify-be/internal/pagination"
+)
+
+// the songs a user ranked at least this high are the ones similar songs are found for
+const minLikedRank = 4
+
+// Collaborative recommends the songs most similar to the ones the user ranked 4 or 5,
+// songs are similar when the users that ranked both ranked them alike. The similarities
+// are rebuilt periodically by scripts/song_similarities.
+type Collaborative struct {
+	SimilaritiesDAO *dao.SongSimilaritiesDAO
+}
+
+func NewCollaborative(similaritiesDAO *dao.SongSimilaritiesDAO) *Collaborative {
+	return &Collaborative{SimilaritiesDAO: similaritiesDAO}
+}
+
+// where Collaborative continues, the similar songs are paged by offset
+type collaborativeState struct {
+	Offset int `json:"o"`
+}
+
+func (s *Collaborative) Name() string {
+	return "similar"
+}
+
+func (s *Collaborative) Generate(ctx context.Context, req Request, rawState json.RawMessage) ([]Candidate, json.RawMessage, error) {
+	var state collaborativeState
+	if rawState != nil {
+		if err := json.Unmarshal(rawState, &state); err != nil || state.Offset < 0 {
+			return nil, nil, pagination.ErrInvalidCursor
+		}
+	}
+
+	songs, err := s.SimilaritiesDAO.GetSimilarUnrankedSongs(ctx, req.UserID, minLikedRank, req.Limit, state.Offset)
+	if err != nil {
+		return nil, nil, err
+	}
+	candidates := make([]Candidate, len(songs))
+	for i, song := range songs {
+		becauseOf := song.BecauseOf
+		candidates[i] = Candidate{
+			Song:      song.Song,
+			Score:     song.Score,
+			Reason:    fmt.Sprintf("Because you ranked %s", songLabel(becauseOf)),
+			BecauseOf: &becauseOf,
+		}
+	}
+
+	if len(songs) < req.Limit {
+		return candidates, nil, nil
+	}
+	nextState, err := json.Marshal(collaborativeState{Offset: state.Offset + len(songs)})
+	if err != nil {
+		return nil, nil, err
+	}
+	return candidates, nextState, nil
+}
+
+// Filter keeps every candidate, the similar songs query already leaves out the ranked ones
+func (s *Collaborative) Filter(ctx context.Context, req Request, candidates []Candidate) ([]Candidate, error) {
+	return candidates, nil
+}
+
+// Score scales the similarity scores Generate set so the page's best is 1
+func (s *Collaborative) Score(ctx context.Context, req Request, candidates []Candidate) error {
+	best := 0.0
+	for _, candidate := range candidates {
+		best = max(best, candidate.Score)
+	}
+	if best == 0 {
+		return nil
+	}
+	for i := range candidates {
+		candidates[i].Score /= best
+	}
+	return nil
+}
+
+// songLabel names the song as "Title by Artist", just the title when the artist is unknown
+func songLabel(song model.Song) string {
+	if song.Artist == nil || *song.Artist == "" {
+		return song.Title
+	}
+	return fmt.Sprintf("%s by %s", song.Title, *song.Artist)
+}
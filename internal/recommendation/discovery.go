@@ -0,0 +1,139 @@
+package recommendation
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"math/rand"
+
+	"github.com/ranktify/ranktify-be/internal/dao"
+	"github.com/ranktify/ranktify-be/internal/pagination"
+	"github.com/ranktify/ranktify-be/internal/spotify"
+)
+
+// Discovery recommends what ranktify always has: up to half the page from the songs
+// the user's friends ranked, best ranked first, and the rest picked at random from a
+// random search and a search of a random genre of the user's market
+type Discovery struct {
+	Provider    spotify.MusicProvider
+	RankingsDAO *dao.RankingsDao
+	GenresDAO   *dao.GenresDAO
+}
+
+func NewDiscovery(provider spotify.MusicProvider, rankingsDAO *dao.RankingsDao, genresDAO *dao.GenresDAO) *Discovery {
+	return &Discovery{Provider: provider, RankingsDAO: rankingsDAO, GenresDAO: genresDAO}
+}
+
+// where Discovery continues, a nil search ran out of results
+type discoveryState struct {
+	Random      *spotify.SearchCursor `json:"r,omitempty"`
+	Genre       *spotify.SearchCursor `json:"g,omitempty"`
+	Friends     int                   `json:"f"`
+	FriendsDone bool                  `json:"fd,omitempty"`
+}
+
+func (d *Discovery) Name() string {
+	return "discovery"
+}
+
+func (d *Discovery) Generate(ctx context.Context, req Request, rawState json.RawMessage) ([]Candidate, json.RawMessage, error) {
+	state, err := d.state(ctx, req, rawState)
+	if err != nil {
+		return nil, nil, err
+	}
+	next := discoveryState{Friends: state.Friends, FriendsDone: state.FriendsDone}
+
+	var candidates []Candidate
+	if friendsShare := req.Limit / 2; !state.FriendsDone && friendsShare > 0 {
+		songs, err := d.RankingsDAO.GetFriendsRankedSongsWithNoUserRank(ctx, req.UserID, friendsShare, state.Friends)
+		if err != nil {
+			return nil, nil, err
+		}
+		for _, song := range songs {
+			candidates = append(candidates, Candidate{
+				Song:       song.Song,
+				Reason:     fmt.Sprintf("A friend ranked it %d", song.FriendRank),
+				friendRank: song.FriendRank,
+			})
+		}
+		next.Friends += len(songs)
+		next.FriendsDone = len(songs) < friendsShare
+	}
+
+	// continues a search of the state, skipping the ones that ran out
+	search := func(from *spotify.SearchCursor) (*spotify.SearchCursor, error) {
+		if from == nil {
+			return nil, nil
+		}
+		songs, next, err := spotify.SearchPage(ctx, d.Provider, req.AccessToken, req.Region, *from, req.Limit)
+		reason := "Something new to rank"
+		if from.Genre != "" {
+			reason = fmt.Sprintf("Something new from %s", from.Genre)
+		}
+		for _, song := range songs {
+			candidates = append(candidates, Candidate{Song: song, Reason: reason})
+		}
+		return next, err
+	}
+	if next.Random, err = search(state.Random); err != nil {
+		return nil, nil, err
+	}
+	if next.Genre, err = search(state.Genre); err != nil {
+		return nil, nil, err
+	}
+
+	if next.Random == nil && next.Genre == nil && next.FriendsDone {
+		return candidates, nil, nil
+	}
+	nextState, err := json.Marshal(next)
+	if err != nil {
+		return nil, nil, err
+	}
+	return candidates, nextState, nil
+}
+
+// state reads the state of the previous page, a fresh one on the first page
+func (d *Discovery) state(ctx context.Context, req Request, rawState json.RawMessage) (discoveryState, error) {
+	var state discoveryState
+	if rawState != nil {
+		if err := json.Unmarshal(rawState, &state); err != nil {
+			return state, pagination.ErrInvalidCursor
+		}
+		if (state.Random != nil && (!state.Random.Valid() || state.Random.Genre != "")) ||
+			(state.Genre != nil && !state.Genre.Valid()) || state.Friends < 0 {
+			return state, pagination.ErrInvalidCursor
+		}
+		return state, nil
+	}
+
+	random := spotify.RandomSearch("")
+	state.Random = &random
+	genre, err := d.GenresDAO.GetRandomGenre(ctx, req.Region.Market)
+	if err != nil {
+		return state, err
+	}
+	// without genres in the market the random search fills the page on its own
+	if genre != nil {
+		genreSearch := spotify.RandomSearch(genre.SearchKey)
+		state.Genre = &genreSearch
+	}
+	return state, nil
+}
+
+// Filter keeps every candidate, the blender drops the ranked ones
+func (d *Discovery) Filter(ctx context.Context, req Request, candidates []Candidate) ([]Candidate, error) {
+	return candidates, nil
+}
+
+// Score puts the songs friends ranked first, best ranked first, and shuffles the
+// search results after them
+func (d *Discovery) Score(ctx context.Context, req Request, candidates []Candidate) error {
+	for i := range candidates {
+		if candidates[i].friendRank > 0 {
+			candidates[i].Score = 1 + float64(candidates[i].friendRank)/5
+		} else {
+			candidates[i].Score = rand.Float64()
+		}
+	}
+	return nil
+}
@@ -0,0 +1,83 @@
+package recommendation
+
+import (
+	"context"
+
+	"github.com/ranktify/ranktify-be/internal/dao"
+	"github.com/ranktify/ranktify-be/internal/model"
+)
+
+// RankedFilter drops the songs the user already ranked, looking them all up at once
+type RankedFilter struct {
+	RankingsDAO *dao.RankingsDao
+}
+
+func NewRankedFilter(rankingsDAO *dao.RankingsDao) *RankedFilter {
+	return &RankedFilter{RankingsDAO: rankingsDAO}
+}
+
+func (f *RankedFilter) Filter(ctx context.Context, req Request, candidates []Candidate) ([]Candidate, error) {
+	if len(candidates) == 0 {
+		return candidates, nil
+	}
+	spotifyIDs := make([]string, len(candidates))
+	for i, candidate := range candidates {
+		spotifyIDs[i] = candidate.Song.SpotifyID
+	}
+	ranked, err := f.RankingsDAO.GetRankedSpotifyIDsAmong(ctx, req.UserID, spotifyIDs)
+	if err != nil {
+		return nil, err
+	}
+
+	unranked := candidates[:0]
+	for _, candidate := range candidates {
+		if !ranked[candidate.Song.SpotifyID] {
+			unranked = append(unranked, candidate)
+		}
+	}
+	return unranked, nil
+}
+
+// FeedbackFilter drops the songs the user gave feedback on and the songs of the
+// artists they excluded, matched by artist rather than by the artist's name
+type FeedbackFilter struct {
+	FeedbackDAO *dao.RecommendationFeedbackDAO
+}
+
+func NewFeedbackFilter(feedbackDAO *dao.RecommendationFeedbackDAO) *FeedbackFilter {
+	return &FeedbackFilter{FeedbackDAO: feedbackDAO}
+}
+
+func (f *FeedbackFilter) Filter(ctx context.Context, req Request, candidates []Candidate) ([]Candidate, error) {
+	if len(candidates) == 0 {
+		return candidates, nil
+	}
+	spotifyIDs := make([]string, len(candidates))
+	for i, candidate := range candidates {
+		spotifyIDs[i] = candidate.Song.SpotifyID
+	}
+	// stored songs are matched through their artists in the database, the ones straight
+	// from Spotify by the spotify ids of their artists
+	songs, artists, err := f.FeedbackDAO.GetExclusions(ctx, req.UserID, spotifyIDs)
+	if err != nil {
+		return nil, err
+	}
+
+	kept := candidates[:0]
+	for _, candidate := range candidates {
+		if songs[candidate.Song.SpotifyID] || hasExcludedArtist(candidate.Song, artists) {
+			continue
+		}
+		kept = append(kept, candidate)
+	}
+	return kept, nil
+}
+
+func hasExcludedArtist(song model.Song, artists map[string]bool) bool {
+	for _, artist := range song.Artists {
+		if artists[artist.SpotifyID] {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,44 @@
+package recommendation
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/ranktify/ranktify-be/internal/dao"
+	"github.com/ranktify/ranktify-be/internal/model"
+	"github.com/ranktify/ranktify-be/internal/testutil"
+)
+
+func TestFeedbackFilter(t *testing.T) {
+	db, mock := testutil.MockDB(t)
+	name := "Bad Bunny"
+	candidates := []Candidate{
+		// stored, the database matched it by song or by its artists
+		{Song: model.Song{SongID: 1, SpotifyID: "stored-excluded"}},
+		{Song: model.Song{SongID: 2, SpotifyID: "stored-kept"}},
+		// straight from Spotify, matched by the spotify ids of its artists
+		{Song: model.Song{SpotifyID: "featuring-excluded", Artists: []model.Artist{{SpotifyID: "main"}, {SpotifyID: "excluded-artist"}}}},
+		// another artist sharing the excluded artist's name isn't excluded
+		{Song: model.Song{SpotifyID: "same-name", Artist: &name, Artists: []model.Artist{{SpotifyID: "namesake", Name: name}}}},
+	}
+
+	mock.ExpectQuery(`FROM songs s`).WillReturnRows(sqlmock.NewRows([]string{"spotify_id"}).AddRow("stored-excluded"))
+	mock.ExpectQuery(`JOIN artists a`).WillReturnRows(sqlmock.NewRows([]string{"spotify_id"}).AddRow("excluded-artist"))
+
+	kept, err := NewFeedbackFilter(dao.NewRecommendationFeedbackDAO(db)).Filter(context.Background(), Request{UserID: 1}, candidates)
+	if err != nil {
+		t.Fatalf("Filter: %v", err)
+	}
+	var got []string
+	for _, candidate := range kept {
+		got = append(got, candidate.Song.SpotifyID)
+	}
+	if want := []string{"stored-kept", "same-name"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("kept %v, want %v", got, want)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Error(err)
+	}
+}
@@ -0,0 +1,53 @@
+package recommendation
+
+import (
+	"context"
+	"encoding/json"
+
+	"github.com/ranktify/ranktify-be/internal/model"
+	"github.com/ranktify/ranktify-be/internal/spotify"
+)
+
+// Request is the page of recommendations being built
+type Request struct {
+	UserID      uint64
+	AccessToken string
+	Region      spotify.Region
+	Limit       int
+}
+
+// Candidate is a song a strategy proposes, Score orders the candidates of a strategy,
+// highest first
+type Candidate struct {
+	Song  model.Song
+	Score float64
+	// why the strategy proposes the song, shown to the user
+	Reason string
+	// the ranked song the candidate was proposed for, when there is one
+	BecauseOf *model.Song
+
+	// the highest rank a friend gave the song, set by Discovery on the songs friends ranked
+	friendRank int
+}
+
+// Strategy proposes songs to recommend, in three steps: Generate finds the candidates,
+// Filter drops the ones the strategy shouldn't recommend and Score orders the rest
+type Strategy interface {
+	// Name identifies the strategy in cursors and in the source of its recommendations
+	Name() string
+
+	// Generate proposes the candidates of a page, continuing from state (nil on the
+	// first page). It returns the state of the next page, nil once it has no more.
+	// A state it can't read is reported as pagination.ErrInvalidCursor.
+	Generate(ctx context.Context, req Request, state json.RawMessage) ([]Candidate, json.RawMessage, error)
+
+	// Score sets the score of the candidates the strategy generated
+	Score(ctx context.Context, req Request, candidates []Candidate) error
+
+	Filter
+}
+
+// Filter drops the candidates that shouldn't be recommended
+type Filter interface {
+	Filter(ctx context.Context, req Request, candidates []Candidate) ([]Candidate, error)
+}
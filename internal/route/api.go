@@ -7,11 +7,26 @@ import (
 	"github.com/ranktify/ranktify-be/internal/dao"
 	"github.com/ranktify/ranktify-be/internal/handler"
 	"github.com/ranktify/ranktify-be/internal/middleware"
+	"github.com/ranktify/ranktify-be/internal/service"
+	"github.com/ranktify/ranktify-be/internal/spotify"
 )
 
 func ApiRoutes(router *gin.RouterGroup, db *sql.DB) {
 	tokensHandler := handler.NewTokensHandler(dao.NewTokensDAO(db), dao.NewUserDAO(db))
-	spotifyHandler := handler.NewSpotifyHandler(dao.NewSpotifyDAO(db))
+	provider := spotify.NewClient()
+	spotifyTokens := service.NewSpotifyTokenService(dao.NewSpotifyDAO(db), provider)
+	previews := service.NewPreviewService(dao.NewPreviewCacheDAO(db), provider)
+	spotifyAccounts := service.NewSpotifyAccountService(dao.NewUserDAO(db), dao.NewSpotifyDAO(db), spotifyTokens, provider)
+	rankingQueue := service.NewRankingQueueService(dao.NewRankingQueueDAO(db), dao.NewSongsDAO(db), provider, spotifyTokens)
+	spotifyHandler := handler.NewSpotifyHandler(dao.NewSpotifyDAO(db), provider, spotifyTokens, previews, spotifyAccounts, rankingQueue, service.NewGenresService(dao.NewGenresDAO(db)))
+	rankingQueueHandler := handler.NewRankingQueueHandler(rankingQueue)
+	playlistExportHandler := handler.NewPlaylistExportHandler(service.NewPlaylistExportService(
+		dao.NewPlaylistExportsDAO(db),
+		dao.NewRankingsDAO(db),
+		dao.NewUserDAO(db),
+		provider,
+		spotifyTokens,
+	))
 
 	api := router.Group("/api")
 	{
@@ -22,14 +37,23 @@ func ApiRoutes(router *gin.RouterGroup, db *sql.DB) {
 		// Spotify auth
 		api.Use(middleware.AuthMiddleware())
 		api.POST("/callback", spotifyHandler.AuthCallback)
+		api.POST("/spotify/link", spotifyHandler.AuthCallback)
+		api.DELETE("/spotify/link", spotifyHandler.UnlinkAccount)
+		api.GET("/queue", rankingQueueHandler.GetQueue)
+		api.DELETE("/queue", rankingQueueHandler.ClearQueue)
 		api.POST("/spotify-refresh", spotifyHandler.RefreshAccessToken)
 
 		// spotify routes that need a access token
-		api.Use(middleware.SpotifyTokenMiddleware())
+		api.Use(middleware.SpotifyTokenMiddleware(spotifyTokens))
+		api.Use(middleware.SpotifyRegionMiddleware(service.NewPreferencesService(dao.NewUserDAO(db))))
 		api.GET("/rank", spotifyHandler.GetSongsToRank)
 		api.GET("/random-songs/:limit", spotifyHandler.GetRandomSongsToRank) //Might delete later
 		api.GET("/:genre/:limit", spotifyHandler.GetRandomSongsByGenreToRank)
 		api.GET("/random-genre/:limit", spotifyHandler.GetRandomSongsByRandomGenreToRank) //Might delete later
+		api.POST("/queue/playlist/:playlist_id", rankingQueueHandler.ImportPlaylist)
+		api.POST("/queue/liked", rankingQueueHandler.ImportLikedSongs)
+		api.POST("/queue/recent", rankingQueueHandler.ImportRecentlyPlayed)
+		api.POST("/export/playlist", playlistExportHandler.ExportRankings)
 
 	}
 }
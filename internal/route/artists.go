@@ -0,0 +1,23 @@
+package route
+
+import (
+	"database/sql"
+
+	"github.com/gin-gonic/gin"
+	"github.com/ranktify/ranktify-be/internal/dao"
+	"github.com/ranktify/ranktify-be/internal/handler"
+	"github.com/ranktify/ranktify-be/internal/middleware"
+	"github.com/ranktify/ranktify-be/internal/service"
+)
+
+func ArtistRoutes(group *gin.RouterGroup, db *sql.DB) {
+	artistsService := service.NewArtistsService(dao.NewArtistsDAO(db))
+	artistsHandler := handler.NewArtistsHandler(artistsService)
+
+	artists := group.Group("/artists")
+	{
+		artists.Use(middleware.AuthMiddleware())
+		artists.GET("/:artist_id", artistsHandler.GetArtist)
+		artists.GET("/:artist_id/friends-top", artistsHandler.GetTopArtistSongsAmongFriends)
+	}
+}
@@ -7,11 +7,12 @@ import (
 	"github.com/ranktify/ranktify-be/internal/dao"
 	"github.com/ranktify/ranktify-be/internal/handler"
 	"github.com/ranktify/ranktify-be/internal/middleware"
+	"github.com/ranktify/ranktify-be/internal/service"
 )
 
 func FriendRoutes(group *gin.RouterGroup, db *sql.DB) {
 	friendDAO := dao.NewFriendsDAO(db)
-	friendsHandler := handler.NewFriendHandler(friendDAO)
+	friendsHandler := handler.NewFriendHandler(friendDAO, service.NewFriendsService(friendDAO, dao.NewRankingsDAO(db)))
 
 	friends := group.Group("/friends")
 	{
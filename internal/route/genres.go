@@ -0,0 +1,29 @@
+package route
+
+import (
+	"database/sql"
+
+	"github.com/gin-gonic/gin"
+	"github.com/ranktify/ranktify-be/internal/dao"
+	"github.com/ranktify/ranktify-be/internal/handler"
+	"github.com/ranktify/ranktify-be/internal/middleware"
+	"github.com/ranktify/ranktify-be/internal/service"
+)
+
+func GenreRoutes(group *gin.RouterGroup, db *sql.DB) {
+	genresHandler := handler.NewGenresHandler(service.NewGenresService(dao.NewGenresDAO(db)))
+
+	// public, the catalog is shown before signing up
+	group.GET("/genres", genresHandler.GetGenres)
+
+	admin := group.Group("/admin/genres")
+	{
+		admin.Use(middleware.AuthMiddleware())
+		admin.Use(middleware.RoleMiddleware(dao.NewUserDAO(db), service.RoleAdmin))
+		admin.GET("", genresHandler.GetAllGenres)
+		admin.POST("", genresHandler.CreateGenre)
+		admin.PUT("/:genre_id", genresHandler.UpdateGenre)
+		admin.POST("/:genre_id/enable", genresHandler.EnableGenre)
+		admin.POST("/:genre_id/disable", genresHandler.DisableGenre)
+	}
+}
@@ -14,6 +14,8 @@ func RankingsRoutes(group *gin.RouterGroup, db *sql.DB) {
 	rankingsService := service.NewRankingsService(
 		dao.NewRankingsDAO(db),
 		dao.NewStreaksDAO(db),
+		dao.NewFriendsDAO(db),
+		dao.NewUserDAO(db),
 	)
 	rankingsHandler := handler.NewRankingsHandler(rankingsService)
 
@@ -27,5 +29,13 @@ func RankingsRoutes(group *gin.RouterGroup, db *sql.DB) {
 		rankings.DELETE("/:ranking_id", rankingsHandler.DeleteRanking)
 		rankings.PUT("/:ranking_id/:rank", rankingsHandler.UpdateRanking)
 		rankings.GET("/top-weekly", rankingsHandler.GetTopWeeklyTracks)
+		rankings.GET("/charts", rankingsHandler.GetChart)
+		rankings.GET("/compatibility/:user_id", rankingsHandler.GetCompatibility)
+		// ranking history
+		rankings.GET("/history/:song_id", rankingsHandler.GetRankingHistory)
+		rankings.GET("/friends-changes", rankingsHandler.GetFriendsRankingChanges)
+		// "this or that" pairwise comparisons
+		rankings.GET("/pairwise", rankingsHandler.GetPairwiseComparison)
+		rankings.POST("/pairwise/:winner_id/:loser_id", rankingsHandler.RecordPairwiseComparison)
 	}
 }
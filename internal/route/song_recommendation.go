@@ -7,16 +7,50 @@ import (
 	"github.com/ranktify/ranktify-be/internal/dao"
 	"github.com/ranktify/ranktify-be/internal/handler"
 	"github.com/ranktify/ranktify-be/internal/middleware"
+	"github.com/ranktify/ranktify-be/internal/recommendation"
+	"github.com/ranktify/ranktify-be/internal/service"
+	"github.com/ranktify/ranktify-be/internal/spotify"
 )
 
 func SongRecommendationRoutes(router *gin.RouterGroup, db *sql.DB) {
 	rankingDAO := dao.NewRankingsDAO(db)
-	songRecommendationHandler := handler.NewSongRecommendationHandler(rankingDAO)
+	provider := spotify.NewClient()
+	spotifyTokens := service.NewSpotifyTokenService(dao.NewSpotifyDAO(db), provider)
+	feedbackDAO := dao.NewRecommendationFeedbackDAO(db)
+	// songs similar to the user's favorites fill two thirds of the page while there
+	// are any, discovery the rest
+	blender := recommendation.NewBlender(
+		[]recommendation.Filter{
+			recommendation.NewRankedFilter(rankingDAO),
+			recommendation.NewFeedbackFilter(feedbackDAO),
+		},
+		recommendation.WeightedStrategy{
+			Strategy: recommendation.NewCollaborative(dao.NewSongSimilaritiesDAO(db)),
+			Weight:   2,
+		},
+		recommendation.WeightedStrategy{
+			Strategy: recommendation.NewDiscovery(provider, rankingDAO, dao.NewGenresDAO(db)),
+			Weight:   1,
+		},
+	)
+	songRecommendationHandler := handler.NewSongRecommendationHandler(
+		service.NewRecommendationService(blender, dao.NewSongsDAO(db), spotifyTokens),
+	)
+
+	feedbackHandler := handler.NewRecommendationFeedbackHandler(
+		service.NewRecommendationFeedbackService(feedbackDAO, dao.NewSongsDAO(db)),
+	)
 
 	songRecommendation := router.Group("/song-recommendation")
 	{
 		songRecommendation.Use(middleware.AuthMiddleware())
-		songRecommendation.Use(middleware.SpotifyTokenMiddleware())
+		songRecommendation.GET("/feedback", feedbackHandler.GetFeedback)
+		songRecommendation.POST("/feedback/:song_id", feedbackHandler.SaveFeedback)
+		songRecommendation.DELETE("/feedback/:song_id", feedbackHandler.DeleteFeedback)
+
+		// recommending needs the user's Spotify access
+		songRecommendation.Use(middleware.SpotifyTokenMiddleware(spotifyTokens))
+		songRecommendation.Use(middleware.SpotifyRegionMiddleware(service.NewPreferencesService(dao.NewUserDAO(db))))
 		songRecommendation.GET("/:limit", songRecommendationHandler.SongRecommendation)
 	}
 }
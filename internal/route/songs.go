@@ -0,0 +1,26 @@
+package route
+
+import (
+	"database/sql"
+
+	"github.com/gin-gonic/gin"
+	"github.com/ranktify/ranktify-be/internal/dao"
+	"github.com/ranktify/ranktify-be/internal/handler"
+	"github.com/ranktify/ranktify-be/internal/middleware"
+	"github.com/ranktify/ranktify-be/internal/service"
+)
+
+func SongRoutes(group *gin.RouterGroup, db *sql.DB) {
+	songsService := service.NewSongsService(
+		dao.NewSongsDAO(db),
+		dao.NewRankingsDAO(db),
+	)
+	songsHandler := handler.NewSongsHandler(songsService)
+
+	songs := group.Group("/songs")
+	{
+		songs.Use(middleware.AuthMiddleware())
+		songs.GET("/:song_id", songsHandler.GetSong)
+		songs.GET("/spotify/:spotify_id", songsHandler.GetSongBySpotifyID)
+	}
+}
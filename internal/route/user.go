@@ -8,22 +8,30 @@ import (
 	"github.com/ranktify/ranktify-be/internal/handler"
 	"github.com/ranktify/ranktify-be/internal/middleware"
 	"github.com/ranktify/ranktify-be/internal/service"
+	"github.com/ranktify/ranktify-be/internal/spotify"
 )
 
 func UserRoutes(group *gin.RouterGroup, db *sql.DB) {
+	provider := spotify.NewClient()
+	spotifyTokens := service.NewSpotifyTokenService(dao.NewSpotifyDAO(db), provider)
 	userService := service.NewUserService(
 		dao.NewUserDAO(db),
 		dao.NewTokensDAO(db),
+		service.NewSpotifyAccountService(dao.NewUserDAO(db), dao.NewSpotifyDAO(db), spotifyTokens, provider),
 	)
 	userHandler := handler.NewUserHandler(userService)
+	preferencesHandler := handler.NewPreferencesHandler(service.NewPreferencesService(dao.NewUserDAO(db)))
 
 	users := group.Group("/user")
 	{
 		users.POST("/login", userHandler.ValidateUser)
+		users.POST("/login/spotify", userHandler.LoginWithSpotify)
 		users.POST("/register", userHandler.CreateUser)
 
 		//add authentication to the rest of the routes
 		users.Use(middleware.AuthMiddleware())
+		users.GET("/preferences", preferencesHandler.GetPreferences)
+		users.PUT("/preferences", preferencesHandler.UpdatePreferences)
 		users.GET("/:id", userHandler.GetUserByID)
 		users.GET("/", userHandler.GetAllUsers)
 		users.PUT("/:id", userHandler.UpdateUserByID)
@@ -0,0 +1,246 @@
+// Package secrets encrypts credentials at rest with envelope encryption: every value
+// gets its own AES-256-GCM data key, which is sealed by a key encryption key from
+// config. The id of the key encryption key is stored next to the ciphertext, so keys
+// can be rotated by rewrapping the data keys without touching the values.
+package secrets
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+	"sync"
+)
+
+const (
+	keysEnv  = "TOKEN_ENCRYPTION_KEYS"
+	keyIDEnv = "TOKEN_ENCRYPTION_KEY_ID"
+)
+
+// envelopePrefix versions the stored format
+const envelopePrefix = "v1:"
+
+const dataKeySize = 32
+
+var (
+	ErrUnknownKey      = errors.New("unknown encryption key")
+	ErrMalformedSecret = errors.New("malformed encrypted secret")
+)
+
+// Keyring holds the key encryption keys by id, new secrets are sealed with the current one
+type Keyring struct {
+	keys      map[string]cipher.AEAD
+	currentID string
+}
+
+// NewKeyring builds a keyring from raw AES keys (16, 24 or 32 bytes) keyed by id
+func NewKeyring(keys map[string][]byte, currentID string) (*Keyring, error) {
+	if len(keys) == 0 {
+		return nil, errors.New("no encryption keys configured")
+	}
+	if currentID == "" && len(keys) == 1 {
+		for id := range keys {
+			currentID = id
+		}
+	}
+	if _, ok := keys[currentID]; !ok {
+		return nil, fmt.Errorf("%w: current key %q", ErrUnknownKey, currentID)
+	}
+
+	k := &Keyring{
+		keys:      make(map[string]cipher.AEAD, len(keys)),
+		currentID: currentID,
+	}
+	for id, key := range keys {
+		aead, err := newAEAD(key)
+		if err != nil {
+			return nil, fmt.Errorf("key %q: %w", id, err)
+		}
+		k.keys[id] = aead
+	}
+	return k, nil
+}
+
+// ParseKeyring reads keys in the "id:base64key,id:base64key" format
+func ParseKeyring(rawKeys string, currentID string) (*Keyring, error) {
+	keys := make(map[string][]byte)
+	for _, entry := range strings.Split(rawKeys, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		id, encodedKey, found := strings.Cut(entry, ":")
+		if !found || id == "" {
+			return nil, fmt.Errorf("invalid key entry %q, expected id:base64key", entry)
+		}
+		key, err := base64.StdEncoding.DecodeString(encodedKey)
+		if err != nil {
+			return nil, fmt.Errorf("key %q is not valid base64: %w", id, err)
+		}
+		keys[id] = key
+	}
+	return NewKeyring(keys, currentID)
+}
+
+// LoadKeyring reads the keyring from TOKEN_ENCRYPTION_KEYS and TOKEN_ENCRYPTION_KEY_ID,
+// the id can be left out when there is a single key
+func LoadKeyring() (*Keyring, error) {
+	return ParseKeyring(os.Getenv(keysEnv), os.Getenv(keyIDEnv))
+}
+
+var (
+	defaultKeyring     *Keyring
+	defaultKeyringOnce sync.Once
+)
+
+// DefaultKeyring returns the keyring from the environment, the program can't store
+// credentials safely without it so a bad config is fatal
+func DefaultKeyring() *Keyring {
+	defaultKeyringOnce.Do(func() {
+		keyring, err := LoadKeyring()
+		if err != nil {
+			log.Fatalf("Token encryption keys didn't load, Error: %s", err)
+		}
+		defaultKeyring = keyring
+	})
+	return defaultKeyring
+}
+
+// CurrentKeyID is the id new secrets are sealed with
+func (k *Keyring) CurrentKeyID() string {
+	return k.currentID
+}
+
+// Encrypt seals plaintext under a fresh data key and returns the ciphertext along with
+// the id of the key that wraps the data key
+func (k *Keyring) Encrypt(plaintext string) (string, string, error) {
+	dataKey := make([]byte, dataKeySize)
+	if _, err := rand.Read(dataKey); err != nil {
+		return "", "", err
+	}
+	dataAEAD, err := newAEAD(dataKey)
+	if err != nil {
+		return "", "", err
+	}
+
+	sealedValue, err := seal(dataAEAD, []byte(plaintext), nil)
+	if err != nil {
+		return "", "", err
+	}
+	wrappedKey, err := seal(k.keys[k.currentID], dataKey, []byte(k.currentID))
+	if err != nil {
+		return "", "", err
+	}
+	return encodeEnvelope(wrappedKey, sealedValue), k.currentID, nil
+}
+
+// Decrypt opens a ciphertext produced by Encrypt under the key with the given id
+func (k *Keyring) Decrypt(ciphertext string, keyID string) (string, error) {
+	wrappedKey, sealedValue, err := decodeEnvelope(ciphertext)
+	if err != nil {
+		return "", err
+	}
+	dataKey, err := k.unwrap(wrappedKey, keyID)
+	if err != nil {
+		return "", err
+	}
+	dataAEAD, err := newAEAD(dataKey)
+	if err != nil {
+		return "", err
+	}
+
+	plaintext, err := open(dataAEAD, sealedValue, nil)
+	if err != nil {
+		return "", err
+	}
+	return string(plaintext), nil
+}
+
+// Rewrap moves a ciphertext to the current key, only its data key is re-encrypted
+func (k *Keyring) Rewrap(ciphertext string, keyID string) (string, string, error) {
+	wrappedKey, sealedValue, err := decodeEnvelope(ciphertext)
+	if err != nil {
+		return "", "", err
+	}
+	dataKey, err := k.unwrap(wrappedKey, keyID)
+	if err != nil {
+		return "", "", err
+	}
+
+	rewrapped, err := seal(k.keys[k.currentID], dataKey, []byte(k.currentID))
+	if err != nil {
+		return "", "", err
+	}
+	return encodeEnvelope(rewrapped, sealedValue), k.currentID, nil
+}
+
+func (k *Keyring) unwrap(wrappedKey []byte, keyID string) ([]byte, error) {
+	keyAEAD, ok := k.keys[keyID]
+	if !ok {
+		return nil, fmt.Errorf("%w: %q", ErrUnknownKey, keyID)
+	}
+	// the key id is authenticated, a data key can't be passed off as wrapped by another key
+	return open(keyAEAD, wrappedKey, []byte(keyID))
+}
+
+func newAEAD(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// seal returns nonce || ciphertext
+func seal(aead cipher.AEAD, plaintext []byte, additionalData []byte) ([]byte, error) {
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, err
+	}
+	return aead.Seal(nonce, nonce, plaintext, additionalData), nil
+}
+
+func open(aead cipher.AEAD, sealed []byte, additionalData []byte) ([]byte, error) {
+	if len(sealed) < aead.NonceSize() {
+		return nil, ErrMalformedSecret
+	}
+	nonce, ciphertext := sealed[:aead.NonceSize()], sealed[aead.NonceSize():]
+	plaintext, err := aead.Open(nil, nonce, ciphertext, additionalData)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrMalformedSecret, err)
+	}
+	return plaintext, nil
+}
+
+// v1:<wrapped data key>.<sealed value>, both base64 url encoded
+func encodeEnvelope(wrappedKey []byte, sealedValue []byte) string {
+	return envelopePrefix +
+		base64.RawURLEncoding.EncodeToString(wrappedKey) + "." +
+		base64.RawURLEncoding.EncodeToString(sealedValue)
+}
+
+func decodeEnvelope(ciphertext string) ([]byte, []byte, error) {
+	body, found := strings.CutPrefix(ciphertext, envelopePrefix)
+	if !found {
+		return nil, nil, ErrMalformedSecret
+	}
+	encodedKey, encodedValue, found := strings.Cut(body, ".")
+	if !found {
+		return nil, nil, ErrMalformedSecret
+	}
+
+	wrappedKey, err := base64.RawURLEncoding.DecodeString(encodedKey)
+	if err != nil {
+		return nil, nil, ErrMalformedSecret
+	}
+	sealedValue, err := base64.RawURLEncoding.DecodeString(encodedValue)
+	if err != nil {
+		return nil, nil, ErrMalformedSecret
+	}
+	return wrappedKey, sealedValue, nil
+}
@@ -0,0 +1,197 @@
+package secrets
+
+import (
+	"bytes"
+	"encoding/base64"
+	"errors"
+	"strings"
+	"testing"
+)
+
+var (
+	oldKey = bytes.Repeat([]byte{1}, 32)
+	newKey = bytes.Repeat([]byte{2}, 32)
+)
+
+func mustKeyring(t *testing.T, keys map[string][]byte, currentID string) *Keyring {
+	t.Helper()
+	keyring, err := NewKeyring(keys, currentID)
+	if err != nil {
+		t.Fatalf("NewKeyring: %v", err)
+	}
+	return keyring
+}
+
+func TestNewKeyring(t *testing.T) {
+	tests := []struct {
+		name      string
+		keys      map[string][]byte
+		currentID string
+		wantID    string
+		wantErr   error
+	}{
+		{name: "single key is current", keys: map[string][]byte{"k1": oldKey}, wantID: "k1"},
+		{name: "current picked among many", keys: map[string][]byte{"k1": oldKey, "k2": newKey}, currentID: "k2", wantID: "k2"},
+		{name: "many keys need a current id", keys: map[string][]byte{"k1": oldKey, "k2": newKey}, wantErr: ErrUnknownKey},
+		{name: "unknown current id", keys: map[string][]byte{"k1": oldKey}, currentID: "k9", wantErr: ErrUnknownKey},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			keyring, err := NewKeyring(tt.keys, tt.currentID)
+			if tt.wantErr != nil {
+				if !errors.Is(err, tt.wantErr) {
+					t.Fatalf("NewKeyring error = %v, want %v", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("NewKeyring: %v", err)
+			}
+			if keyring.CurrentKeyID() != tt.wantID {
+				t.Errorf("CurrentKeyID() = %q, want %q", keyring.CurrentKeyID(), tt.wantID)
+			}
+		})
+	}
+
+	if _, err := NewKeyring(map[string][]byte{}, ""); err == nil {
+		t.Error("NewKeyring with no keys should fail")
+	}
+	if _, err := NewKeyring(map[string][]byte{"k1": []byte("short")}, "k1"); err == nil {
+		t.Error("NewKeyring with an invalid key size should fail")
+	}
+}
+
+func TestParseKeyring(t *testing.T) {
+	encodedOld := base64.StdEncoding.EncodeToString(oldKey)
+	encodedNew := base64.StdEncoding.EncodeToString(newKey)
+
+	tests := []struct {
+		name      string
+		rawKeys   string
+		currentID string
+		wantID    string
+		wantErr   bool
+	}{
+		{name: "single key", rawKeys: "k1:" + encodedOld, wantID: "k1"},
+		{name: "spaces and trailing comma", rawKeys: " k1:" + encodedOld + " , k2:" + encodedNew + ",", currentID: "k2", wantID: "k2"},
+		{name: "missing id", rawKeys: ":" + encodedOld, wantErr: true},
+		{name: "missing separator", rawKeys: encodedOld, wantErr: true},
+		{name: "bad base64", rawKeys: "k1:not base64!", wantErr: true},
+		{name: "empty", rawKeys: "", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			keyring, err := ParseKeyring(tt.rawKeys, tt.currentID)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatal("ParseKeyring should fail")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("ParseKeyring: %v", err)
+			}
+			if keyring.CurrentKeyID() != tt.wantID {
+				t.Errorf("CurrentKeyID() = %q, want %q", keyring.CurrentKeyID(), tt.wantID)
+			}
+		})
+	}
+}
+
+func TestEncryptDecrypt(t *testing.T) {
+	keyring := mustKeyring(t, map[string][]byte{"k1": oldKey}, "k1")
+
+	for _, plaintext := range []string{"", "refresh-token", strings.Repeat("x", 1024)} {
+		ciphertext, keyID, err := keyring.Encrypt(plaintext)
+		if err != nil {
+			t.Fatalf("Encrypt: %v", err)
+		}
+		if keyID != "k1" {
+			t.Errorf("Encrypt key id = %q, want k1", keyID)
+		}
+		if !strings.HasPrefix(ciphertext, envelopePrefix) {
+			t.Errorf("ciphertext %q misses the %q prefix", ciphertext, envelopePrefix)
+		}
+		if plaintext != "" && strings.Contains(ciphertext, plaintext) {
+			t.Errorf("ciphertext %q contains the plaintext", ciphertext)
+		}
+
+		decrypted, err := keyring.Decrypt(ciphertext, keyID)
+		if err != nil {
+			t.Fatalf("Decrypt: %v", err)
+		}
+		if decrypted != plaintext {
+			t.Errorf("Decrypt = %q, want %q", decrypted, plaintext)
+		}
+	}
+}
+
+func TestRotation(t *testing.T) {
+	before := mustKeyring(t, map[string][]byte{"k1": oldKey}, "k1")
+	after := mustKeyring(t, map[string][]byte{"k1": oldKey, "k2": newKey}, "k2")
+	retired := mustKeyring(t, map[string][]byte{"k2": newKey}, "k2")
+
+	ciphertext, keyID, err := before.Encrypt("refresh-token")
+	if err != nil {
+		t.Fatalf("Encrypt: %v", err)
+	}
+
+	// secrets sealed before the rotation still open with the old key in the keyring
+	if decrypted, err := after.Decrypt(ciphertext, keyID); err != nil || decrypted != "refresh-token" {
+		t.Fatalf("Decrypt after rotation = %q, %v", decrypted, err)
+	}
+
+	rewrapped, rewrappedID, err := after.Rewrap(ciphertext, keyID)
+	if err != nil {
+		t.Fatalf("Rewrap: %v", err)
+	}
+	if rewrappedID != "k2" {
+		t.Errorf("Rewrap key id = %q, want k2", rewrappedID)
+	}
+	_, sealedBefore, _ := decodeEnvelope(ciphertext)
+	_, sealedAfter, _ := decodeEnvelope(rewrapped)
+	if !bytes.Equal(sealedBefore, sealedAfter) {
+		t.Error("Rewrap re-encrypted the value, only the data key should change")
+	}
+
+	// once rewrapped the old key can be dropped
+	if decrypted, err := retired.Decrypt(rewrapped, rewrappedID); err != nil || decrypted != "refresh-token" {
+		t.Fatalf("Decrypt with the old key retired = %q, %v", decrypted, err)
+	}
+	if _, err := retired.Decrypt(ciphertext, keyID); !errors.Is(err, ErrUnknownKey) {
+		t.Errorf("Decrypt of a secret under a retired key error = %v, want %v", err, ErrUnknownKey)
+	}
+}
+
+func TestDecryptRejects(t *testing.T) {
+	keyring := mustKeyring(t, map[string][]byte{"k1": oldKey, "k2": newKey}, "k1")
+	ciphertext, keyID, err := keyring.Encrypt("refresh-token")
+	if err != nil {
+		t.Fatalf("Encrypt: %v", err)
+	}
+	wrappedKey, sealedValue, _ := decodeEnvelope(ciphertext)
+	tampered := append([]byte(nil), sealedValue...)
+	tampered[len(tampered)-1] ^= 0xff
+
+	tests := []struct {
+		name       string
+		ciphertext string
+		keyID      string
+		wantErr    error
+	}{
+		{name: "unknown key id", ciphertext: ciphertext, keyID: "k9", wantErr: ErrUnknownKey},
+		{name: "another key's id", ciphertext: ciphertext, keyID: "k2", wantErr: ErrMalformedSecret},
+		{name: "missing prefix", ciphertext: strings.TrimPrefix(ciphertext, envelopePrefix), keyID: keyID, wantErr: ErrMalformedSecret},
+		{name: "missing separator", ciphertext: strings.Replace(ciphertext, ".", "", 1), keyID: keyID, wantErr: ErrMalformedSecret},
+		{name: "bad base64", ciphertext: envelopePrefix + "!!.!!", keyID: keyID, wantErr: ErrMalformedSecret},
+		{name: "too short", ciphertext: encodeEnvelope([]byte{1}, sealedValue), keyID: keyID, wantErr: ErrMalformedSecret},
+		{name: "tampered value", ciphertext: encodeEnvelope(wrappedKey, tampered), keyID: keyID, wantErr: ErrMalformedSecret},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := keyring.Decrypt(tt.ciphertext, tt.keyID); !errors.Is(err, tt.wantErr) {
+				t.Errorf("Decrypt error = %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,60 @@
+package service
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"net/http"
+
+	"github.com/ranktify/ranktify-be/internal/dao"
+	"github.com/ranktify/ranktify-be/internal/model"
+)
+
+type ArtistsService struct {
+	ArtistsDAO *dao.ArtistsDAO
+}
+
+func NewArtistsService(artistsDAO *dao.ArtistsDAO) *ArtistsService {
+	return &ArtistsService{ArtistsDAO: artistsDAO}
+}
+
+// GetArtist returns the artist page: the artist, their songs and the average rank
+// across all of them
+func (s *ArtistsService) GetArtist(ctx context.Context, artistID uint64) (int, content) {
+	artist, err := s.ArtistsDAO.GetArtistByID(ctx, artistID)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return http.StatusNotFound, content{"error": fmt.Sprintf("Artist with id %d not found", artistID)}
+		}
+		return http.StatusInternalServerError, content{"error": "Failed to retrieve artist"}
+	}
+	songs, err := s.ArtistsDAO.GetArtistSongs(ctx, artistID)
+	if err != nil {
+		return http.StatusInternalServerError, content{"error": "Failed to retrieve artist songs"}
+	}
+
+	detail := model.ArtistDetail{Artist: *artist, Songs: songs}
+	var total float64
+	for _, song := range songs {
+		if song.AvgRank != nil {
+			total += *song.AvgRank * float64(song.RatingsCount)
+			detail.RatingsCount += song.RatingsCount
+		}
+	}
+	if detail.RatingsCount > 0 {
+		avg := total / float64(detail.RatingsCount)
+		detail.AvgRank = &avg
+	}
+	return http.StatusOK, content{"artist": detail}
+}
+
+func (s *ArtistsService) GetTopArtistSongsAmongFriends(ctx context.Context, userID uint64, artistID uint64) (int, content) {
+	songs, err := s.ArtistsDAO.GetTopArtistSongsAmongFriends(ctx, userID, artistID, 5)
+	if err != nil {
+		return http.StatusInternalServerError, content{"error": "Failed to retrieve top artist songs among friends"}
+	}
+	if len(songs) == 0 {
+		return http.StatusNotFound, content{"error": "No songs of the artist ranked by friends"}
+	}
+	return http.StatusOK, content{"songs": songs}
+}
@@ -0,0 +1,61 @@
+package service
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"net/http"
+
+	"github.com/ranktify/ranktify-be/internal/dao"
+)
+
+// RoleAdmin is the role of the users that manage the catalogs, roles are only granted
+// in the database
+const RoleAdmin = "admin"
+
+// ownerLookup resolves the id of the user that owns a resource
+type ownerLookup func() (uint64, error)
+
+// authorize checks that callerID owns the resource resolved by lookup. When the caller
+// can't act on it ok is false, and the status code and content describe why:
+// 404 if the resource doesn't exist and 403 if it belongs to another user.
+func authorize(callerID uint64, resource string, lookup ownerLookup) (int, content, bool) {
+	ownerID, err := lookup()
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return http.StatusNotFound, content{"error": fmt.Sprintf("%s not found", resource)}, false
+		}
+		return http.StatusInternalServerError, content{"error": fmt.Sprintf("Failed to retrieve %s", resource)}, false
+	}
+	if ownerID != callerID {
+		return http.StatusForbidden, content{"error": fmt.Sprintf("Not allowed to access this %s", resource)}, false
+	}
+	return http.StatusOK, nil, true
+}
+
+// AuthorizeSelf checks that a route taking user ids in its path is acting on behalf of
+// the caller, that is callerID is one of ids. Same return values as authorize.
+func AuthorizeSelf(callerID uint64, ids ...uint64) (int, content, bool) {
+	for _, id := range ids {
+		if id == callerID {
+			return http.StatusOK, nil, true
+		}
+	}
+	return http.StatusForbidden, content{"error": "Not allowed to act on behalf of another user"}, false
+}
+
+// AuthorizeRole checks that the caller has the role, it's read from the database since
+// access tokens don't carry it. Same return values as authorize.
+func AuthorizeRole(ctx context.Context, userDAO *dao.UserDAO, callerID uint64, role string) (int, content, bool) {
+	callerRole, err := userDAO.GetUserRole(ctx, callerID)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return http.StatusNotFound, content{"error": "User not found"}, false
+		}
+		return http.StatusInternalServerError, content{"error": "Failed to retrieve user"}, false
+	}
+	if callerRole == nil || *callerRole != role {
+		return http.StatusForbidden, content{"error": "Not allowed to access this resource"}, false
+	}
+	return http.StatusOK, nil, true
+}
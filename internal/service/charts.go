@@ -0,0 +1,156 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/ranktify/ranktify-be/internal/model"
+	"github.com/ranktify/ranktify-be/internal/rating"
+)
+
+// chart periods, every period but all-time covers the last complete calendar period
+const (
+	PeriodDaily   = "daily"
+	PeriodWeekly  = "weekly"
+	PeriodMonthly = "monthly"
+	PeriodYearly  = "yearly"
+	PeriodAllTime = "all-time"
+)
+
+var errInvalidPeriod = errors.New("invalid chart period")
+
+// chart periods follow Puerto Rico days like streaks do, Puerto Rico doesn't observe
+// daylight saving time
+var chartLocation = time.FixedZone("AST", -4*60*60)
+
+// chartWindow returns the [start, end) window of the last complete period before now
+func chartWindow(period string, now time.Time) (time.Time, time.Time, error) {
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location())
+
+	var start, end time.Time
+	switch period {
+	case PeriodDaily:
+		end = today
+		start = end.AddDate(0, 0, -1)
+	case PeriodWeekly:
+		// (Weekday()+6)%7 maps Monday→0, Tuesday→1, … Sunday→6
+		offset := (int(now.Weekday()) + 6) % 7
+		end = today.AddDate(0, 0, -offset)
+		start = end.AddDate(0, 0, -7)
+	case PeriodMonthly:
+		end = time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, now.Location())
+		start = end.AddDate(0, -1, 0)
+	case PeriodYearly:
+		end = time.Date(now.Year(), time.January, 1, 0, 0, 0, 0, now.Location())
+		start = end.AddDate(-1, 0, 0)
+	default:
+		return time.Time{}, time.Time{}, errInvalidPeriod
+	}
+	return start, end, nil
+}
+
+var errInvalidScoring = errors.New("invalid chart scoring")
+
+// buildChart computes the page of the chart for the period, ordered and paged by the
+// database with the named scorer, comparing each position against the previous period.
+func (s *RankingsService) buildChart(ctx context.Context, period string, scoring string, filter model.ChartFilter, page int, pageSize int) (*model.Chart, error) {
+	scorer, ok := rating.ScorerByName(scoring)
+	if !ok {
+		return nil, errInvalidScoring
+	}
+	prior, err := s.RankingsDAO.GetRankingPrior(ctx)
+	if err != nil {
+		return nil, err
+	}
+	chart := &model.Chart{
+		Period:   period,
+		Scoring:  scorer.Name(),
+		Prior:    prior,
+		Page:     page,
+		PageSize: pageSize,
+	}
+
+	var start, end, previousStart, previousEnd *time.Time
+	if period != PeriodAllTime {
+		currentStart, currentEnd, err := chartWindow(period, time.Now().In(chartLocation))
+		if err != nil {
+			return nil, err
+		}
+		lastStart, lastEnd, _ := chartWindow(period, currentStart)
+		start, end = &currentStart, &currentEnd
+		previousStart, previousEnd = &lastStart, &lastEnd
+		chart.Start, chart.End = start, end
+	}
+
+	from := (page - 1) * pageSize
+	pageAggregates, total, err := s.RankingsDAO.GetChartPage(ctx, start, end, filter, scorer, prior, pageSize, from)
+	if err != nil {
+		return nil, err
+	}
+	chart.Total = total
+	if len(pageAggregates) == 0 {
+		chart.Entries = []model.ChartEntry{}
+		return chart, nil
+	}
+
+	songIDs := make([]uint64, len(pageAggregates))
+	for i, aggregate := range pageAggregates {
+		songIDs[i] = aggregate.SongID
+	}
+	var previousPosition map[uint64]int
+	if period != PeriodAllTime {
+		previousPosition, err = s.RankingsDAO.GetChartPositions(ctx, previousStart, previousEnd, filter, scorer, prior, songIDs)
+		if err != nil {
+			return nil, err
+		}
+	}
+	songs, err := s.RankingsDAO.GetSongsByIDs(ctx, songIDs)
+	if err != nil {
+		return nil, err
+	}
+	songsByID := make(map[uint64]model.Song, len(songs))
+	for _, song := range songs {
+		songsByID[song.SongID] = song
+	}
+
+	chart.Entries = make([]model.ChartEntry, 0, len(pageAggregates))
+	for i, aggregate := range pageAggregates {
+		entry := model.ChartEntry{
+			Song:         songsByID[aggregate.SongID],
+			Position:     from + i + 1,
+			AvgRank:      aggregate.AvgRank,
+			RatingsCount: aggregate.RatingsCount,
+			Score:        aggregate.Score,
+			Confidence:   rating.Confidence(aggregate.RatingsCount, prior),
+		}
+		if previousPosition != nil {
+			if position, ok := previousPosition[aggregate.SongID]; ok {
+				change := position - entry.Position
+				entry.PreviousPosition = &position
+				entry.PositionChange = &change
+			} else {
+				entry.NewEntry = true
+			}
+		}
+		chart.Entries = append(chart.Entries, entry)
+	}
+	return chart, nil
+}
+
+func (s *RankingsService) GetChart(ctx context.Context, period string, scoring string, filter model.ChartFilter, page int, pageSize int) (int, content) {
+	chart, err := s.buildChart(ctx, period, scoring, filter, page, pageSize)
+	if err != nil {
+		if errors.Is(err, errInvalidScoring) {
+			return http.StatusBadRequest, content{"error": fmt.Sprintf("Invalid scoring %q, expected mean, bayesian or wilson", scoring)}
+		}
+		if errors.Is(err, errInvalidPeriod) {
+			return http.StatusBadRequest, content{"error": fmt.Sprintf("Invalid period %q, expected one of %s, %s, %s, %s or %s",
+				period, PeriodDaily, PeriodWeekly, PeriodMonthly, PeriodYearly, PeriodAllTime)}
+		}
+		return http.StatusInternalServerError, content{"error": "Failed to retrieve chart"}
+	}
+	return http.StatusOK, content{"chart": chart}
+}
@@ -0,0 +1,143 @@
+package service
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"math"
+	"net/http"
+	"sort"
+
+	"github.com/ranktify/ranktify-be/internal/model"
+)
+
+const (
+	// minCompatibilityOverlap is how many songs two users must have both ranked
+	// before their compatibility score means anything
+	minCompatibilityOverlap = 5
+	// compatibilityHighlights caps the agreements and disagreements returned
+	compatibilityHighlights = 5
+)
+
+// pearson returns the Pearson correlation of the two users' ranks, ok is false when
+// either user gave every shared song the same rank and the correlation is undefined.
+func pearson(shared []model.SharedRanking) (float64, bool) {
+	n := float64(len(shared))
+	var sumMine, sumTheirs float64
+	for _, ranking := range shared {
+		sumMine += float64(ranking.UserRank)
+		sumTheirs += float64(ranking.OtherRank)
+	}
+	meanMine, meanTheirs := sumMine/n, sumTheirs/n
+
+	var covariance, varianceMine, varianceTheirs float64
+	for _, ranking := range shared {
+		dMine := float64(ranking.UserRank) - meanMine
+		dTheirs := float64(ranking.OtherRank) - meanTheirs
+		covariance += dMine * dTheirs
+		varianceMine += dMine * dMine
+		varianceTheirs += dTheirs * dTheirs
+	}
+	if varianceMine == 0 || varianceTheirs == 0 {
+		return 0, false
+	}
+	return covariance / math.Sqrt(varianceMine*varianceTheirs), true
+}
+
+// agreement maps the mean absolute rank difference onto -1 (always 4 apart) to
+// 1 (always the same rank), used when the correlation is undefined.
+func agreement(shared []model.SharedRanking) float64 {
+	var totalDiff float64
+	for _, ranking := range shared {
+		totalDiff += math.Abs(float64(ranking.UserRank - ranking.OtherRank))
+	}
+	meanDiff := totalDiff / float64(len(shared))
+	return 1 - meanDiff/2
+}
+
+func rankDiff(ranking model.SharedRanking) int {
+	diff := ranking.UserRank - ranking.OtherRank
+	if diff < 0 {
+		return -diff
+	}
+	return diff
+}
+
+// compatibilityHighlightsOf returns the songs both users agree on the most (at most a
+// rank apart, loved songs first) and the ones they disagree on the most.
+func compatibilityHighlightsOf(shared []model.SharedRanking) ([]model.SharedRanking, []model.SharedRanking) {
+	agreements := []model.SharedRanking{}
+	disagreements := []model.SharedRanking{}
+	for _, ranking := range shared {
+		if rankDiff(ranking) <= 1 {
+			agreements = append(agreements, ranking)
+		} else {
+			disagreements = append(disagreements, ranking)
+		}
+	}
+
+	sort.Slice(agreements, func(i, j int) bool {
+		a, b := agreements[i], agreements[j]
+		if rankDiff(a) != rankDiff(b) {
+			return rankDiff(a) < rankDiff(b)
+		}
+		return a.UserRank+a.OtherRank > b.UserRank+b.OtherRank
+	})
+	sort.Slice(disagreements, func(i, j int) bool {
+		return rankDiff(disagreements[i]) > rankDiff(disagreements[j])
+	})
+
+	if len(agreements) > compatibilityHighlights {
+		agreements = agreements[:compatibilityHighlights]
+	}
+	if len(disagreements) > compatibilityHighlights {
+		disagreements = disagreements[:compatibilityHighlights]
+	}
+	return agreements, disagreements
+}
+
+func (s *RankingsService) GetCompatibility(ctx context.Context, userID uint64, otherUserID uint64) (int, content) {
+	if userID == otherUserID {
+		return http.StatusBadRequest, content{"error": "Can't compute the compatibility of a user with themselves"}
+	}
+	if _, err := s.UserDAO.GetUserByID(otherUserID); err != nil {
+		if err == sql.ErrNoRows {
+			return http.StatusNotFound, content{"error": fmt.Sprintf("User with id %d not found", otherUserID)}
+		}
+		return http.StatusInternalServerError, content{"error": "Failed to retrieve user"}
+	}
+	// the agreements reveal the other user's ranks, only friends get to see them
+	friends, err := s.FriendsDAO.AreFriends(ctx, userID, otherUserID)
+	if err != nil {
+		return http.StatusInternalServerError, content{"error": "Failed to retrieve friendship"}
+	}
+	if !friends {
+		return http.StatusForbidden, content{"error": "Compatibility is only available between friends"}
+	}
+	shared, err := s.RankingsDAO.GetSharedRankings(ctx, userID, otherUserID)
+	if err != nil {
+		return http.StatusInternalServerError, content{"error": "Failed to retrieve shared rankings"}
+	}
+
+	compatibility := model.Compatibility{
+		UserID:      userID,
+		OtherUserID: otherUserID,
+		SharedSongs: len(shared),
+		MinOverlap:  minCompatibilityOverlap,
+	}
+	compatibility.Agreements, compatibility.Disagreements = compatibilityHighlightsOf(shared)
+
+	if len(shared) >= minCompatibilityOverlap {
+		score, ok := pearson(shared)
+		compatibility.Method = "pearson"
+		if !ok {
+			score = agreement(shared)
+			compatibility.Method = "agreement"
+		}
+		percentage := (score + 1) * 50
+		compatibility.Score = &score
+		compatibility.Percentage = &percentage
+	}
+
+	return http.StatusOK, content{"compatibility": compatibility}
+}
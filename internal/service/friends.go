@@ -0,0 +1,124 @@
+package service
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/ranktify/ranktify-be/internal/dao"
+	"github.com/ranktify/ranktify-be/internal/model"
+	"github.com/ranktify/ranktify-be/internal/rating"
+)
+
+// how many songs the top tracks among friends has
+const friendsTopTracks = 5
+
+type FriendsService struct {
+	FriendsDAO  *dao.FriendsDAO
+	RankingsDAO *dao.RankingsDao
+}
+
+func NewFriendsService(friendsDAO *dao.FriendsDAO, rankingsDAO *dao.RankingsDao) *FriendsService {
+	return &FriendsService{
+		FriendsDAO:  friendsDAO,
+		RankingsDAO: rankingsDAO,
+	}
+}
+
+// authorizeFriendRequest checks that the friend request exists and that the user is
+// the side of it that owner picks, the receiver to answer it and the sender to cancel it
+func (s *FriendsService) authorizeFriendRequest(userID uint64, requestID uint64, owner func(model.FriendRequests) uint64) (model.FriendRequests, int, content, bool) {
+	var friendRequest model.FriendRequests
+	statusCode, body, ok := authorize(userID, "Friend request", func() (uint64, error) {
+		var err error
+		friendRequest, err = s.FriendsDAO.GetFriendRequestsByRequestID(requestID)
+		if err != nil {
+			return 0, err
+		}
+		return owner(friendRequest), nil
+	})
+	return friendRequest, statusCode, body, ok
+}
+
+func receiverOf(friendRequest model.FriendRequests) uint64 {
+	return friendRequest.ReceiverID
+}
+
+func senderOf(friendRequest model.FriendRequests) uint64 {
+	return friendRequest.SenderID
+}
+
+func (s *FriendsService) AcceptFriendRequest(userID uint64, requestID uint64) (int, content) {
+	friendRequest, statusCode, body, ok := s.authorizeFriendRequest(userID, requestID, receiverOf)
+	if !ok {
+		return statusCode, body
+	}
+	err := s.FriendsDAO.AcceptFriendRequest(friendRequest.SenderID, friendRequest.ReceiverID)
+	if err != nil {
+		return http.StatusInternalServerError, content{"error": "Failed to accept the friend request"}
+	}
+	err = s.FriendsDAO.DeleteFriendRequest(requestID)
+	if err != nil {
+		return http.StatusInternalServerError, content{"error": "Failed to delete the friend request"}
+	}
+	return http.StatusOK, content{"message": "Friend request accepted"}
+}
+
+func (s *FriendsService) DeclineFriendRequest(userID uint64, requestID uint64) (int, content) {
+	if _, statusCode, body, ok := s.authorizeFriendRequest(userID, requestID, receiverOf); !ok {
+		return statusCode, body
+	}
+	err := s.FriendsDAO.DeleteFriendRequest(requestID)
+	if err != nil {
+		return http.StatusInternalServerError, content{"error": "Failed to decline the friend request"}
+	}
+	return http.StatusOK, content{"message": "Friend request declined "}
+}
+
+func (s *FriendsService) CancelFriendRequest(userID uint64, requestID uint64) (int, content) {
+	if _, statusCode, body, ok := s.authorizeFriendRequest(userID, requestID, senderOf); !ok {
+		return statusCode, body
+	}
+	err := s.FriendsDAO.DeleteFriendRequest(requestID)
+	if err != nil {
+		return http.StatusInternalServerError, content{"error": "Failed to cancel friend request"}
+	}
+	return http.StatusOK, content{"message": "Friend request canceled successfully"}
+}
+
+// GetTopTracksAmongFriends returns the songs the user's friends ranked the highest,
+// ordered by scorer like the charts are. Nil when the friends haven't ranked any.
+func (s *FriendsService) GetTopTracksAmongFriends(ctx context.Context, userID uint64, scorer rating.Scorer) ([]model.TopTrack, error) {
+	prior, err := s.RankingsDAO.GetRankingPrior(ctx)
+	if err != nil {
+		return nil, err
+	}
+	aggregates, err := s.FriendsDAO.GetTopTracksRankedByFriends(ctx, userID, scorer, prior, friendsTopTracks)
+	if err != nil || len(aggregates) == 0 {
+		return nil, err
+	}
+
+	songIDs := make([]uint64, len(aggregates))
+	for i, aggregate := range aggregates {
+		songIDs[i] = aggregate.SongID
+	}
+	songs, err := s.RankingsDAO.GetSongsByIDs(ctx, songIDs)
+	if err != nil {
+		return nil, err
+	}
+	songsByID := make(map[uint64]model.Song, len(songs))
+	for _, song := range songs {
+		songsByID[song.SongID] = song
+	}
+
+	topTracks := make([]model.TopTrack, 0, len(aggregates))
+	for _, aggregate := range aggregates {
+		topTracks = append(topTracks, model.TopTrack{
+			Song:         songsByID[aggregate.SongID],
+			AvgRank:      aggregate.AvgRank,
+			RatingsCount: aggregate.RatingsCount,
+			Score:        aggregate.Score,
+			Confidence:   rating.Confidence(aggregate.RatingsCount, prior),
+		})
+	}
+	return topTracks, nil
+}
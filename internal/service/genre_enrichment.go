@@ -0,0 +1,71 @@
+package service
+
+import (
+	"context"
+	"log"
+
+	"github.com/ranktify/ranktify-be/internal/dao"
+	"github.com/ranktify/ranktify-be/internal/spotify"
+)
+
+// GenreEnrichmentService tags stored songs with the genres of their artists, Spotify
+// doesn't tag tracks with genres
+type GenreEnrichmentService struct {
+	SongsDAO *dao.SongsDAO
+	Resolver spotify.ArtistGenreResolver
+}
+
+func NewGenreEnrichmentService(songsDAO *dao.SongsDAO, resolver spotify.ArtistGenreResolver) *GenreEnrichmentService {
+	return &GenreEnrichmentService{
+		SongsDAO: songsDAO,
+		Resolver: resolver,
+	}
+}
+
+// EnrichSongs resolves the genres of up to batchSize songs that haven't been enriched
+// yet and returns how many were processed
+func (s *GenreEnrichmentService) EnrichSongs(ctx context.Context, batchSize int) (int, error) {
+	songs, err := s.SongsDAO.GetSongsToEnrichGenres(ctx, batchSize)
+	if err != nil {
+		return 0, err
+	}
+	if len(songs) == 0 {
+		return 0, nil
+	}
+
+	seen := make(map[string]bool)
+	var artistIDs []string
+	for _, song := range songs {
+		for _, artist := range song.Artists {
+			if !seen[artist.SpotifyID] {
+				seen[artist.SpotifyID] = true
+				artistIDs = append(artistIDs, artist.SpotifyID)
+			}
+		}
+	}
+	artistGenres, err := s.Resolver.ArtistGenres(ctx, artistIDs)
+	if err != nil {
+		return 0, err
+	}
+
+	processed := 0
+	for _, song := range songs {
+		// main artist's genres first, so they become the song's primary genre
+		var genres []string
+		songGenres := make(map[string]bool)
+		for _, artist := range song.Artists {
+			for _, genre := range artistGenres[artist.SpotifyID] {
+				if !songGenres[genre] {
+					songGenres[genre] = true
+					genres = append(genres, genre)
+				}
+			}
+		}
+		if err := s.SongsDAO.SaveArtistGenres(ctx, song.SongID, genres); err != nil {
+			log.Printf("Couldn't save genres of song %d: %v", song.SongID, err)
+			continue
+		}
+		processed++
+	}
+	return processed, nil
+}
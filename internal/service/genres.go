@@ -0,0 +1,147 @@
+package service
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/ranktify/ranktify-be/internal/dao"
+	"github.com/ranktify/ranktify-be/internal/model"
+	"github.com/ranktify/ranktify-be/internal/spotify"
+)
+
+// ErrNoGenres is returned when the market has no enabled genre to pick from
+var ErrNoGenres = errors.New("no genres enabled for the market")
+
+// GenresService manages the genre catalog songs to rank are searched by
+type GenresService struct {
+	GenresDAO *dao.GenresDAO
+}
+
+func NewGenresService(genresDAO *dao.GenresDAO) *GenresService {
+	return &GenresService{GenresDAO: genresDAO}
+}
+
+// ListGenres returns the enabled genres offered in the market, every enabled genre
+// when it's empty, named in the locale (Spanish for es locales, English otherwise)
+func (s *GenresService) ListGenres(ctx context.Context, market string, locale string) (int, content) {
+	if market != "" && !spotify.ValidMarket(market) {
+		return http.StatusBadRequest, content{"error": "market must be an ISO 3166-1 alpha-2 country code"}
+	}
+	genres, err := s.GenresDAO.GetGenres(ctx, strings.ToUpper(market), false)
+	if err != nil {
+		return http.StatusInternalServerError, content{"error": "Failed to retrieve genres"}
+	}
+	spanish := isSpanishLocale(locale)
+	for i := range genres {
+		genres[i].Name = genres[i].NameEN
+		if spanish {
+			genres[i].Name = genres[i].NameES
+		}
+	}
+	return http.StatusOK, content{"genres": genres}
+}
+
+// ListAllGenres returns the whole catalog, disabled genres included
+func (s *GenresService) ListAllGenres(ctx context.Context) (int, content) {
+	genres, err := s.GenresDAO.GetGenres(ctx, "", true)
+	if err != nil {
+		return http.StatusInternalServerError, content{"error": "Failed to retrieve genres"}
+	}
+	return http.StatusOK, content{"genres": genres}
+}
+
+func (s *GenresService) CreateGenre(ctx context.Context, genre model.Genre) (int, content) {
+	if message, ok := normalizeGenre(&genre); !ok {
+		return http.StatusBadRequest, content{"error": message}
+	}
+	if err := s.GenresDAO.CreateGenre(ctx, &genre); err != nil {
+		if errors.Is(err, dao.ErrGenreTaken) {
+			return http.StatusConflict, content{"error": fmt.Sprintf("Genre %q already exists", genre.SearchKey)}
+		}
+		return http.StatusInternalServerError, content{"error": "Failed to create genre"}
+	}
+	return http.StatusCreated, content{"genre": genre}
+}
+
+func (s *GenresService) UpdateGenre(ctx context.Context, genreID uint64, genre model.Genre) (int, content) {
+	if message, ok := normalizeGenre(&genre); !ok {
+		return http.StatusBadRequest, content{"error": message}
+	}
+	genre.GenreID = genreID
+	if err := s.GenresDAO.UpdateGenre(ctx, &genre); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return http.StatusNotFound, content{"error": fmt.Sprintf("Genre with id %d not found", genreID)}
+		}
+		if errors.Is(err, dao.ErrGenreTaken) {
+			return http.StatusConflict, content{"error": fmt.Sprintf("Genre %q already exists", genre.SearchKey)}
+		}
+		return http.StatusInternalServerError, content{"error": "Failed to update genre"}
+	}
+	return http.StatusOK, content{"genre": genre}
+}
+
+func (s *GenresService) SetGenreEnabled(ctx context.Context, genreID uint64, enabled bool) (int, content) {
+	if err := s.GenresDAO.SetGenreEnabled(ctx, genreID, enabled); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return http.StatusNotFound, content{"error": fmt.Sprintf("Genre with id %d not found", genreID)}
+		}
+		return http.StatusInternalServerError, content{"error": "Failed to update genre"}
+	}
+	state := "Disabled"
+	if enabled {
+		state = "Enabled"
+	}
+	return http.StatusOK, content{"message": fmt.Sprintf("%s genre with id %d", state, genreID)}
+}
+
+// GetEnabledGenre returns the enabled genre with the search key, nil when the catalog
+// doesn't have it or it's disabled
+func (s *GenresService) GetEnabledGenre(ctx context.Context, searchKey string) (*model.Genre, error) {
+	genre, err := s.GenresDAO.GetGenreBySearchKey(ctx, strings.ToLower(strings.TrimSpace(searchKey)))
+	if err != nil || genre == nil || !genre.Enabled {
+		return nil, err
+	}
+	return genre, nil
+}
+
+// RandomGenre returns the search key of a random enabled genre offered in the market,
+// ErrNoGenres when there is none
+func (s *GenresService) RandomGenre(ctx context.Context, market string) (string, error) {
+	genre, err := s.GenresDAO.GetRandomGenre(ctx, spotify.MarketOrDefault(market))
+	if err != nil {
+		return "", err
+	}
+	if genre == nil {
+		return "", ErrNoGenres
+	}
+	return genre.SearchKey, nil
+}
+
+// normalizeGenre trims the genre, lower cases its search key and upper cases its
+// markets, reporting what's wrong when it's invalid
+func normalizeGenre(genre *model.Genre) (string, bool) {
+	genre.SearchKey = strings.ToLower(strings.TrimSpace(genre.SearchKey))
+	genre.NameEN = strings.TrimSpace(genre.NameEN)
+	genre.NameES = strings.TrimSpace(genre.NameES)
+	if genre.SearchKey == "" || genre.NameEN == "" || genre.NameES == "" {
+		return "search_key, name_en and name_es are required", false
+	}
+	markets := make([]string, 0, len(genre.Markets))
+	for _, market := range genre.Markets {
+		if !spotify.ValidMarket(market) {
+			return fmt.Sprintf("Invalid market %q, markets must be ISO 3166-1 alpha-2 country codes", market), false
+		}
+		markets = append(markets, strings.ToUpper(market))
+	}
+	genre.Markets = markets
+	return "", true
+}
+
+func isSpanishLocale(locale string) bool {
+	locale = strings.ToLower(locale)
+	return locale == "es" || strings.HasPrefix(locale, "es-")
+}
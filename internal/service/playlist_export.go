@@ -0,0 +1,126 @@
+package service
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/ranktify/ranktify-be/internal/dao"
+	"github.com/ranktify/ranktify-be/internal/model"
+	"github.com/ranktify/ranktify-be/internal/spotify"
+)
+
+// Spotify playlists hold up to 10000 tracks, exports stop well before that
+const maxExportedTracks = 1000
+
+// PlaylistExportService exports the user's rankings to playlists on their Spotify
+// account, one per minimum rank, syncing the same playlist on every export
+type PlaylistExportService struct {
+	ExportsDAO  *dao.PlaylistExportsDAO
+	RankingsDAO *dao.RankingsDao
+	UserDAO     *dao.UserDAO
+	Provider    spotify.MusicProvider
+	Tokens      *SpotifyTokenService
+}
+
+func NewPlaylistExportService(exportsDAO *dao.PlaylistExportsDAO, rankingsDAO *dao.RankingsDao, userDAO *dao.UserDAO, provider spotify.MusicProvider, tokens *SpotifyTokenService) *PlaylistExportService {
+	return &PlaylistExportService{
+		ExportsDAO:  exportsDAO,
+		RankingsDAO: rankingsDAO,
+		UserDAO:     userDAO,
+		Provider:    provider,
+		Tokens:      tokens,
+	}
+}
+
+// ExportRankings fills the user's playlist for minRank with the songs they ranked at
+// least minRank, creating it on the first export or when it no longer exists. Once
+// exported the playlist is synced even when no song qualifies any more.
+func (s *PlaylistExportService) ExportRankings(ctx context.Context, userID uint64, accessToken string, minRank int) (int, content) {
+	if minRank < 1 || minRank > 5 {
+		return http.StatusBadRequest, content{"error": "min_rank must be between 1 and 5"}
+	}
+
+	user, err := s.UserDAO.GetUserByID(userID)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return http.StatusNotFound, content{"error": fmt.Sprintf("User with id %d not found", userID)}
+		}
+		return http.StatusInternalServerError, content{"error": "Failed to retrieve user"}
+	}
+	if user.SpotifyID == nil {
+		return http.StatusConflict, content{"error": "Link your Spotify account before exporting"}
+	}
+
+	trackIDs, err := s.RankingsDAO.GetRankedSpotifyIDs(ctx, userID, minRank, maxExportedTracks)
+	if err != nil {
+		return http.StatusInternalServerError, content{"error": "Failed to retrieve rankings"}
+	}
+
+	export, err := s.ExportsDAO.GetPlaylistExport(ctx, userID, minRank)
+	if err != nil {
+		return http.StatusInternalServerError, content{"error": err.Error()}
+	}
+	// an exported playlist is still synced, emptied, so it doesn't keep stale tracks
+	if len(trackIDs) == 0 && export == nil {
+		return http.StatusNotFound, content{"error": fmt.Sprintf("No songs ranked %d or higher", minRank)}
+	}
+
+	created := false
+	var saveErr error
+	err = s.Tokens.WithAccessToken(ctx, userID, accessToken, func(accessToken string) error {
+		if export != nil {
+			err := s.Provider.SetPlaylistTracks(ctx, accessToken, export.PlaylistID, trackIDs)
+			var apiErr *spotify.APIError
+			if !errors.As(err, &apiErr) || apiErr.StatusCode != http.StatusNotFound {
+				return err
+			}
+			// the playlist is gone, export to a new one
+		}
+
+		name, description := exportPlaylistDetails(minRank)
+		playlistID, err := s.Provider.CreatePlaylist(ctx, accessToken, *user.SpotifyID, name, description)
+		if err != nil {
+			return err
+		}
+		// saved before filling it, so a retry with a refreshed token or a later export
+		// reuses the playlist even when filling it fails
+		export = &model.PlaylistExport{UserID: userID, MinRank: minRank, PlaylistID: playlistID}
+		created = true
+		if saveErr = s.ExportsDAO.SavePlaylistExport(ctx, export); saveErr != nil {
+			return saveErr
+		}
+		return s.Provider.SetPlaylistTracks(ctx, accessToken, playlistID, trackIDs)
+	})
+	if saveErr != nil {
+		return http.StatusInternalServerError, content{"error": saveErr.Error()}
+	}
+	if err != nil {
+		return SpotifyErrorResponse(err)
+	}
+
+	export.TrackCount = len(trackIDs)
+	if err := s.ExportsDAO.SavePlaylistExport(ctx, export); err != nil {
+		return http.StatusInternalServerError, content{"error": err.Error()}
+	}
+
+	statusCode := http.StatusOK
+	if created {
+		statusCode = http.StatusCreated
+	}
+	return statusCode, content{
+		"export":       export,
+		"playlist_url": "https://open.spotify.com/playlist/" + export.PlaylistID,
+		"created":      created,
+	}
+}
+
+func exportPlaylistDetails(minRank int) (string, string) {
+	if minRank == 5 {
+		return "My Ranktify 5s", "Every song I ranked 5 on Ranktify, most recent first"
+	}
+	return fmt.Sprintf("My Ranktify %d+", minRank),
+		fmt.Sprintf("Every song I ranked %d or higher on Ranktify, best ranked first", minRank)
+}
@@ -0,0 +1,90 @@
+package service
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"net/http"
+	"regexp"
+	"strings"
+
+	"github.com/ranktify/ranktify-be/internal/dao"
+	"github.com/ranktify/ranktify-be/internal/model"
+	"github.com/ranktify/ranktify-be/internal/spotify"
+)
+
+// a BCP 47 language tag like "es" or "es-PR"
+var localeRegex = regexp.MustCompile(`^[a-zA-Z]{2,3}(-[a-zA-Z0-9]{2,8})*$`)
+
+// users.locale is a VARCHAR(35), the longest tag BCP 47 asks implementations to keep
+const maxLocaleLength = 35
+
+// PreferencesService keeps the market and locale the user's Spotify searches use
+type PreferencesService struct {
+	UserDAO *dao.UserDAO
+}
+
+func NewPreferencesService(userDAO *dao.UserDAO) *PreferencesService {
+	return &PreferencesService{UserDAO: userDAO}
+}
+
+func (s *PreferencesService) GetPreferences(ctx context.Context, userID uint64) (int, content) {
+	preferences, err := s.UserDAO.GetUserPreferences(ctx, userID)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return http.StatusNotFound, content{"error": fmt.Sprintf("User with id %d not found", userID)}
+		}
+		return http.StatusInternalServerError, content{"error": "Failed to retrieve preferences"}
+	}
+	return http.StatusOK, content{"preferences": preferences}
+}
+
+// UpdatePreferences replaces the user's market and locale, empty or missing values
+// go back to the defaults
+func (s *PreferencesService) UpdatePreferences(ctx context.Context, userID uint64, preferences model.UserPreferences) (int, content) {
+	market := strings.TrimSpace(derefOrEmpty(preferences.Market))
+	locale := strings.TrimSpace(derefOrEmpty(preferences.Locale))
+	if market != "" && !spotify.ValidMarket(market) {
+		return http.StatusBadRequest, content{"error": "market must be an ISO 3166-1 alpha-2 country code"}
+	}
+	if locale != "" && (len(locale) > maxLocaleLength || !localeRegex.MatchString(locale)) {
+		return http.StatusBadRequest, content{"error": fmt.Sprintf("locale must be a language tag like es or es-PR, at most %d characters", maxLocaleLength)}
+	}
+
+	preferences = model.UserPreferences{}
+	if market != "" {
+		market = strings.ToUpper(market)
+		preferences.Market = &market
+	}
+	if locale != "" {
+		preferences.Locale = &locale
+	}
+	if err := s.UserDAO.UpdateUserPreferences(ctx, userID, preferences); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return http.StatusNotFound, content{"error": fmt.Sprintf("User with id %d not found", userID)}
+		}
+		return http.StatusInternalServerError, content{"error": "Failed to update preferences"}
+	}
+	return http.StatusOK, content{"preferences": preferences}
+}
+
+// Region returns where the user's Spotify searches run, the defaults when they have
+// no preferences
+func (s *PreferencesService) Region(ctx context.Context, userID uint64) (spotify.Region, error) {
+	preferences, err := s.UserDAO.GetUserPreferences(ctx, userID)
+	if err != nil {
+		return spotify.Region{}, err
+	}
+	return spotify.Region{
+		Market: spotify.MarketOrDefault(derefOrEmpty(preferences.Market)),
+		Locale: derefOrEmpty(preferences.Locale),
+	}, nil
+}
+
+func derefOrEmpty(value *string) string {
+	if value == nil {
+		return ""
+	}
+	return *value
+}
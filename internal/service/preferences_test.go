@@ -0,0 +1,30 @@
+package service
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/ranktify/ranktify-be/internal/model"
+)
+
+func TestUpdatePreferencesRejectsInvalidLocales(t *testing.T) {
+	tests := []struct {
+		name   string
+		locale string
+	}{
+		{name: "not a language tag", locale: "spanish!"},
+		{name: "longer than the column", locale: "es" + strings.Repeat("-abcdefgh", 4)},
+	}
+	// rejected before reaching the database
+	preferences := NewPreferencesService(nil)
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			status, response := preferences.UpdatePreferences(context.Background(), 1, model.UserPreferences{Locale: &tt.locale})
+			if status != http.StatusBadRequest {
+				t.Errorf("status = %d, want %d: %v", status, http.StatusBadRequest, response)
+			}
+		})
+	}
+}
@@ -0,0 +1,203 @@
+package service
+
+import (
+	"context"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/ranktify/ranktify-be/internal/dao"
+	"github.com/ranktify/ranktify-be/internal/model"
+	"github.com/ranktify/ranktify-be/internal/spotify"
+)
+
+const (
+	// scraped previews are stable, tracks without one are retried sooner since Spotify
+	// may add it or the lookup may have hit the wrong track
+	previewTTL        = 7 * 24 * time.Hour
+	missingPreviewTTL = 24 * time.Hour
+
+	// at most this many previews are scraped at once per call
+	previewWorkers = 4
+
+	// how long a request waits for the previews that weren't cached, the rest keep
+	// resolving in the background for up to previewResolveTimeout and are cached
+	previewRequestBudget  = 2 * time.Second
+	previewResolveTimeout = 30 * time.Second
+)
+
+// PreviewService fills song previews from the preview cache, scraping the missing ones
+type PreviewService struct {
+	DAO      *dao.PreviewCacheDAO
+	Provider spotify.MusicProvider
+}
+
+func NewPreviewService(dao *dao.PreviewCacheDAO, provider spotify.MusicProvider) *PreviewService {
+	return &PreviewService{
+		DAO:      dao,
+		Provider: provider,
+	}
+}
+
+type resolvedPreview struct {
+	index      int
+	previewURI *string
+	err        error
+}
+
+// FillPreviews sets the PreviewURI of the songs that don't have one, from the cache or
+// by scraping them in the market. Songs whose preview doesn't resolve within the request
+// budget are left without one, they are cached for the next request.
+func (s *PreviewService) FillPreviews(ctx context.Context, accessToken string, market string, songs []model.Song) error {
+	var spotifyIDs []string
+	for _, song := range songs {
+		if song.PreviewURI == nil {
+			spotifyIDs = append(spotifyIDs, song.SpotifyID)
+		}
+	}
+	if len(spotifyIDs) == 0 {
+		return nil
+	}
+
+	cached, err := s.DAO.GetPreviews(ctx, spotifyIDs)
+	if err != nil {
+		return err
+	}
+
+	var misses []model.Song
+	var missIndexes []int
+	for i := range songs {
+		if songs[i].PreviewURI != nil {
+			continue
+		}
+		if previewURI, ok := cached[songs[i].SpotifyID]; ok {
+			songs[i].PreviewURI = previewURI
+			continue
+		}
+		misses = append(misses, songs[i])
+		missIndexes = append(missIndexes, i)
+	}
+	if len(misses) == 0 {
+		return nil
+	}
+
+	// the scraping outlives the request so whatever misses the budget still gets cached
+	resolveCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), previewResolveTimeout)
+	results := s.resolvePreviews(resolveCtx, accessToken, market, misses)
+	// the stragglers are drained in the background, cancelling once they're done
+	defer func() {
+		go func() {
+			for range results {
+			}
+			cancel()
+		}()
+	}()
+
+	budget := time.NewTimer(previewRequestBudget)
+	defer budget.Stop()
+	for pending := len(misses); pending > 0; pending-- {
+		select {
+		case result := <-results:
+			if result.err == nil {
+				songs[missIndexes[result.index]].PreviewURI = result.previewURI
+			}
+		case <-budget.C:
+			return nil
+		case <-ctx.Done():
+			return nil
+		}
+	}
+	return nil
+}
+
+// RefreshStalePreviews resolves up to batchSize stored songs whose preview was never
+// resolved or expired, returning how many were refreshed and how many failed. Failed
+// songs are backed off so the next batch moves on to other songs.
+func (s *PreviewService) RefreshStalePreviews(ctx context.Context, batchSize int) (int, int, error) {
+	songs, err := s.DAO.GetSongsWithStalePreviews(ctx, batchSize)
+	if err != nil {
+		return 0, 0, err
+	}
+	if len(songs) == 0 {
+		return 0, 0, nil
+	}
+
+	// no user is involved, searching only needs the app's token
+	accessToken, err := s.Provider.ClientCredentialsToken(ctx)
+	if err != nil {
+		return 0, 0, err
+	}
+
+	// the cache is shared by every user, refreshes look the tracks up in the default market
+	refreshed, failed := 0, 0
+	for result := range s.resolvePreviews(ctx, accessToken, "", songs) {
+		if result.err != nil {
+			song := songs[result.index]
+			log.Printf("Couldn't refresh the preview of song %d: %v", song.SongID, result.err)
+			if err := s.DAO.RecordPreviewFailure(ctx, song.SpotifyID); err != nil {
+				return refreshed, failed, err
+			}
+			failed++
+			continue
+		}
+		refreshed++
+	}
+	return refreshed, failed, nil
+}
+
+// resolvePreviews scrapes and caches the songs' previews with a bounded pool of
+// workers, the channel is closed once every song is done
+func (s *PreviewService) resolvePreviews(ctx context.Context, accessToken string, market string, songs []model.Song) <-chan resolvedPreview {
+	jobs := make(chan int)
+	// buffered so workers never block on a caller that stopped reading
+	results := make(chan resolvedPreview, len(songs))
+
+	var wg sync.WaitGroup
+	for range min(previewWorkers, len(songs)) {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range jobs {
+				previewURI, err := s.resolvePreview(ctx, accessToken, market, songs[i])
+				results <- resolvedPreview{index: i, previewURI: previewURI, err: err}
+			}
+		}()
+	}
+
+	go func() {
+		for i := range songs {
+			jobs <- i
+		}
+		close(jobs)
+		wg.Wait()
+		close(results)
+	}()
+	return results
+}
+
+func (s *PreviewService) resolvePreview(ctx context.Context, accessToken string, market string, song model.Song) (*string, error) {
+	// searched by the main artist alone, Spotify matches artist: against a single name
+	var artist string
+	if len(song.Artists) > 0 {
+		artist = song.Artists[0].Name
+	} else if song.Artist != nil {
+		artist = *song.Artist
+	}
+
+	previewURI, err := s.Provider.PreviewURL(ctx, accessToken, market, song.Title, artist)
+	if err != nil {
+		// failed lookups aren't cached, the next request tries again
+		return nil, err
+	}
+
+	var preview *string
+	ttl := missingPreviewTTL
+	if previewURI != "" {
+		preview = &previewURI
+		ttl = previewTTL
+	}
+	if err := s.DAO.SavePreview(ctx, song.SpotifyID, preview, ttl); err != nil {
+		return nil, err
+	}
+	return preview, nil
+}
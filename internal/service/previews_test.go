@@ -0,0 +1,70 @@
+package service
+
+import (
+	"context"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/ranktify/ranktify-be/internal/dao"
+	"github.com/ranktify/ranktify-be/internal/model"
+	"github.com/ranktify/ranktify-be/internal/spotify"
+	"github.com/ranktify/ranktify-be/internal/testutil"
+)
+
+// previewStub records the artist each preview was searched with
+type previewStub struct {
+	spotify.MusicProvider
+	artists []string
+}
+
+func (p *previewStub) PreviewURL(ctx context.Context, accessToken string, market string, trackTitle string, trackArtist string) (string, error) {
+	p.artists = append(p.artists, trackArtist)
+	return "", nil
+}
+
+func TestResolvePreviewSearchesMainArtist(t *testing.T) {
+	// songs keep their main artist's name in Artist
+	artist := "Bad Bunny"
+	tests := []struct {
+		name       string
+		song       model.Song
+		wantArtist string
+	}{
+		{
+			name: "main artist of a collaboration",
+			song: model.Song{SpotifyID: "a", Title: "Dákiti", Artist: &artist, Artists: []model.Artist{
+				{SpotifyID: "4q3ewBCX7sLwd24euuV69X", Name: "Bad Bunny"},
+				{SpotifyID: "0EFisYRi20PTADoJrifHrz", Name: "Jhay Cortez"},
+			}},
+			wantArtist: "Bad Bunny",
+		},
+		{
+			name:       "stored song without its artists",
+			song:       model.Song{SpotifyID: "b", Title: "Dákiti", Artist: &artist},
+			wantArtist: "Bad Bunny",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db, mock := testutil.MockDB(t)
+			provider := &previewStub{}
+			previews := NewPreviewService(dao.NewPreviewCacheDAO(db), provider)
+
+			mock.ExpectBegin()
+			mock.ExpectExec(`INSERT INTO preview_cache`).WillReturnResult(sqlmock.NewResult(0, 1))
+			mock.ExpectExec(`UPDATE songs`).WillReturnResult(sqlmock.NewResult(0, 1))
+			mock.ExpectExec(`DELETE FROM preview_failures`).WillReturnResult(sqlmock.NewResult(0, 0))
+			mock.ExpectCommit()
+
+			if _, err := previews.resolvePreview(context.Background(), "token", "PR", tt.song); err != nil {
+				t.Fatalf("resolvePreview: %v", err)
+			}
+			if len(provider.artists) != 1 || provider.artists[0] != tt.wantArtist {
+				t.Errorf("searched with artists %q, want %q", provider.artists, tt.wantArtist)
+			}
+			if err := mock.ExpectationsWereMet(); err != nil {
+				t.Error(err)
+			}
+		})
+	}
+}
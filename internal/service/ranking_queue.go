@@ -0,0 +1,142 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/ranktify/ranktify-be/internal/dao"
+	"github.com/ranktify/ranktify-be/internal/model"
+	"github.com/ranktify/ranktify-be/internal/spotify"
+)
+
+const (
+	// imports stop after this many tracks so a huge playlist doesn't hold the request
+	maxImportedTracks = 1000
+
+	// how many songs GET /queue lists
+	queuePreviewSize = 50
+)
+
+// Sources a ranking queue can be imported from
+const (
+	QueueSourceLiked  = "liked"
+	QueueSourceRecent = "recent"
+)
+
+// RankingQueueService imports songs from the user's Spotify library into a ranking
+// queue that the songs to rank are drawn from
+type RankingQueueService struct {
+	QueueDAO *dao.RankingQueueDAO
+	SongsDAO *dao.SongsDAO
+	Provider spotify.MusicProvider
+	Tokens   *SpotifyTokenService
+}
+
+func NewRankingQueueService(queueDAO *dao.RankingQueueDAO, songsDAO *dao.SongsDAO, provider spotify.MusicProvider, tokens *SpotifyTokenService) *RankingQueueService {
+	return &RankingQueueService{
+		QueueDAO: queueDAO,
+		SongsDAO: songsDAO,
+		Provider: provider,
+		Tokens:   tokens,
+	}
+}
+
+// ImportPlaylist queues the tracks of the Spotify playlist, as available in the market
+func (s *RankingQueueService) ImportPlaylist(ctx context.Context, userID uint64, accessToken string, market string, playlistID string) (int, content) {
+	return s.importSongs(ctx, userID, accessToken, "playlist:"+playlistID, func(accessToken string) ([]model.Song, error) {
+		return s.Provider.PlaylistTracks(ctx, accessToken, market, playlistID, maxImportedTracks)
+	})
+}
+
+// ImportLibrary queues the user's liked songs (QueueSourceLiked) or recently played
+// tracks (QueueSourceRecent)
+func (s *RankingQueueService) ImportLibrary(ctx context.Context, userID uint64, accessToken string, market string, source string) (int, content) {
+	switch source {
+	case QueueSourceLiked:
+		return s.importSongs(ctx, userID, accessToken, source, func(accessToken string) ([]model.Song, error) {
+			return s.Provider.SavedTracks(ctx, accessToken, market, maxImportedTracks)
+		})
+	case QueueSourceRecent:
+		return s.importSongs(ctx, userID, accessToken, source, func(accessToken string) ([]model.Song, error) {
+			return s.Provider.RecentlyPlayedTracks(ctx, accessToken)
+		})
+	default:
+		return http.StatusBadRequest, content{"error": fmt.Sprintf("Invalid source %q", source)}
+	}
+}
+
+func (s *RankingQueueService) importSongs(ctx context.Context, userID uint64, accessToken string, source string, fetch func(accessToken string) ([]model.Song, error)) (int, content) {
+	var songs []model.Song
+	err := s.Tokens.WithAccessToken(ctx, userID, accessToken, func(accessToken string) error {
+		var err error
+		songs, err = fetch(accessToken)
+		return err
+	})
+	if err != nil {
+		var apiErr *spotify.APIError
+		if errors.As(err, &apiErr) && apiErr.StatusCode == http.StatusNotFound {
+			return http.StatusNotFound, content{"error": fmt.Sprintf("Spotify couldn't find %s", source)}
+		}
+		return SpotifyErrorResponse(err)
+	}
+
+	songIDs, err := s.SongsDAO.StoreSongs(ctx, songs)
+	if err != nil {
+		return http.StatusInternalServerError, content{"error": err.Error()}
+	}
+
+	queued, err := s.QueueDAO.EnqueueSongs(ctx, userID, songIDs, source)
+	if err != nil {
+		return http.StatusInternalServerError, content{"error": err.Error()}
+	}
+	queueSize, err := s.QueueDAO.CountQueuedSongs(ctx, userID)
+	if err != nil {
+		return http.StatusInternalServerError, content{"error": err.Error()}
+	}
+
+	return http.StatusOK, content{
+		"imported":   len(songs),
+		"queued":     queued,
+		"queue_size": queueSize,
+	}
+}
+
+// GetQueue returns the next songs in the user's queue and its size
+func (s *RankingQueueService) GetQueue(ctx context.Context, userID uint64) (int, content) {
+	songs, err := s.QueueDAO.GetQueuedSongs(ctx, userID, queuePreviewSize)
+	if err != nil {
+		return http.StatusInternalServerError, content{"error": "Failed to retrieve the ranking queue"}
+	}
+	queueSize, err := s.QueueDAO.CountQueuedSongs(ctx, userID)
+	if err != nil {
+		return http.StatusInternalServerError, content{"error": "Failed to retrieve the ranking queue"}
+	}
+	if songs == nil {
+		songs = []model.QueuedSong{}
+	}
+	return http.StatusOK, content{"songs": songs, "queue_size": queueSize}
+}
+
+func (s *RankingQueueService) ClearQueue(ctx context.Context, userID uint64) (int, content) {
+	removed, err := s.QueueDAO.ClearQueue(ctx, userID)
+	if err != nil {
+		return http.StatusInternalServerError, content{"error": "Failed to clear the ranking queue"}
+	}
+	return http.StatusOK, content{"message": fmt.Sprintf("Removed %d songs from the ranking queue", removed)}
+}
+
+// NextSongs draws up to n songs from the front of the user's queue, songs stay queued
+// until they are ranked
+func (s *RankingQueueService) NextSongs(ctx context.Context, userID uint64, n int) ([]model.Song, error) {
+	queued, err := s.QueueDAO.GetQueuedSongs(ctx, userID, n)
+	if err != nil {
+		return nil, err
+	}
+	songs := make([]model.Song, 0, len(queued))
+	for _, queuedSong := range queued {
+		songs = append(songs, queuedSong.Song)
+	}
+	return songs, nil
+}
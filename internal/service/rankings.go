@@ -2,26 +2,46 @@ package service
 
 import (
 	"context"
+	"database/sql"
+	"fmt"
 	"net/http"
 
 	"github.com/ranktify/ranktify-be/internal/dao"
 	"github.com/ranktify/ranktify-be/internal/model"
+	"github.com/ranktify/ranktify-be/internal/rating"
 )
 
 type RankingsService struct {
 	RankingsDAO *dao.RankingsDao
 	StreaksDAO  *dao.StreaksDAO
+	FriendsDAO  *dao.FriendsDAO
+	UserDAO     *dao.UserDAO
 }
 
-func NewRankingsService(rankingsDao *dao.RankingsDao, sDao *dao.StreaksDAO) *RankingsService {
+func NewRankingsService(rankingsDao *dao.RankingsDao, sDao *dao.StreaksDAO, friendsDAO *dao.FriendsDAO, userDAO *dao.UserDAO) *RankingsService {
 	return &RankingsService{
 		RankingsDAO: rankingsDao,
 		StreaksDAO:  sDao,
+		FriendsDAO:  friendsDAO,
+		UserDAO:     userDAO,
 	}
 }
 
-func (s *RankingsService) GetRankedSongs(userID uint64) (int, content) {
-	rankings, err := s.RankingsDAO.GetRankedSongs(userID)
+// GetRankedSongs returns the user's rankings, order can be empty for the default
+// order or "pairwise" for the personal order given by the "this or that" mode.
+func (s *RankingsService) GetRankedSongs(userID uint64, order string) (int, content) {
+	var (
+		rankings []model.Rankings
+		err      error
+	)
+	switch order {
+	case "":
+		rankings, err = s.RankingsDAO.GetRankedSongs(userID)
+	case "pairwise":
+		rankings, err = s.RankingsDAO.GetRankedSongsByPairwiseRating(userID)
+	default:
+		return http.StatusBadRequest, content{"error": fmt.Sprintf("Invalid order %q", order)}
+	}
 	if err != nil {
 		return http.StatusNotFound, content{"error": "Failed to retrieve rankings"}
 	}
@@ -36,35 +56,81 @@ func (s *RankingsService) GetFriendsRankedSongs(userID uint64) (int, content) {
 	return http.StatusOK, content{"User's friends rankings": rankings}
 }
 
-func (s *RankingsService) GetFriendsRankedSongsWithNoUserRank(userID uint64) (int, content) {
-	rankings, err := s.RankingsDAO.GetFriendsRankedSongsWithNoUserRank(userID)
+func (s *RankingsService) GetFriendsRankedSongsWithNoUserRank(ctx context.Context, userID uint64) (int, content) {
+	// the first few are enough, song recommendations page through the rest
+	rankings, err := s.RankingsDAO.GetFriendsRankedSongsWithNoUserRank(ctx, userID, 5, 0)
 	if err != nil {
 		return http.StatusNotFound, content{"error": "Failed to retrieve rankings"}
 	}
 	return http.StatusOK, content{"User's friends songs": rankings}
 }
 
-func (s *RankingsService) RankSong(songID uint64, userID uint64, rank int) (int, content) {
-	err := s.RankingsDAO.RankSong(songID, userID, rank)
+func (s *RankingsService) GetPairwiseComparison(ctx context.Context, userID uint64) (int, content) {
+	songs, err := s.RankingsDAO.GetPairwiseCandidates(ctx, userID)
 	if err != nil {
-		return http.StatusBadRequest, content{"error": "Failed to rank song"}
+		return http.StatusInternalServerError, content{"error": "Failed to retrieve songs to compare"}
 	}
-	if err = s.StreaksDAO.RecordSongRank(context.Background(), userID); err != nil {
-		return http.StatusBadRequest, content{"error": "Failed to record streak"}
+	if len(songs) < 2 {
+		return http.StatusNotFound, content{"error": "Rank at least two songs to compare them"}
 	}
-	return http.StatusOK, content{"Song ranked succesfully as a": rank}
+	return http.StatusOK, content{"songs": songs}
 }
 
-func (s *RankingsService) DeleteRanking(rankingID uint64) (int, content) {
-	err := s.RankingsDAO.DeleteRanking(rankingID)
+func (s *RankingsService) RecordPairwiseComparison(ctx context.Context, userID uint64, winnerID uint64, loserID uint64) (int, content) {
+	if winnerID == loserID {
+		return http.StatusBadRequest, content{"error": "A song can't be compared against itself"}
+	}
+	err := s.RankingsDAO.RecordPairwiseComparison(ctx, userID, winnerID, loserID)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return http.StatusNotFound, content{"error": "Both songs must be ranked by the user"}
+		}
+		return http.StatusInternalServerError, content{"error": "Failed to record comparison"}
+	}
+	return http.StatusOK, content{"message": "Comparison recorded successfully"}
+}
+
+func (s *RankingsService) RankSong(ctx context.Context, songID uint64, userID uint64, rank int, source string) (int, content) {
+	// re-ranking a song the same day doesn't count towards the streak again
+	result, err := s.RankingsDAO.RankSong(ctx, songID, userID, rank, source, s.StreaksDAO)
+	if err != nil {
+		return http.StatusInternalServerError, content{"error": "Failed to rank song"}
+	}
+
+	statusCode, status := http.StatusOK, "updated"
+	if result.Created {
+		statusCode, status = http.StatusCreated, "created"
+	}
+	return statusCode, content{
+		"Song ranked succesfully as a": rank,
+		"ranking_id":                   result.RankingID,
+		"status":                       status,
+	}
+}
+
+// authorizeRanking checks that the ranking exists and belongs to the user
+func (s *RankingsService) authorizeRanking(userID uint64, rankingID uint64) (int, content, bool) {
+	return authorize(userID, "Ranking", func() (uint64, error) {
+		return s.RankingsDAO.GetRankingOwner(rankingID)
+	})
+}
+
+func (s *RankingsService) DeleteRanking(ctx context.Context, userID uint64, rankingID uint64, source string) (int, content) {
+	if statusCode, body, ok := s.authorizeRanking(userID, rankingID); !ok {
+		return statusCode, body
+	}
+	err := s.RankingsDAO.DeleteRanking(ctx, rankingID, source)
 	if err != nil {
 		return http.StatusBadRequest, content{"error": "Failed to delete rank"}
 	}
 	return http.StatusOK, content{"Ranking deleted succesfully": rankingID}
 }
 
-func (s *RankingsService) UpdateRanking(rankingID uint64, rank int) (int, content) {
-	err := s.RankingsDAO.UpdateRanking(rankingID, rank)
+func (s *RankingsService) UpdateRanking(ctx context.Context, userID uint64, rankingID uint64, rank int, source string) (int, content) {
+	if statusCode, body, ok := s.authorizeRanking(userID, rankingID); !ok {
+		return statusCode, body
+	}
+	err := s.RankingsDAO.UpdateRanking(ctx, rankingID, rank, source)
 	if err != nil {
 		return http.StatusBadRequest, content{"error": "Failed to update rank"}
 	}
@@ -72,10 +138,34 @@ func (s *RankingsService) UpdateRanking(rankingID uint64, rank int) (int, conten
 
 }
 
+func (s *RankingsService) GetRankingHistory(ctx context.Context, userID uint64, songID uint64) (int, content) {
+	history, err := s.RankingsDAO.GetRankingHistory(ctx, userID, songID)
+	if err != nil {
+		return http.StatusInternalServerError, content{"error": "Failed to retrieve ranking history"}
+	}
+	if len(history) == 0 {
+		return http.StatusNotFound, content{"error": fmt.Sprintf("No ranking history found for song with id %d", songID)}
+	}
+	return http.StatusOK, content{"history": history}
+}
+
+func (s *RankingsService) GetFriendsRankingChanges(ctx context.Context, userID uint64) (int, content) {
+	changes, err := s.RankingsDAO.GetFriendsRankingChanges(ctx, userID, 20)
+	if err != nil {
+		return http.StatusInternalServerError, content{"error": "Failed to retrieve friends ranking changes"}
+	}
+	return http.StatusOK, content{"changes": changes}
+}
+
+// GetTopWeeklyRankedSongs returns the top 5 songs of last week's chart
 func (s *RankingsService) GetTopWeeklyRankedSongs(ctx context.Context) ([]model.Song, error) {
-	songs, err := s.RankingsDAO.GetTopWeeklyRankedSongs(ctx)
+	chart, err := s.buildChart(ctx, PeriodWeekly, rating.DefaultScorer, model.ChartFilter{}, 1, 5)
 	if err != nil {
 		return nil, err
 	}
+	var songs []model.Song
+	for _, entry := range chart.Entries {
+		songs = append(songs, entry.Song)
+	}
 	return songs, nil
 }
@@ -0,0 +1,90 @@
+package service
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/ranktify/ranktify-be/internal/dao"
+)
+
+// Feedback a user can give on a recommended song, any of them keeps the song from
+// being recommended again
+const (
+	FeedbackSkip          = "skip"
+	FeedbackNotInterested = "not_interested"
+	FeedbackAlreadyKnow   = "already_know"
+)
+
+// RecommendationFeedbackService records what users think of the songs recommended to
+// them, the recommendations leave out and down-weight what they turned down
+type RecommendationFeedbackService struct {
+	FeedbackDAO *dao.RecommendationFeedbackDAO
+	SongsDAO    *dao.SongsDAO
+}
+
+func NewRecommendationFeedbackService(feedbackDAO *dao.RecommendationFeedbackDAO, songsDAO *dao.SongsDAO) *RecommendationFeedbackService {
+	return &RecommendationFeedbackService{
+		FeedbackDAO: feedbackDAO,
+		SongsDAO:    songsDAO,
+	}
+}
+
+// SaveFeedback records the user's feedback on the song, replacing the previous one.
+// Excluding the song's artist is only possible along with not_interested.
+func (s *RecommendationFeedbackService) SaveFeedback(ctx context.Context, userID uint64, songID uint64, feedback string, excludeArtist bool) (int, content) {
+	switch feedback {
+	case FeedbackSkip, FeedbackAlreadyKnow:
+		if excludeArtist {
+			return http.StatusBadRequest, content{"error": "exclude_artist is only allowed with not_interested"}
+		}
+	case FeedbackNotInterested:
+	default:
+		return http.StatusBadRequest, content{"error": fmt.Sprintf("Invalid feedback %q, expected skip, not_interested or already_know", feedback)}
+	}
+
+	if _, err := s.SongsDAO.GetSongByID(ctx, songID); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return http.StatusNotFound, content{"error": fmt.Sprintf("Song with id %d not found", songID)}
+		}
+		return http.StatusInternalServerError, content{"error": "Failed to retrieve song"}
+	}
+
+	// the song's main artist is the one excluded
+	var excludedArtistID *uint64
+	if excludeArtist {
+		artists, err := s.SongsDAO.GetSongArtists(ctx, songID)
+		if err != nil {
+			return http.StatusInternalServerError, content{"error": "Failed to retrieve song artists"}
+		}
+		if len(artists) == 0 {
+			return http.StatusConflict, content{"error": fmt.Sprintf("Song with id %d has no known artist to exclude", songID)}
+		}
+		excludedArtistID = &artists[0].ArtistID
+	}
+
+	if err := s.FeedbackDAO.SaveFeedback(ctx, userID, songID, feedback, excludedArtistID); err != nil {
+		return http.StatusInternalServerError, content{"error": "Failed to save feedback"}
+	}
+	return http.StatusOK, content{"message": fmt.Sprintf("Song with id %d won't be recommended again", songID)}
+}
+
+func (s *RecommendationFeedbackService) DeleteFeedback(ctx context.Context, userID uint64, songID uint64) (int, content) {
+	if err := s.FeedbackDAO.DeleteFeedback(ctx, userID, songID); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return http.StatusNotFound, content{"error": fmt.Sprintf("No feedback on song with id %d", songID)}
+		}
+		return http.StatusInternalServerError, content{"error": "Failed to delete feedback"}
+	}
+	return http.StatusOK, content{"message": fmt.Sprintf("Song with id %d can be recommended again", songID)}
+}
+
+func (s *RecommendationFeedbackService) GetFeedback(ctx context.Context, userID uint64) (int, content) {
+	feedback, err := s.FeedbackDAO.GetFeedback(ctx, userID)
+	if err != nil {
+		return http.StatusInternalServerError, content{"error": "Failed to retrieve feedback"}
+	}
+	return http.StatusOK, content{"feedback": feedback}
+}
@@ -0,0 +1,75 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"net/http"
+
+	"github.com/ranktify/ranktify-be/internal/dao"
+	"github.com/ranktify/ranktify-be/internal/model"
+	"github.com/ranktify/ranktify-be/internal/pagination"
+	"github.com/ranktify/ranktify-be/internal/recommendation"
+	"github.com/ranktify/ranktify-be/internal/spotify"
+)
+
+// RecommendationService pages through the songs the blender's strategies recommend
+type RecommendationService struct {
+	Blender  *recommendation.Blender
+	SongsDAO *dao.SongsDAO
+	Tokens   *SpotifyTokenService
+}
+
+func NewRecommendationService(blender *recommendation.Blender, songsDAO *dao.SongsDAO, tokens *SpotifyTokenService) *RecommendationService {
+	return &RecommendationService{
+		Blender:  blender,
+		SongsDAO: songsDAO,
+		Tokens:   tokens,
+	}
+}
+
+// Recommend returns a page of up to limit recommendations, continuing from cursor (nil
+// on the first page). Recommended songs are stored so they can be ranked by id.
+func (s *RecommendationService) Recommend(ctx context.Context, userID uint64, accessToken string, region spotify.Region, limit int, cursor recommendation.Cursor) (int, content) {
+	req := recommendation.Request{UserID: userID, Region: region, Limit: limit}
+	var recommendations []model.Recommendation
+	var next recommendation.Cursor
+	err := s.Tokens.WithAccessToken(ctx, userID, accessToken, func(accessToken string) error {
+		req.AccessToken = accessToken
+		var err error
+		recommendations, next, err = s.Blender.Blend(ctx, req, cursor)
+		return err
+	})
+	if err != nil {
+		if errors.Is(err, pagination.ErrInvalidCursor) {
+			return http.StatusBadRequest, content{"error": "Invalid cursor"}
+		}
+		return SpotifyErrorResponse(err)
+	}
+
+	// the songs straight from Spotify are stored together, in one transaction
+	var unstored []int
+	var songs []model.Song
+	for i, recommended := range recommendations {
+		if recommended.SongID == 0 {
+			unstored = append(unstored, i)
+			songs = append(songs, recommended.Song)
+		}
+	}
+	songIDs, err := s.SongsDAO.StoreSongs(ctx, songs)
+	if err != nil {
+		return http.StatusInternalServerError, content{"error": "Failed to store the recommended songs"}
+	}
+	for j, i := range unstored {
+		recommendations[i].SongID = songIDs[j]
+	}
+
+	page := content{"songs": recommendations, "next_cursor": nil, "limit": limit}
+	if next != nil {
+		nextCursor, err := pagination.EncodeCursor(next)
+		if err != nil {
+			return http.StatusInternalServerError, content{"error": err.Error()}
+		}
+		page["next_cursor"] = nextCursor
+	}
+	return http.StatusOK, page
+}
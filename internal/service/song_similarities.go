@@ -0,0 +1,36 @@
+package service
+
+import (
+	"context"
+
+	"github.com/ranktify/ranktify-be/internal/dao"
+)
+
+const (
+	// users that must have ranked both songs for them to be similar, one user's taste
+	// alone isn't a pattern
+	minCoRankers = 2
+
+	// similarities of pairs few users ranked are shrunk towards 0, with 5 co-rankers a
+	// similarity keeps half its value
+	similarityShrinkage = 5
+
+	// most similar songs kept per song
+	similarSongsPerSong = 50
+)
+
+// SongSimilarityService rebuilds the song to song similarities the collaborative
+// recommendations are made from
+type SongSimilarityService struct {
+	SimilaritiesDAO *dao.SongSimilaritiesDAO
+}
+
+func NewSongSimilarityService(similaritiesDAO *dao.SongSimilaritiesDAO) *SongSimilarityService {
+	return &SongSimilarityService{SimilaritiesDAO: similaritiesDAO}
+}
+
+// RebuildSimilarities recomputes every similarity from the current rankings and
+// returns how many were stored
+func (s *SongSimilarityService) RebuildSimilarities(ctx context.Context) (int64, error) {
+	return s.SimilaritiesDAO.RebuildSimilarities(ctx, minCoRankers, similarityShrinkage, similarSongsPerSong)
+}
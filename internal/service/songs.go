@@ -0,0 +1,83 @@
+package service
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/ranktify/ranktify-be/internal/dao"
+	"github.com/ranktify/ranktify-be/internal/model"
+)
+
+type SongsService struct {
+	SongsDAO    *dao.SongsDAO
+	RankingsDAO *dao.RankingsDao
+}
+
+func NewSongsService(songsDAO *dao.SongsDAO, rankingsDAO *dao.RankingsDao) *SongsService {
+	return &SongsService{
+		SongsDAO:    songsDAO,
+		RankingsDAO: rankingsDAO,
+	}
+}
+
+func (s *SongsService) GetSongDetail(ctx context.Context, userID uint64, songID uint64) (int, content) {
+	song, err := s.SongsDAO.GetSongByID(ctx, songID)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return http.StatusNotFound, content{"error": fmt.Sprintf("Song with id %d not found", songID)}
+		}
+		return http.StatusInternalServerError, content{"error": "Failed to retrieve song"}
+	}
+	return s.songDetail(ctx, userID, *song)
+}
+
+func (s *SongsService) GetSongDetailBySpotifyID(ctx context.Context, userID uint64, spotifyID string) (int, content) {
+	song, err := s.RankingsDAO.GetSongBySpotifyID(spotifyID)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return http.StatusNotFound, content{"error": fmt.Sprintf("Song with spotify id %s not found", spotifyID)}
+		}
+		return http.StatusInternalServerError, content{"error": "Failed to retrieve song"}
+	}
+	return s.songDetail(ctx, userID, song)
+}
+
+// songDetail completes the song with its global, user and friends rankings
+func (s *SongsService) songDetail(ctx context.Context, userID uint64, song model.Song) (int, content) {
+	detail := model.SongDetail{Song: song}
+
+	artists, err := s.SongsDAO.GetSongArtists(ctx, song.SongID)
+	if err != nil {
+		return http.StatusInternalServerError, content{"error": "Failed to retrieve song artists"}
+	}
+	detail.Artists = artists
+	detail.Genres, err = s.SongsDAO.GetSongGenres(ctx, song.SongID)
+	if err != nil {
+		return http.StatusInternalServerError, content{"error": "Failed to retrieve song genres"}
+	}
+	detail.AlbumDetails, err = s.SongsDAO.GetSongAlbum(ctx, song.SongID)
+	if err != nil {
+		return http.StatusInternalServerError, content{"error": "Failed to retrieve song album"}
+	}
+
+	stats, err := s.SongsDAO.GetSongRankingStats(ctx, song.SongID)
+	if err != nil {
+		return http.StatusInternalServerError, content{"error": "Failed to retrieve song ranking stats"}
+	}
+	detail.Stats = *stats
+
+	detail.UserRanking, err = s.RankingsDAO.GetUserRankingForSong(ctx, userID, song.SongID)
+	if err != nil && err != sql.ErrNoRows {
+		return http.StatusInternalServerError, content{"error": "Failed to retrieve user ranking"}
+	}
+
+	detail.FriendsRankings, err = s.SongsDAO.GetFriendsRankingsForSong(ctx, userID, song.SongID)
+	if err != nil {
+		return http.StatusInternalServerError, content{"error": "Failed to retrieve friends rankings"}
+	}
+
+	return http.StatusOK, content{"song": detail}
+}
@@ -0,0 +1,146 @@
+package service
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"math"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/ranktify/ranktify-be/internal/dao"
+	"github.com/ranktify/ranktify-be/internal/model"
+	"github.com/ranktify/ranktify-be/internal/spotify"
+)
+
+// SpotifyAccountService links and unlinks the users' Spotify accounts
+type SpotifyAccountService struct {
+	UserDAO    *dao.UserDAO
+	SpotifyDAO *dao.SpotifyDAO
+	Tokens     *SpotifyTokenService
+	Provider   spotify.MusicProvider
+}
+
+func NewSpotifyAccountService(userDAO *dao.UserDAO, spotifyDAO *dao.SpotifyDAO, tokens *SpotifyTokenService, provider spotify.MusicProvider) *SpotifyAccountService {
+	return &SpotifyAccountService{
+		UserDAO:    userDAO,
+		SpotifyDAO: spotifyDAO,
+		Tokens:     tokens,
+		Provider:   provider,
+	}
+}
+
+// ExchangeCode trades an authorization code from the Spotify login for tokens and the
+// profile they belong to
+func (s *SpotifyAccountService) ExchangeCode(ctx context.Context, code string) (*spotify.SpotifyAccessTokenResponse, *spotify.SpotifyProfile, error) {
+	formData := url.Values{}
+	formData.Set("grant_type", "authorization_code")
+	formData.Set("code", code)
+	formData.Set("redirect_uri", spotify.GetSpotifyRedirectURI())
+
+	tokenResponse, err := s.Provider.ExchangeToken(ctx, formData)
+	if err != nil {
+		return nil, nil, err
+	}
+	profile, err := s.Provider.CurrentUser(ctx, tokenResponse.AccessToken)
+	if err != nil {
+		return nil, nil, err
+	}
+	return tokenResponse, profile, nil
+}
+
+// LinkAccount completes the authorization code flow for the user, linking the Spotify
+// profile and keeping its tokens. A Spotify account already linked to someone else
+// is rejected before anything is stored.
+func (s *SpotifyAccountService) LinkAccount(ctx context.Context, userID uint64, code string) (int, content) {
+	tokenResponse, profile, err := s.ExchangeCode(ctx, code)
+	if err != nil {
+		return SpotifyErrorResponse(err)
+	}
+
+	account := spotifyAccountFromProfile(profile)
+	if err := s.UserDAO.LinkSpotifyAccount(ctx, userID, account); err != nil {
+		if errors.Is(err, dao.ErrSpotifyAccountTaken) {
+			return http.StatusConflict, content{"error": "This Spotify account is linked to another user"}
+		}
+		if errors.Is(err, sql.ErrNoRows) {
+			return http.StatusNotFound, content{"error": fmt.Sprintf("User with id %d not found", userID)}
+		}
+		return http.StatusInternalServerError, content{"error": err.Error()}
+	}
+
+	if err := s.Tokens.StoreAuthorization(userID, tokenResponse); err != nil {
+		return http.StatusInternalServerError, content{"error": err.Error()}
+	}
+
+	return http.StatusOK, content{
+		"access_token":    tokenResponse.AccessToken,
+		"spotify_account": account,
+	}
+}
+
+// UnlinkAccount forgets the user's Spotify profile and tokens
+func (s *SpotifyAccountService) UnlinkAccount(ctx context.Context, userID uint64) (int, content) {
+	if err := s.UserDAO.UnlinkSpotifyAccount(ctx, userID); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return http.StatusNotFound, content{"error": fmt.Sprintf("User with id %d not found", userID)}
+		}
+		return http.StatusInternalServerError, content{"error": err.Error()}
+	}
+
+	// users that never authorized have no refresh token, nothing to delete
+	if err := s.SpotifyDAO.DeleteRefreshToken(userID); err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return http.StatusInternalServerError, content{"error": err.Error()}
+	}
+	s.Tokens.Forget(userID)
+
+	return http.StatusOK, content{"message": "Spotify account unlinked"}
+}
+
+func spotifyAccountFromProfile(profile *spotify.SpotifyProfile) model.SpotifyAccount {
+	// the country is only sent with the user-read-private scope
+	var country *string
+	if spotify.ValidMarket(profile.Country) {
+		market := spotify.MarketOrDefault(profile.Country)
+		country = &market
+	}
+	return model.SpotifyAccount{
+		SpotifyID:         profile.ID,
+		DisplayName:       profile.DisplayName,
+		ProfileURI:        profile.ProfileURI,
+		ProfilePictureURI: profile.ProfilePictureURI,
+		Country:           country,
+	}
+}
+
+// SpotifyErrorResponse maps a failed Spotify call to a response, rate limits and
+// outages carry a retry_after hint in seconds
+func SpotifyErrorResponse(err error) (int, content) {
+	var apiErr *spotify.APIError
+	switch {
+	case errors.Is(err, spotify.ErrUnauthorized):
+		return http.StatusUnauthorized, content{"error": err.Error()}
+	case errors.As(err, &apiErr) && apiErr.StatusCode == http.StatusTooManyRequests:
+		return http.StatusTooManyRequests, content{
+			"error":       "Spotify rate limit reached, try again later",
+			"retry_after": retryAfterSeconds(apiErr.RetryAfter),
+		}
+	case errors.As(err, &apiErr) && apiErr.StatusCode >= 500:
+		return http.StatusServiceUnavailable, content{
+			"error":       "Spotify is unavailable, try again later",
+			"retry_after": retryAfterSeconds(apiErr.RetryAfter),
+		}
+	case errors.As(err, &apiErr) && apiErr.StatusCode == http.StatusBadRequest:
+		// Spotify rejected what the client sent, e.g. an expired authorization code
+		return http.StatusBadRequest, content{"error": err.Error()}
+	default:
+		return http.StatusInternalServerError, content{"error": err.Error()}
+	}
+}
+
+// round up, a client retrying early just gets the same error
+func retryAfterSeconds(retryAfter time.Duration) int {
+	return max(int(math.Ceil(retryAfter.Seconds())), 1)
+}
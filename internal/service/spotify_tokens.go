@@ -0,0 +1,196 @@
+package service
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"net/url"
+	"sync"
+	"time"
+
+	"github.com/ranktify/ranktify-be/internal/dao"
+	"github.com/ranktify/ranktify-be/internal/model"
+	"github.com/ranktify/ranktify-be/internal/spotify"
+)
+
+// ErrSpotifyNotLinked is returned when the user never authorized ranktify on Spotify
+var ErrSpotifyNotLinked = errors.New("spotify account not linked")
+
+// tokens are refreshed this long before Spotify expires them, so a cached token
+// doesn't die mid request
+const accessTokenExpiryMargin = time.Minute
+
+type cachedAccessToken struct {
+	token     string
+	expiresAt time.Time
+}
+
+// access tokens are shared by every SpotifyTokenService, each route group builds its own
+var accessTokenCache = struct {
+	sync.Mutex
+	tokens map[uint64]cachedAccessToken
+}{tokens: make(map[uint64]cachedAccessToken)}
+
+// refreshes in flight by user, concurrent requests of a user wait for the same refresh
+// instead of each spending the refresh token, which Spotify may rotate
+var accessTokenRefreshes = struct {
+	sync.Mutex
+	calls map[uint64]*accessTokenRefresh
+}{calls: make(map[uint64]*accessTokenRefresh)}
+
+type accessTokenRefresh struct {
+	done  chan struct{}
+	token string
+	err   error
+}
+
+// SpotifyTokenService hands out Spotify access tokens for users, caching them until
+// they expire and refreshing them with the stored refresh token
+type SpotifyTokenService struct {
+	DAO      *dao.SpotifyDAO
+	Provider spotify.MusicProvider
+}
+
+func NewSpotifyTokenService(dao *dao.SpotifyDAO, provider spotify.MusicProvider) *SpotifyTokenService {
+	return &SpotifyTokenService{
+		DAO:      dao,
+		Provider: provider,
+	}
+}
+
+// AccessToken returns the user's cached access token, refreshing it when it's missing
+// or about to expire
+func (s *SpotifyTokenService) AccessToken(ctx context.Context, userID uint64) (string, error) {
+	accessTokenCache.Lock()
+	cached, ok := accessTokenCache.tokens[userID]
+	accessTokenCache.Unlock()
+
+	if ok && time.Now().Before(cached.expiresAt) {
+		return cached.token, nil
+	}
+	return s.Refresh(ctx, userID)
+}
+
+// Refresh exchanges the user's stored refresh token for a new access token. Concurrent
+// refreshes of a user share a single exchange and its result.
+func (s *SpotifyTokenService) Refresh(ctx context.Context, userID uint64) (string, error) {
+	accessTokenRefreshes.Lock()
+	call, inFlight := accessTokenRefreshes.calls[userID]
+	if !inFlight {
+		call = &accessTokenRefresh{done: make(chan struct{})}
+		accessTokenRefreshes.calls[userID] = call
+	}
+	accessTokenRefreshes.Unlock()
+
+	if !inFlight {
+		// not cancelled with this request, the requests waiting on it still need it
+		call.token, call.err = s.refresh(context.WithoutCancel(ctx), userID)
+
+		accessTokenRefreshes.Lock()
+		delete(accessTokenRefreshes.calls, userID)
+		accessTokenRefreshes.Unlock()
+		close(call.done)
+	}
+
+	select {
+	case <-call.done:
+		return call.token, call.err
+	case <-ctx.Done():
+		return "", ctx.Err()
+	}
+}
+
+// refresh does the exchange, storing the refresh token Spotify may rotate in the response
+func (s *SpotifyTokenService) refresh(ctx context.Context, userID uint64) (string, error) {
+	refreshToken, err := s.DAO.GetRefreshToken(userID)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return "", ErrSpotifyNotLinked
+		}
+		return "", err
+	}
+
+	formData := url.Values{}
+	formData.Set("grant_type", "refresh_token")
+	formData.Set("refresh_token", refreshToken)
+
+	tokenResponse, err := s.Provider.ExchangeToken(ctx, formData)
+	if err != nil {
+		return "", err
+	}
+
+	// If the api returned a new rt then update it in the database
+	if tokenResponse.RefreshToken != "" {
+		if err := s.DAO.UpdateRefreshToken(userID, tokenResponse.RefreshToken); err != nil {
+			return "", err
+		}
+	}
+
+	s.cache(userID, tokenResponse)
+	return tokenResponse.AccessToken, nil
+}
+
+// StoreAuthorization keeps the tokens of a completed authorization code exchange
+func (s *SpotifyTokenService) StoreAuthorization(userID uint64, tokenResponse *spotify.SpotifyAccessTokenResponse) error {
+	rt := model.SpotifyRefreshToken{
+		UserID: userID,
+		Token:  tokenResponse.RefreshToken,
+	}
+	if err := s.DAO.SaveRefreshToken(rt); err != nil {
+		return err
+	}
+
+	s.cache(userID, tokenResponse)
+	return nil
+}
+
+// Forget drops the user's cached access token
+func (s *SpotifyTokenService) Forget(userID uint64) {
+	accessTokenCache.Lock()
+	delete(accessTokenCache.tokens, userID)
+	accessTokenCache.Unlock()
+}
+
+// WithAccessToken calls fn with the given access token and, when Spotify rejects it,
+// once more with a refreshed one
+func (s *SpotifyTokenService) WithAccessToken(ctx context.Context, userID uint64, accessToken string, fn func(accessToken string) error) error {
+	err := fn(accessToken)
+	if !errors.Is(err, spotify.ErrUnauthorized) {
+		return err
+	}
+
+	refreshed, refreshErr := s.replaceAccessToken(ctx, userID, accessToken)
+	if refreshErr != nil {
+		// nothing to retry with, the original error explains it better
+		return err
+	}
+	return fn(refreshed)
+}
+
+// replaceAccessToken returns a token to use instead of the rejected one, the cached
+// token when another request already replaced it and a refreshed one otherwise
+func (s *SpotifyTokenService) replaceAccessToken(ctx context.Context, userID uint64, rejected string) (string, error) {
+	accessTokenCache.Lock()
+	cached, ok := accessTokenCache.tokens[userID]
+	if ok && cached.token == rejected {
+		delete(accessTokenCache.tokens, userID)
+		ok = false
+	}
+	accessTokenCache.Unlock()
+
+	if ok && time.Now().Before(cached.expiresAt) {
+		return cached.token, nil
+	}
+	return s.Refresh(ctx, userID)
+}
+
+func (s *SpotifyTokenService) cache(userID uint64, tokenResponse *spotify.SpotifyAccessTokenResponse) {
+	expiresIn := time.Duration(tokenResponse.ExpiresIn)*time.Second - accessTokenExpiryMargin
+
+	accessTokenCache.Lock()
+	accessTokenCache.tokens[userID] = cachedAccessToken{
+		token:     tokenResponse.AccessToken,
+		expiresAt: time.Now().Add(expiresIn),
+	}
+	accessTokenCache.Unlock()
+}
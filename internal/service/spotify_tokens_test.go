@@ -0,0 +1,137 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/ranktify/ranktify-be/internal/dao"
+	"github.com/ranktify/ranktify-be/internal/spotify"
+	"github.com/ranktify/ranktify-be/internal/spotify/spotifytest"
+	"github.com/ranktify/ranktify-be/internal/testutil"
+)
+
+// newTestTokenService returns a SpotifyTokenService backed by a mocked database and
+// the fake Spotify
+func newTestTokenService(t *testing.T) (*SpotifyTokenService, sqlmock.Sqlmock, *spotifytest.Server) {
+	db, mock := testutil.MockDB(t)
+	server := testutil.SpotifyServer(t)
+	tokens := NewSpotifyTokenService(&dao.SpotifyDAO{DB: db, Keyring: testutil.Keyring(t)}, server.Provider())
+	return tokens, mock, server
+}
+
+func TestWithAccessToken(t *testing.T) {
+	tests := []struct {
+		name          string
+		userID        uint64
+		accessToken   string
+		linked        bool
+		wantErr       error
+		wantTokens    []string
+		wantExchanges int
+	}{
+		{
+			name:        "accepted token",
+			userID:      1001,
+			accessToken: spotifytest.AccessToken,
+			wantTokens:  []string{spotifytest.AccessToken},
+		},
+		{
+			name:          "rejected token is refreshed and retried",
+			userID:        1002,
+			accessToken:   spotifytest.ExpiredAccessToken,
+			linked:        true,
+			wantTokens:    []string{spotifytest.ExpiredAccessToken, spotifytest.AccessToken},
+			wantExchanges: 1,
+		},
+		{
+			name:        "rejected token without a refresh token",
+			userID:      1003,
+			accessToken: spotifytest.ExpiredAccessToken,
+			linked:      false,
+			wantErr:     spotify.ErrUnauthorized,
+			wantTokens:  []string{spotifytest.ExpiredAccessToken},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tokens, mock, server := newTestTokenService(t)
+			tokens.Forget(tt.userID)
+			if tt.accessToken == spotifytest.ExpiredAccessToken {
+				testutil.ExpectRefreshToken(mock, tt.userID, tt.linked)
+			}
+
+			var usedTokens []string
+			err := tokens.WithAccessToken(context.Background(), tt.userID, tt.accessToken, func(accessToken string) error {
+				usedTokens = append(usedTokens, accessToken)
+				_, err := tokens.Provider.TopTracks(context.Background(), accessToken, 5)
+				return err
+			})
+
+			if !errors.Is(err, tt.wantErr) {
+				t.Errorf("WithAccessToken error = %v, want %v", err, tt.wantErr)
+			}
+			if len(usedTokens) != len(tt.wantTokens) {
+				t.Fatalf("called with %v, want %v", usedTokens, tt.wantTokens)
+			}
+			for i := range usedTokens {
+				if usedTokens[i] != tt.wantTokens[i] {
+					t.Errorf("call %d used %q, want %q", i, usedTokens[i], tt.wantTokens[i])
+				}
+			}
+			if exchanges := server.Count("POST /api/token"); exchanges != tt.wantExchanges {
+				t.Errorf("exchanged the refresh token %d times, want %d", exchanges, tt.wantExchanges)
+			}
+			if err := mock.ExpectationsWereMet(); err != nil {
+				t.Error(err)
+			}
+		})
+	}
+}
+
+func TestWithAccessTokenDoesNotRetryOtherErrors(t *testing.T) {
+	tokens, mock, server := newTestTokenService(t)
+	server.FailNext(1, http.StatusNotFound, 0)
+
+	calls := 0
+	err := tokens.WithAccessToken(context.Background(), 1004, spotifytest.AccessToken, func(accessToken string) error {
+		calls++
+		_, err := tokens.Provider.TopTracks(context.Background(), accessToken, 5)
+		return err
+	})
+	if err == nil || errors.Is(err, spotify.ErrUnauthorized) {
+		t.Errorf("WithAccessToken error = %v, want the 404", err)
+	}
+	if calls != 1 {
+		t.Errorf("called %d times, want 1", calls)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestWithAccessTokenUsesTokenRefreshedByAnotherRequest(t *testing.T) {
+	tokens, mock, _ := newTestTokenService(t)
+	const userID = 1005
+	// another request already replaced the expired token
+	tokens.cache(userID, &spotify.SpotifyAccessTokenResponse{AccessToken: spotifytest.AccessToken, ExpiresIn: 3600})
+
+	var usedTokens []string
+	err := tokens.WithAccessToken(context.Background(), userID, spotifytest.ExpiredAccessToken, func(accessToken string) error {
+		usedTokens = append(usedTokens, accessToken)
+		_, err := tokens.Provider.TopTracks(context.Background(), accessToken, 5)
+		return err
+	})
+	if err != nil {
+		t.Fatalf("WithAccessToken: %v", err)
+	}
+	if len(usedTokens) != 2 || usedTokens[1] != spotifytest.AccessToken {
+		t.Errorf("called with %v, want the cached token on the retry", usedTokens)
+	}
+	// no refresh, the mock has no query expected
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Error(err)
+	}
+}
@@ -1,9 +1,13 @@
 package service
 
 import (
+	"context"
 	"database/sql"
+	"errors"
 	"fmt"
+	"math/rand/v2"
 	"net/http"
+	"strings"
 
 	"github.com/ranktify/ranktify-be/internal/dao"
 	"github.com/ranktify/ranktify-be/internal/jwt"
@@ -12,17 +16,22 @@ import (
 )
 
 type UserService struct {
-	UserDAO   *dao.UserDAO
-	TokensDAO *dao.TokensDAO
+	UserDAO         *dao.UserDAO
+	TokensDAO       *dao.TokensDAO
+	SpotifyAccounts *SpotifyAccountService
 }
 
 // replaces gin.H, hence decoupling web framework from service layer
 type content map[string]any
 
-func NewUserService(userDAO *dao.UserDAO, tokensDAO *dao.TokensDAO) *UserService {
+// how many usernames are tried when the one derived from the Spotify profile is taken
+const spotifyUsernameAttempts = 5
+
+func NewUserService(userDAO *dao.UserDAO, tokensDAO *dao.TokensDAO, spotifyAccounts *SpotifyAccountService) *UserService {
 	return &UserService{
-		UserDAO:   userDAO,
-		TokensDAO: tokensDAO,
+		UserDAO:         userDAO,
+		TokensDAO:       tokensDAO,
+		SpotifyAccounts: spotifyAccounts,
 	}
 }
 
@@ -41,19 +50,15 @@ func (s *UserService) CreateUser(user *model.User) (int, content) {
 		return http.StatusInternalServerError, content{"error": err.Error()}
 	}
 	user.Password = string(bytes)
+	// roles are only granted in the database, never on sign up
+	user.Role = nil
 
 	if err = s.UserDAO.CreateUser(user); err != nil {
 		return http.StatusInternalServerError, content{"error": err.Error()}
 
 	}
 
-	accessToken, refreshToken := jwt.CreateTokens(*user)
-
-	rt, err := jwt.ParseRefreshTokenClaims(refreshToken)
-	if err != nil {
-		return http.StatusInternalServerError, content{"error": err.Error()}
-	}
-	err = s.TokensDAO.SaveJWTRefreshToken(rt)
+	accessToken, refreshToken, err := s.issueTokens(*user)
 	if err != nil {
 		return http.StatusInternalServerError, content{"error": err.Error()}
 	}
@@ -82,12 +87,7 @@ func (s *UserService) ValidateUser(user *model.User) (int, content) {
 		return http.StatusUnauthorized, content{"error": "Password is incorrect"}
 	}
 
-	accessToken, refreshToken := jwt.CreateTokens(*dbUser)
-	rt, err := jwt.ParseRefreshTokenClaims(refreshToken)
-	if err != nil {
-		return http.StatusInternalServerError, content{"error": err.Error()}
-	}
-	err = s.TokensDAO.SaveJWTRefreshToken(rt)
+	accessToken, refreshToken, err := s.issueTokens(*dbUser)
 	if err != nil {
 		return http.StatusInternalServerError, content{"error": err.Error()}
 	}
@@ -98,6 +98,131 @@ func (s *UserService) ValidateUser(user *model.User) (int, content) {
 	}
 }
 
+// LoginWithSpotify signs the user in with a Spotify authorization code, creating the
+// account on the first login. Spotify accounts whose email belongs to a password account
+// aren't merged, that user has to log in with the password and link Spotify instead.
+func (s *UserService) LoginWithSpotify(ctx context.Context, code string) (int, content) {
+	tokenResponse, profile, err := s.SpotifyAccounts.ExchangeCode(ctx, code)
+	if err != nil {
+		return SpotifyErrorResponse(err)
+	}
+
+	account := spotifyAccountFromProfile(profile)
+	statusCode := http.StatusOK
+	user, err := s.UserDAO.GetUserBySpotifyID(ctx, profile.ID)
+	if err != nil {
+		return http.StatusInternalServerError, content{"error": err.Error()}
+	}
+	if user != nil {
+		// keep the linked profile up to date
+		if err := s.UserDAO.LinkSpotifyAccount(ctx, user.Id, account); err != nil {
+			return http.StatusInternalServerError, content{"error": err.Error()}
+		}
+	} else {
+		if profile.Email == "" {
+			return http.StatusBadRequest, content{"error": "The Spotify account has no email, grant the user-read-email scope"}
+		}
+		// the email lookup ignores the username, it can't be empty
+		existing, err := s.UserDAO.GetUser(profile.Email, "")
+		if err != nil {
+			return http.StatusInternalServerError, content{"error": err.Error()}
+		}
+		if existing != nil {
+			return http.StatusConflict, content{
+				"error": "An account with this email already exists, log in with your password and link Spotify from your profile",
+			}
+		}
+
+		user, err = s.createSpotifyUser(ctx, profile.Email, account)
+		if err != nil {
+			if errors.Is(err, dao.ErrEmailTaken) || errors.Is(err, dao.ErrSpotifyAccountTaken) {
+				// another login got there first
+				return http.StatusConflict, content{"error": "User already exist"}
+			}
+			return http.StatusInternalServerError, content{"error": err.Error()}
+		}
+		statusCode = http.StatusCreated
+	}
+
+	if err := s.SpotifyAccounts.Tokens.StoreAuthorization(user.Id, tokenResponse); err != nil {
+		return http.StatusInternalServerError, content{"error": err.Error()}
+	}
+
+	accessToken, refreshToken, err := s.issueTokens(*user)
+	if err != nil {
+		return http.StatusInternalServerError, content{"error": err.Error()}
+	}
+	return statusCode, content{
+		"success":              fmt.Sprintf("Logged in user with id: %d", user.Id),
+		"access_token":         accessToken,
+		"refresh_token":        refreshToken,
+		"spotify_access_token": tokenResponse.AccessToken,
+	}
+}
+
+// Creates the user of a first Spotify login. The username comes from the Spotify id,
+// with a random suffix when it's taken. There is no password, bcrypt never matches an
+// empty hash so the account can only log in through Spotify.
+func (s *UserService) createSpotifyUser(ctx context.Context, email string, account model.SpotifyAccount) (*model.User, error) {
+	user := model.User{
+		Email:                    email,
+		SpotifyID:                &account.SpotifyID,
+		SpotifyDisplayName:       account.DisplayName,
+		SpotifyProfileURI:        account.ProfileURI,
+		SpotifyProfilePictureURI: account.ProfilePictureURI,
+		Market:                   account.Country,
+	}
+
+	baseUsername := spotifyUsername(account.SpotifyID)
+	user.Username = baseUsername
+	for range spotifyUsernameAttempts {
+		err := s.UserDAO.CreateSpotifyUser(ctx, &user)
+		if !errors.Is(err, dao.ErrUsernameTaken) {
+			if err != nil {
+				return nil, err
+			}
+			return &user, nil
+		}
+		user.Username = fmt.Sprintf("%s_%04d", baseUsername, rand.IntN(10000))
+	}
+	return nil, dao.ErrUsernameTaken
+}
+
+// Spotify ids are usernames for older accounts and random strings for newer ones, either
+// way only the characters a username allows are kept
+func spotifyUsername(spotifyID string) string {
+	username := strings.Map(func(r rune) rune {
+		switch {
+		case r >= 'a' && r <= 'z', r >= '0' && r <= '9', r == '_', r == '.':
+			return r
+		case r >= 'A' && r <= 'Z':
+			return r + ('a' - 'A')
+		default:
+			return -1
+		}
+	}, spotifyID)
+	if len(username) > 30 {
+		username = username[:30]
+	}
+	if username == "" {
+		username = "spotify_user"
+	}
+	return username
+}
+
+// Creates the JWT pair of the user, keeping the refresh token for rotation
+func (s *UserService) issueTokens(user model.User) (string, string, error) {
+	accessToken, refreshToken := jwt.CreateTokens(user)
+	rt, err := jwt.ParseRefreshTokenClaims(refreshToken)
+	if err != nil {
+		return "", "", err
+	}
+	if err := s.TokensDAO.SaveJWTRefreshToken(rt); err != nil {
+		return "", "", err
+	}
+	return accessToken, refreshToken, nil
+}
+
 func (s *UserService) GetUserByID(userID uint64) (int, content) {
 	user, err := s.UserDAO.GetUserByID(userID)
 	if err != nil {
@@ -123,7 +248,10 @@ func (s *UserService) GetAllUsers() (int, content) {
 	return http.StatusOK, content{"users": users}
 }
 
-func (s *UserService) UpdateUserByID(userID uint64, user *model.User) (int, content) {
+func (s *UserService) UpdateUserByID(callerID uint64, userID uint64, user *model.User) (int, content) {
+	if statusCode, body, ok := AuthorizeSelf(callerID, userID); !ok {
+		return statusCode, body
+	}
 	err := s.UserDAO.UpdateUserByID(userID, user)
 	if err != nil {
 		if err == sql.ErrNoRows {
@@ -136,7 +264,10 @@ func (s *UserService) UpdateUserByID(userID uint64, user *model.User) (int, cont
 	return http.StatusOK, content{"message": "User updated successfully"}
 }
 
-func (s *UserService) DeleteUserByID(userID uint64) (int, content) {
+func (s *UserService) DeleteUserByID(callerID uint64, userID uint64) (int, content) {
+	if statusCode, body, ok := AuthorizeSelf(callerID, userID); !ok {
+		return statusCode, body
+	}
 	err := s.UserDAO.DeleteUserByID(userID)
 	if err != nil {
 		if err == sql.ErrNoRows {
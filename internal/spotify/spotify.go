@@ -3,12 +3,14 @@ package spotify
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"log"
-	"math/rand"
 	"net/http"
+	"net/url"
 	"regexp"
+	"strconv"
 	"strings"
 	"time"
 
@@ -16,80 +18,44 @@ import (
 	"github.com/zmb3/spotify/v2"
 )
 
-var genres = []string{
-	// US market
-	"pop",
-	"hip%20hop",
-	"rock",
-	"country",
-	"edm",
-	"latin",
-	"r%20b",
-	"reggae",
-	"jazz",
-	"classical",
-	"rap",
-	"trap",
-	"metal",
-	"indie%20rock",
-	"alternative%20pop",
-	"alternative%20rock",
-	// Latin Market
-	"reggaeton",
-	"salsa",
-	"bachata",
-	"merengue",
-	"cumbia",
-	"ranchera",
-	"mariachi",
-	"vallenato",
-	"tango",
-	"bolero",
-	"latin%20pop",
-	"latin%20rock",
-	"latin%20trap",
-}
-
-var wildcards = []string{
-	"%25a%25", "a%25",
-	"%25e%25", "e%25",
-	"%25i%25", "i%25",
-	"%25o%25", "o%25",
-	"%25u%25", "u%25",
-}
+var scdnMP3PreviewRegex = regexp.MustCompile(`https://p\.scdn\.co/mp3-preview/[^"' >)]+`)
 
-var limit int = 50
-
-var (
-	scdnMP3PreviewRegex = regexp.MustCompile(`https://p\.scdn\.co/mp3-preview/[^"' >)]+`)
-
-	httpClient = &http.Client{
-		Timeout: 10 * time.Second,
+// Searches tracks, songs found through a genre search are tagged with that genre
+func (c *Client) SearchTracks(ctx context.Context, accessToken string, params SearchParams) ([]model.Song, error) {
+	market := MarketOrDefault(params.Market)
+	query := params.Query
+	if params.Genre != "" {
+		query = fmt.Sprintf("%s genre:%q", query, params.Genre)
 	}
-)
 
-func FetchRandomSongsByGenre(ctx context.Context, accessToken string, limit int, genre string, query string) ([]model.Song, error) {
-	offset := rand.Intn(limit) + 1
-	market := "US"
+	values := url.Values{}
+	values.Set("q", query)
+	values.Set("type", "track")
+	values.Set("offset", strconv.Itoa(params.Offset))
+	values.Set("limit", strconv.Itoa(params.Limit))
+	values.Set("market", market)
+	searchURL := c.APIBaseURL + "/search?" + values.Encode()
 
-	url := fmt.Sprintf("https://api.spotify.com/v1/search?q=%s%%20genre:%%22%s%%22&offset=%d&limit=%d&type=track&market=%s", query, genre, offset, limit, market)
-
-	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, searchURL, nil)
 	if err != nil {
 		return nil, err
 	}
 
 	accessToken = strings.TrimPrefix(accessToken, "Bearer ")
 	req.Header.Add("Authorization", "Bearer "+accessToken)
+	if params.Locale != "" {
+		// localizes the names Spotify has translations for
+		req.Header.Add("Accept-Language", params.Locale)
+	}
 
-	resp, err := http.DefaultClient.Do(req)
+	resp, err := c.HTTPClient.Do(req)
 	if err != nil {
 		return nil, err
 	}
 	defer resp.Body.Close()
 
 	if resp.StatusCode != http.StatusOK {
-		return nil, fmt.Errorf("failed to fetch songs, status: %d", resp.StatusCode)
+		return nil, newAPIError(resp)
 	}
 
 	body, err := io.ReadAll(resp.Body)
@@ -100,82 +66,109 @@ func FetchRandomSongsByGenre(ctx context.Context, accessToken string, limit int,
 	if err != nil {
 		return nil, fmt.Errorf("failed to store songs")
 	}
-	return songs, nil
-}
-
-func GetRandomGenre() string {
-	index := rand.Intn(len(genres))
 
-	return genres[index]
+	if params.Genre != "" {
+		for i := range songs {
+			genre := params.Genre
+			songs[i].Genre = &genre
+			songs[i].Genres = []string{genre}
+		}
+	}
+	return songs, nil
 }
 
 // returns top N songs from the user using the CurrentUsersTopTracks from zmb3 client
-// the PreviewURI, SongID, CreatedAt are ignored here
-func GetTopNSongs(ctx context.Context, client *spotify.Client, n int) ([]model.Song, error) {
+// the SongID, CreatedAt are ignored here. Spotify rarely sends previews anymore, the
+// PreviewService resolves the missing ones.
+func (c *Client) TopTracks(ctx context.Context, accessToken string, n int) ([]model.Song, error) {
+	client := c.userClient(ctx, accessToken)
+
 	// We have tree options for terms: long_term, medium_term, and short_term
 	// add spotify.Timerange(spotify.MediumTermRange) as parameter to "CurrentUsersTopTracks"
 	results, err := client.CurrentUsersTopTracks(ctx, spotify.Limit(n), spotify.Timerange(spotify.LongTermRange))
 	if err != nil {
-		return nil, err
+		return nil, wrapClientError(err)
 	}
 
 	songs := make([]model.Song, 0, len(results.Tracks))
-
 	for _, track := range results.Tracks {
-		var artistNamePtr *string
-		if len(track.Artists) > 0 {
-			name := track.Artists[0].Name
-			artistNamePtr = &name
-		}
+		songs = append(songs, songFromTrack(track.SimpleTrack, track.Album))
+	}
 
-		var albumNamePtr *string
-		if track.Album.Name != "" {
-			album := track.Album.Name
-			albumNamePtr = &album
-		}
+	return songs, nil
+}
 
-		var releaseDatePtr *time.Time
-		if track.Album.ReleaseDate != "" {
-			// Spotify gives release_date in YYYY[-MM[-DD]]
-			// Try parsing full date
-			if t, err := time.Parse("2006-01-02", track.Album.ReleaseDate); err == nil {
-				releaseDatePtr = &t
-			} else if t, err := time.Parse("2006-01", track.Album.ReleaseDate); err == nil {
-				releaseDatePtr = &t
-			} else if t, err := time.Parse("2006", track.Album.ReleaseDate); err == nil {
-				releaseDatePtr = &t
-			}
-		}
+// Maps a zmb3 track to a song, the SongID, CreatedAt are ignored here. The album is
+// passed apart since FullTrack shadows the SimpleTrack one, only its own gets decoded.
+func songFromTrack(track spotify.SimpleTrack, trackAlbum spotify.SimpleAlbum) model.Song {
+	var artistNamePtr *string
+	if len(track.Artists) > 0 {
+		name := track.Artists[0].Name
+		artistNamePtr = &name
+	}
 
-		// Cover image URI (take first image if available)
-		var coverURIPtr *string
-		if len(track.Album.Images) > 0 {
-			uri := track.Album.Images[0].URL
-			coverURIPtr = &uri
-		}
+	var albumNamePtr *string
+	if trackAlbum.Name != "" {
+		album := trackAlbum.Name
+		albumNamePtr = &album
+	}
 
-		artistNames := make([]string, len(track.Artists))
-		for j, a := range track.Artists {
-			artistNames[j] = a.Name
+	var releaseDatePtr *time.Time
+	if trackAlbum.ReleaseDate != "" {
+		// Spotify gives release_date in YYYY[-MM[-DD]]
+		// Try parsing full date
+		if t, err := time.Parse("2006-01-02", trackAlbum.ReleaseDate); err == nil {
+			releaseDatePtr = &t
+		} else if t, err := time.Parse("2006-01", trackAlbum.ReleaseDate); err == nil {
+			releaseDatePtr = &t
+		} else if t, err := time.Parse("2006", trackAlbum.ReleaseDate); err == nil {
+			releaseDatePtr = &t
 		}
-		prevURI := ScrapePreviewURI(ctx, client, track.Name, strings.Join(artistNames, ","))
-		songs = append(songs, model.Song{
-			SpotifyID:   track.ID.String(),
-			Title:       track.Name,
-			Artist:      artistNamePtr,
-			Album:       albumNamePtr,
+	}
+
+	// Cover image URI (take first image if available)
+	var coverURIPtr *string
+	if len(trackAlbum.Images) > 0 {
+		uri := trackAlbum.Images[0].URL
+		coverURIPtr = &uri
+	}
+
+	artists := make([]model.Artist, len(track.Artists))
+	for j, a := range track.Artists {
+		artists[j] = model.Artist{SpotifyID: a.ID.String(), Name: a.Name}
+	}
+
+	var previewURIPtr *string
+	if track.PreviewURL != "" {
+		previewURI := track.PreviewURL
+		previewURIPtr = &previewURI
+	}
+
+	var album *model.Album
+	if trackAlbum.ID != "" {
+		album = &model.Album{
+			SpotifyID:   trackAlbum.ID.String(),
+			Name:        trackAlbum.Name,
 			ReleaseDate: releaseDatePtr,
-			Genre:       nil, // genre not provided by track
 			CoverURI:    coverURIPtr,
-			PreviewURI:  &prevURI,
-			// SongID, CreatedAt are ignored here
-		})
+		}
 	}
 
-	return songs, nil
+	return model.Song{
+		SpotifyID:    track.ID.String(),
+		Title:        track.Name,
+		Artist:       artistNamePtr,
+		Album:        albumNamePtr,
+		ReleaseDate:  releaseDatePtr,
+		Genre:        nil, // genre not provided by track
+		CoverURI:     coverURIPtr,
+		PreviewURI:   previewURIPtr,
+		Artists:      artists,
+		AlbumDetails: album,
+	}
 }
 
-func extractSCDNLink(ctx context.Context, pageURL string) (string, error) {
+func (c *Client) extractSCDNLink(ctx context.Context, pageURL string) (string, error) {
 	req, err := http.NewRequestWithContext(ctx, "GET", pageURL, nil)
 	if err != nil {
 		return "", fmt.Errorf("failed to create request for %s: %w", pageURL, err)
@@ -183,7 +176,7 @@ func extractSCDNLink(ctx context.Context, pageURL string) (string, error) {
 	// Spotify might block default Go user agent, pretend to be a browser
 	req.Header.Set("User-Agent", "Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/91.0.4472.124 Safari/537.36")
 
-	resp, err := httpClient.Do(req)
+	resp, err := c.HTTPClient.Do(req)
 	if err != nil {
 		return "", fmt.Errorf("failed to fetch %s: %w", pageURL, err)
 	}
@@ -195,11 +188,6 @@ func extractSCDNLink(ctx context.Context, pageURL string) (string, error) {
 			log.Printf("Info: Spotify page not found (404) for %s", pageURL)
 			return "", nil
 		}
-		// Handle potential rate limiting on scraping
-		if resp.StatusCode == http.StatusTooManyRequests {
-			log.Printf("Warning: Rate limited while scraping %s", pageURL)
-			return "", fmt.Errorf("rate limited while scraping %s", pageURL)
-		}
 		return "", fmt.Errorf("failed to fetch %s: status code %d", pageURL, resp.StatusCode)
 	}
 
@@ -216,44 +204,38 @@ func extractSCDNLink(ctx context.Context, pageURL string) (string, error) {
 	return match, nil
 }
 
-func ScrapePreviewURI(ctx context.Context, client *spotify.Client, trackTitle string, trackArtist string) string {
+// Looks the track up in the market and scrapes the mp3 preview from its Spotify page,
+// empty when the track has none. An error means the lookup failed, not that there is
+// no preview.
+func (c *Client) PreviewURL(ctx context.Context, accessToken string, market string, trackTitle string, trackArtist string) (string, error) {
+	client := c.userClient(ctx, accessToken)
 	query := fmt.Sprintf("track:%s artist:%s", trackTitle, trackArtist)
 
 	opts := []spotify.RequestOption{
 		spotify.Limit(1), // Request only the top result
-		spotify.Market(spotify.CountryUSA),
+		spotify.Market(MarketOrDefault(market)),
 	}
 
 	results, err := client.Search(ctx, query, spotify.SearchTypeTrack, opts...)
 	if err != nil {
-		log.Printf("Error searching Spotify: %v", err)
-		return ""
+		return "", wrapClientError(err)
 	}
 
 	if results.Tracks == nil || len(results.Tracks.Tracks) == 0 {
-		log.Println("No tracks found matching the query.")
-		// Return success, but with an empty results slice
-		return ""
+		return "", nil
 	}
 
 	track := results.Tracks.Tracks[0]
 
 	var spotifyURL string
-	if url, ok := track.ExternalURLs["spotify"]; ok {
-		spotifyURL = url
+	if trackURL, ok := track.ExternalURLs["spotify"]; ok {
+		spotifyURL = trackURL
 	}
 	if spotifyURL == "" {
-		log.Println("No Spotify URL found for the track.")
-		return ""
-	}
-
-	audioURI, err := extractSCDNLink(ctx, spotifyURL)
-	if err != nil {
-		log.Printf("Error extracting SCDN links: %v", err)
-		return ""
+		return "", nil
 	}
 
-	return audioURI
+	return c.extractSCDNLink(ctx, spotifyURL)
 }
 
 func StoreSongs(body []byte) ([]model.Song, error) {
@@ -285,15 +267,33 @@ func StoreSongs(body []byte) ([]model.Song, error) {
 			coverURI = &item.Album.Images[0].URL
 		}
 
+		// keep every artist, not only the main one
+		artists := make([]model.Artist, len(item.Artists))
+		for i, artist := range item.Artists {
+			artists[i] = model.Artist{SpotifyID: artist.ID, Name: artist.Name}
+		}
+
+		var album *model.Album
+		if item.Album.ID != "" {
+			album = &model.Album{
+				SpotifyID:   item.Album.ID,
+				Name:        item.Album.Name,
+				ReleaseDate: releaseDate,
+				CoverURI:    coverURI,
+			}
+		}
+
 		song := model.Song{
-			SpotifyID:   item.ID,
-			Title:       item.Name,
-			Artist:      artistName,
-			Album:       &item.Album.Name,
-			ReleaseDate: releaseDate,
-			CoverURI:    coverURI,
-			PreviewURI:  item.PreviewURL,
-			CreatedAt:   time.Now(),
+			SpotifyID:    item.ID,
+			Title:        item.Name,
+			Artist:       artistName,
+			Album:        &item.Album.Name,
+			ReleaseDate:  releaseDate,
+			CoverURI:     coverURI,
+			PreviewURI:   item.PreviewURL,
+			CreatedAt:    time.Now(),
+			Artists:      artists,
+			AlbumDetails: album,
 		}
 
 		songs = append(songs, song)
@@ -301,61 +301,12 @@ func StoreSongs(body []byte) ([]model.Song, error) {
 	return songs, nil
 }
 
-func GetRandomSongs(ctx context.Context, accessToken string) (*[]model.Song, error) {
-	query := wildcards[rand.Intn(len(wildcards))]
-	offset := rand.Intn(limit) + 1
-	market := "US"
-
-	url := fmt.Sprintf("https://api.spotify.com/v1/search?q=%s&offset=%d&limit=%d&type=track&market=%s", query, offset, limit, market)
-
-	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
-	if err != nil {
-		return nil, err
-	}
-
-	accessToken = strings.TrimPrefix(accessToken, "Bearer ")
-	req.Header.Add("Authorization", "Bearer "+accessToken)
-
-	resp, err := http.DefaultClient.Do(req)
-	if err != nil {
-		return nil, err
-	}
-	defer resp.Body.Close()
-
-	if resp.StatusCode != http.StatusOK {
-		return nil, fmt.Errorf("failed to fetch songs, status: %d", resp.StatusCode)
+// Maps the zmb3 client's errors to the package's, so callers can tell an expired token
+// or a rate limit. Failures from the transport are already typed.
+func wrapClientError(err error) error {
+	var spotifyErr spotify.Error
+	if errors.As(err, &spotifyErr) {
+		return &APIError{StatusCode: spotifyErr.Status, Message: spotifyErr.Message}
 	}
-
-	body, err := io.ReadAll(resp.Body)
-	if err != nil {
-		return nil, err
-	}
-
-	songs, err := StoreSongs(body)
-	if err != nil {
-		return nil, fmt.Errorf("failed to store songs")
-	}
-
-	return &songs, nil
-}
-
-func GetRandomSongsByGenre(ctx context.Context, accessToken string, genre string) (*[]model.Song, error) {
-	randomWildcard := wildcards
-	i := rand.Intn(len(randomWildcard))
-
-	query1 := randomWildcard[i]
-	randomWildcard = append(randomWildcard[:i], randomWildcard[i+1:]...)
-	query2 := randomWildcard[rand.Intn(len(randomWildcard))]
-
-	firstBatch, err := FetchRandomSongsByGenre(ctx, accessToken, limit, genre, query1)
-	if err != nil {
-		return nil, fmt.Errorf("failed to get songs by random genre (batch1)")
-	}
-	secondBatch, err := FetchRandomSongsByGenre(ctx, accessToken, limit, genre, query2)
-	if err != nil {
-		return nil, fmt.Errorf("failed to get songs by random genre (batch2)")
-	}
-	songs := append(firstBatch, secondBatch...)
-
-	return &songs, nil
+	return err
 }
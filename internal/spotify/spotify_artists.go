@@ -0,0 +1,65 @@
+package spotify
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// maxArtistsPerRequest is the most ids the several artists endpoint accepts at once
+const maxArtistsPerRequest = 50
+
+// ArtistGenreResolver resolves the genres Spotify tags artists with, keyed by the
+// artist's spotify id. Artists without genres may be missing from the result.
+type ArtistGenreResolver interface {
+	ArtistGenres(ctx context.Context, artistIDs []string) (map[string][]string, error)
+}
+
+// ArtistGenres looks artists up through the several artists endpoint, authenticated
+// with the app's client credentials since no user is needed
+func (c *Client) ArtistGenres(ctx context.Context, artistIDs []string) (map[string][]string, error) {
+	accessToken, err := c.ClientCredentialsToken(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	genres := make(map[string][]string, len(artistIDs))
+	for start := 0; start < len(artistIDs); start += maxArtistsPerRequest {
+		end := min(start+maxArtistsPerRequest, len(artistIDs))
+
+		artistsURL := fmt.Sprintf("%s/artists?ids=%s", c.APIBaseURL, url.QueryEscape(strings.Join(artistIDs[start:end], ",")))
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, artistsURL, nil)
+		if err != nil {
+			return nil, err
+		}
+		req.Header.Add("Authorization", "Bearer "+accessToken)
+
+		resp, err := c.HTTPClient.Do(req)
+		if err != nil {
+			return nil, err
+		}
+		var artistsResp SpotifyArtistsResponse
+		if resp.StatusCode != http.StatusOK {
+			apiErr := newAPIError(resp)
+			resp.Body.Close()
+			return nil, apiErr
+		}
+		err = json.NewDecoder(resp.Body).Decode(&artistsResp)
+		resp.Body.Close()
+		if err != nil {
+			return nil, err
+		}
+
+		for _, artist := range artistsResp.Artists {
+			// unknown ids come back as null entries
+			if artist == nil || len(artist.Genres) == 0 {
+				continue
+			}
+			genres[artist.ID] = artist.Genres
+		}
+	}
+	return genres, nil
+}
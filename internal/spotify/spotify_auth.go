@@ -14,8 +14,6 @@ import (
 	"golang.org/x/oauth2"
 )
 
-const tokenEndpoint string = "https://accounts.spotify.com/api/token"
-
 func GetSpotifyClientID() string {
 	return os.Getenv("SPOTIFY_CLIENT_ID")
 }
@@ -28,26 +26,29 @@ func GetSpotifyRedirectURI() string {
 	return os.Getenv("SPOTIFY_REDIRECT_URI")
 }
 
-func FetchSpotifyToken(ctx context.Context, formData url.Values) (*SpotifyAccessTokenResponse, error) {
+// Exchanges an authorization code or refresh token (depending on the grant_type in
+// formData) for an access token
+func (c *Client) ExchangeToken(ctx context.Context, formData url.Values) (*SpotifyAccessTokenResponse, error) {
+	tokenEndpoint := c.AccountsBaseURL + "/api/token"
 	req, err := http.NewRequestWithContext(ctx, http.MethodPost, tokenEndpoint, strings.NewReader(formData.Encode()))
 	if err != nil {
 		return nil, err
 	}
 
-	credentials := fmt.Sprintf("%s:%s", GetSpotifyClientID(), GetSpotifySecret())
+	credentials := fmt.Sprintf("%s:%s", c.ClientID, c.ClientSecret)
 	encodedCredentials := base64.StdEncoding.EncodeToString([]byte(credentials))
 	req.Header.Add("Authorization", "Basic "+encodedCredentials)
 
 	req.Header.Add("Content-Type", "application/x-www-form-urlencoded")
 
-	resp, err := httpClient.Do(req)
+	resp, err := c.HTTPClient.Do(req)
 	if err != nil {
 		return nil, err
 	}
 	defer resp.Body.Close()
 
 	if resp.StatusCode != http.StatusOK {
-		return nil, fmt.Errorf("failed to fetch access token, status code not ok")
+		return nil, newAPIError(resp)
 	}
 
 	var tokenResponse SpotifyAccessTokenResponse
@@ -57,12 +58,50 @@ func FetchSpotifyToken(ctx context.Context, formData url.Values) (*SpotifyAccess
 	return &tokenResponse, nil
 }
 
-// Uses zmb3 spotify wrapper
-func SpotifyClientFromAccessToken(ctx context.Context, accessToken string) *spotify.Client {
+// Returns an app access token from the client credentials flow, enough for endpoints
+// that don't act on behalf of a user
+func (c *Client) ClientCredentialsToken(ctx context.Context) (string, error) {
+	formData := url.Values{}
+	formData.Set("grant_type", "client_credentials")
+
+	tokenResponse, err := c.ExchangeToken(ctx, formData)
+	if err != nil {
+		return "", err
+	}
+	return tokenResponse.AccessToken, nil
+}
+
+// Returns the profile of the user the access token belongs to
+func (c *Client) CurrentUser(ctx context.Context, accessToken string) (*SpotifyProfile, error) {
+	user, err := c.userClient(ctx, accessToken).CurrentUser(ctx)
+	if err != nil {
+		return nil, wrapClientError(err)
+	}
+
+	profile := SpotifyProfile{
+		ID:      user.ID,
+		Email:   user.Email,
+		Country: user.Country,
+	}
+	if user.DisplayName != "" {
+		profile.DisplayName = &user.DisplayName
+	}
+	if profileURI, ok := user.ExternalURLs["spotify"]; ok {
+		profile.ProfileURI = &profileURI
+	}
+	if len(user.Images) > 0 {
+		profile.ProfilePictureURI = &user.Images[0].URL
+	}
+	return &profile, nil
+}
+
+// Uses zmb3 spotify wrapper, pointed at the client's base url
+func (c *Client) userClient(ctx context.Context, accessToken string) *spotify.Client {
 	src := oauth2.StaticTokenSource(&oauth2.Token{
-		AccessToken: accessToken,
+		AccessToken: strings.TrimPrefix(accessToken, "Bearer "),
 		TokenType:   "Bearer",
 	})
 
-	return spotify.New(oauth2.NewClient(ctx, src))
+	ctx = context.WithValue(ctx, oauth2.HTTPClient, c.HTTPClient)
+	return spotify.New(oauth2.NewClient(ctx, src), spotify.WithBaseURL(c.APIBaseURL+"/"))
 }
@@ -0,0 +1,114 @@
+package spotify
+
+import (
+	"context"
+	"errors"
+
+	"github.com/ranktify/ranktify-be/internal/model"
+	"github.com/zmb3/spotify/v2"
+)
+
+const (
+	// the most items the playlist items and saved tracks endpoints return per page
+	maxPlaylistItemsPerPage = 100
+	maxSavedTracksPerPage   = 50
+	// Spotify only keeps the last 50 plays
+	maxRecentlyPlayed = 50
+)
+
+// LibraryReader reads the tracks of the user's playlists and library, in the order
+// Spotify lists them. Episodes and local files are skipped, tracks are relinked to the
+// version playable in the market.
+type LibraryReader interface {
+	PlaylistTracks(ctx context.Context, accessToken string, market string, playlistID string, max int) ([]model.Song, error)
+	SavedTracks(ctx context.Context, accessToken string, market string, max int) ([]model.Song, error)
+	RecentlyPlayedTracks(ctx context.Context, accessToken string) ([]model.Song, error)
+}
+
+// PlaylistTracks pages through the playlist, returning up to max of its tracks
+func (c *Client) PlaylistTracks(ctx context.Context, accessToken string, market string, playlistID string, max int) ([]model.Song, error) {
+	client := c.userClient(ctx, accessToken)
+
+	page, err := client.GetPlaylistItems(ctx, spotify.ID(playlistID),
+		spotify.Limit(maxPlaylistItemsPerPage),
+		spotify.Market(MarketOrDefault(market)),
+	)
+	if err != nil {
+		return nil, wrapClientError(err)
+	}
+
+	var songs []model.Song
+	for {
+		for _, item := range page.Items {
+			if item.IsLocal || item.Track.Track == nil || item.Track.Track.ID == "" {
+				continue
+			}
+			songs = append(songs, songFromTrack(item.Track.Track.SimpleTrack, item.Track.Track.Album))
+			if len(songs) == max {
+				return songs, nil
+			}
+		}
+
+		err = client.NextPage(ctx, page)
+		if errors.Is(err, spotify.ErrNoMorePages) {
+			return songs, nil
+		}
+		if err != nil {
+			return nil, wrapClientError(err)
+		}
+	}
+}
+
+// SavedTracks pages through the user's liked songs, most recently liked first,
+// returning up to max of them
+func (c *Client) SavedTracks(ctx context.Context, accessToken string, market string, max int) ([]model.Song, error) {
+	client := c.userClient(ctx, accessToken)
+
+	page, err := client.CurrentUsersTracks(ctx,
+		spotify.Limit(maxSavedTracksPerPage),
+		spotify.Market(MarketOrDefault(market)),
+	)
+	if err != nil {
+		return nil, wrapClientError(err)
+	}
+
+	var songs []model.Song
+	for {
+		for _, savedTrack := range page.Tracks {
+			songs = append(songs, songFromTrack(savedTrack.SimpleTrack, savedTrack.Album))
+			if len(songs) == max {
+				return songs, nil
+			}
+		}
+
+		err = client.NextPage(ctx, page)
+		if errors.Is(err, spotify.ErrNoMorePages) {
+			return songs, nil
+		}
+		if err != nil {
+			return nil, wrapClientError(err)
+		}
+	}
+}
+
+// RecentlyPlayedTracks returns the user's last plays, most recent first, without
+// repeating tracks played more than once
+func (c *Client) RecentlyPlayedTracks(ctx context.Context, accessToken string) ([]model.Song, error) {
+	client := c.userClient(ctx, accessToken)
+
+	items, err := client.PlayerRecentlyPlayedOpt(ctx, &spotify.RecentlyPlayedOptions{Limit: maxRecentlyPlayed})
+	if err != nil {
+		return nil, wrapClientError(err)
+	}
+
+	seen := make(map[spotify.ID]bool, len(items))
+	songs := make([]model.Song, 0, len(items))
+	for _, item := range items {
+		if seen[item.Track.ID] {
+			continue
+		}
+		seen[item.Track.ID] = true
+		songs = append(songs, songFromTrack(item.Track, item.Track.Album))
+	}
+	return songs, nil
+}
@@ -0,0 +1,34 @@
+package spotify
+
+import "strings"
+
+const defaultMarket string = "US"
+
+// Region is where the user listens from, Market picks the playable version of tracks
+// and the genres offered, Locale (optional) localizes the names Spotify translates
+type Region struct {
+	Market string
+	Locale string
+}
+
+// MarketOrDefault returns the market upper cased, the US when it's empty
+func MarketOrDefault(market string) string {
+	if market == "" {
+		return defaultMarket
+	}
+	return strings.ToUpper(market)
+}
+
+// ValidMarket reports whether market looks like an ISO 3166-1 alpha-2 country code,
+// Spotify answers 400 to the ones it doesn't sell in
+func ValidMarket(market string) bool {
+	if len(market) != 2 {
+		return false
+	}
+	for _, r := range market {
+		if (r < 'A' || r > 'Z') && (r < 'a' || r > 'z') {
+			return false
+		}
+	}
+	return true
+}
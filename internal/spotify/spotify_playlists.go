@@ -0,0 +1,56 @@
+package spotify
+
+import (
+	"context"
+
+	"github.com/zmb3/spotify/v2"
+)
+
+// the most tracks a playlist replace or add request accepts
+const maxPlaylistTracksPerRequest = 100
+
+// PlaylistWriter creates and fills playlists on the user's account, it needs the
+// playlist-modify-private scope
+type PlaylistWriter interface {
+	CreatePlaylist(ctx context.Context, accessToken string, spotifyUserID string, name string, description string) (string, error)
+	SetPlaylistTracks(ctx context.Context, accessToken string, playlistID string, trackIDs []string) error
+}
+
+// CreatePlaylist creates a private playlist owned by the user, returning its id
+func (c *Client) CreatePlaylist(ctx context.Context, accessToken string, spotifyUserID string, name string, description string) (string, error) {
+	client := c.userClient(ctx, accessToken)
+
+	playlist, err := client.CreatePlaylistForUser(ctx, spotifyUserID, name, description, false, false)
+	if err != nil {
+		return "", wrapClientError(err)
+	}
+	return playlist.ID.String(), nil
+}
+
+// SetPlaylistTracks replaces the playlist's tracks with trackIDs, in order, no tracks
+// empties it. Spotify caps each request so the first batch replaces and the rest are
+// appended.
+func (c *Client) SetPlaylistTracks(ctx context.Context, accessToken string, playlistID string, trackIDs []string) error {
+	client := c.userClient(ctx, accessToken)
+
+	ids := make([]spotify.ID, len(trackIDs))
+	for i, trackID := range trackIDs {
+		ids[i] = spotify.ID(trackID)
+	}
+
+	first := min(maxPlaylistTracksPerRequest, len(ids))
+	uris := make([]spotify.URI, first)
+	for i, id := range ids[:first] {
+		uris[i] = spotify.URI("spotify:track:" + id)
+	}
+	if _, err := client.ReplacePlaylistItems(ctx, spotify.ID(playlistID), uris...); err != nil {
+		return wrapClientError(err)
+	}
+	for start := first; start < len(ids); start += maxPlaylistTracksPerRequest {
+		end := min(start+maxPlaylistTracksPerRequest, len(ids))
+		if _, err := client.AddTracksToPlaylist(ctx, spotify.ID(playlistID), ids[start:end]...); err != nil {
+			return wrapClientError(err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,90 @@
+package spotify
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+
+	"github.com/ranktify/ranktify-be/internal/model"
+)
+
+const (
+	defaultAPIBaseURL      string = "https://api.spotify.com/v1"
+	defaultAccountsBaseURL string = "https://accounts.spotify.com"
+)
+
+// ErrUnauthorized is returned when Spotify rejects the access token, usually because
+// it expired
+var ErrUnauthorized = errors.New("spotify access token rejected")
+
+// SearchParams describes a track search, Query and Genre are raw (not url encoded)
+// and Spotify's % wildcards are allowed in Query
+type SearchParams struct {
+	Query  string
+	Genre  string // optional, narrows the search with a genre: filter
+	Offset int
+	Limit  int
+	Market string // ISO 3166-1 alpha-2, defaults to the US
+	Locale string // optional, sent as Accept-Language
+}
+
+// MusicProvider is everything ranktify needs from Spotify. Client talks to the real
+// API, spotifytest serves the same endpoints from canned fixtures.
+type MusicProvider interface {
+	SearchTracks(ctx context.Context, accessToken string, params SearchParams) ([]model.Song, error)
+	TopTracks(ctx context.Context, accessToken string, n int) ([]model.Song, error)
+	CurrentUser(ctx context.Context, accessToken string) (*SpotifyProfile, error)
+	ExchangeToken(ctx context.Context, formData url.Values) (*SpotifyAccessTokenResponse, error)
+	PreviewURL(ctx context.Context, accessToken string, market string, trackTitle string, trackArtist string) (string, error)
+	ClientCredentialsToken(ctx context.Context) (string, error)
+	ArtistGenreResolver
+	LibraryReader
+	PlaylistWriter
+}
+
+// Client is the MusicProvider backed by the Spotify Web API and accounts service
+type Client struct {
+	APIBaseURL      string
+	AccountsBaseURL string
+	ClientID        string
+	ClientSecret    string
+	HTTPClient      *http.Client
+}
+
+var _ MusicProvider = (*Client)(nil)
+
+// NewClient returns a Client configured from the environment, SPOTIFY_API_URL and
+// SPOTIFY_ACCOUNTS_URL point it somewhere other than Spotify (e.g. the fake server)
+func NewClient() *Client {
+	return &Client{
+		APIBaseURL:      GetSpotifyAPIBaseURL(),
+		AccountsBaseURL: GetSpotifyAccountsBaseURL(),
+		ClientID:        GetSpotifyClientID(),
+		ClientSecret:    GetSpotifySecret(),
+		HTTPClient: &http.Client{
+			Timeout:   clientTimeout,
+			Transport: sharedTransport,
+		},
+	}
+}
+
+// GetSpotifyAPIBaseURL returns the Web API base url, SPOTIFY_API_URL overrides it
+// so a local server can stand in for Spotify.
+func GetSpotifyAPIBaseURL() string {
+	if baseURL := os.Getenv("SPOTIFY_API_URL"); baseURL != "" {
+		return strings.TrimSuffix(baseURL, "/")
+	}
+	return defaultAPIBaseURL
+}
+
+// GetSpotifyAccountsBaseURL returns the accounts service base url, overridden by
+// SPOTIFY_ACCOUNTS_URL
+func GetSpotifyAccountsBaseURL() string {
+	if baseURL := os.Getenv("SPOTIFY_ACCOUNTS_URL"); baseURL != "" {
+		return strings.TrimSuffix(baseURL, "/")
+	}
+	return defaultAccountsBaseURL
+}
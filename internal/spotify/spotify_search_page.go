@@ -0,0 +1,74 @@
+package spotify
+
+import (
+	"context"
+	"math/rand"
+	"slices"
+
+	"github.com/ranktify/ranktify-be/internal/model"
+)
+
+const (
+	// Spotify serves search results up to this offset, limit included
+	maxSearchOffset = 1000
+
+	// random searches start somewhere in the first results of their wildcard
+	randomSearchOffsets = 50
+)
+
+// the queries random searches pick from, they match about any track
+var wildcards = []string{
+	"%a%", "a%",
+	"%e%", "e%",
+	"%i%", "i%",
+	"%o%", "o%",
+	"%u%", "u%",
+}
+
+// SearchCursor is where a random search continues, following it pages through the
+// same results so a client asking for more doesn't get repeats
+type SearchCursor struct {
+	Query  string `json:"q"`
+	Genre  string `json:"g,omitempty"`
+	Offset int    `json:"o"`
+}
+
+// RandomSearch starts a search from a random wildcard and offset, narrowed to the
+// genre when it isn't empty
+func RandomSearch(genre string) SearchCursor {
+	return SearchCursor{
+		Query:  wildcards[rand.Intn(len(wildcards))],
+		Genre:  genre,
+		Offset: rand.Intn(randomSearchOffsets),
+	}
+}
+
+// Valid reports whether the cursor could have come from RandomSearch and SearchPage,
+// cursors are sent back by clients
+func (c SearchCursor) Valid() bool {
+	return slices.Contains(wildcards, c.Query) && c.Offset >= 0 && c.Offset < maxSearchOffset
+}
+
+// SearchPage fetches up to limit songs from where the cursor points, next is nil once
+// the search has no more results
+func SearchPage(ctx context.Context, provider MusicProvider, accessToken string, region Region, cursor SearchCursor, limit int) ([]model.Song, *SearchCursor, error) {
+	limit = min(limit, maxSearchOffset-cursor.Offset)
+	songs, err := provider.SearchTracks(ctx, accessToken, SearchParams{
+		Query:  cursor.Query,
+		Genre:  cursor.Genre,
+		Offset: cursor.Offset,
+		Limit:  limit,
+		Market: region.Market,
+		Locale: region.Locale,
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+
+	next := cursor
+	next.Offset += limit
+	if len(songs) < limit || next.Offset >= maxSearchOffset {
+		return songs, nil, nil
+	}
+	return songs, &next, nil
+}
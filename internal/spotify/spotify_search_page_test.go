@@ -0,0 +1,143 @@
+package spotify
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/ranktify/ranktify-be/internal/model"
+)
+
+// searchStub has total results for any query, the other MusicProvider methods aren't
+// used by SearchPage
+type searchStub struct {
+	MusicProvider
+	total int
+	err   error
+	calls []SearchParams
+}
+
+func (s *searchStub) SearchTracks(ctx context.Context, accessToken string, params SearchParams) ([]model.Song, error) {
+	s.calls = append(s.calls, params)
+	if s.err != nil {
+		return nil, s.err
+	}
+	var songs []model.Song
+	for i := params.Offset; i < min(params.Offset+params.Limit, s.total); i++ {
+		songs = append(songs, model.Song{SpotifyID: fmt.Sprintf("track-%d", i)})
+	}
+	return songs, nil
+}
+
+func TestSearchPage(t *testing.T) {
+	tests := []struct {
+		name      string
+		total     int
+		offset    int
+		limit     int
+		wantSongs int
+		wantLimit int
+		wantNext  *int
+	}{
+		{name: "full page", total: 100, offset: 0, limit: 20, wantSongs: 20, wantLimit: 20, wantNext: ptr(20)},
+		{name: "continues from the cursor", total: 100, offset: 40, limit: 20, wantSongs: 20, wantLimit: 20, wantNext: ptr(60)},
+		{name: "short page is the last", total: 50, offset: 40, limit: 20, wantSongs: 10, wantLimit: 20},
+		{name: "no results", total: 0, offset: 0, limit: 20, wantSongs: 0, wantLimit: 20},
+		{name: "limit clamped to Spotify's max offset", total: 2000, offset: 990, limit: 20, wantSongs: 10, wantLimit: 10},
+		{name: "reaching the max offset is the last", total: 2000, offset: 980, limit: 20, wantSongs: 20, wantLimit: 20},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			provider := &searchStub{total: tt.total}
+			cursor := SearchCursor{Query: "%a%", Genre: "salsa", Offset: tt.offset}
+			region := Region{Market: "PR", Locale: "es-PR"}
+
+			songs, next, err := SearchPage(context.Background(), provider, "token", region, cursor, tt.limit)
+			if err != nil {
+				t.Fatalf("SearchPage: %v", err)
+			}
+			if len(songs) != tt.wantSongs {
+				t.Errorf("got %d songs, want %d", len(songs), tt.wantSongs)
+			}
+
+			want := SearchParams{Query: "%a%", Genre: "salsa", Offset: tt.offset, Limit: tt.wantLimit, Market: "PR", Locale: "es-PR"}
+			if len(provider.calls) != 1 || provider.calls[0] != want {
+				t.Errorf("searched %+v, want %+v", provider.calls, want)
+			}
+
+			switch {
+			case tt.wantNext == nil && next != nil:
+				t.Errorf("next = %+v, want the last page", *next)
+			case tt.wantNext != nil && next == nil:
+				t.Errorf("next = nil, want offset %d", *tt.wantNext)
+			case tt.wantNext != nil:
+				if next.Offset != *tt.wantNext || next.Query != cursor.Query || next.Genre != cursor.Genre {
+					t.Errorf("next = %+v, want the same search at offset %d", *next, *tt.wantNext)
+				}
+			}
+		})
+	}
+}
+
+func TestSearchPageFollowsCursorWithoutRepeats(t *testing.T) {
+	provider := &searchStub{total: 95}
+	cursor := SearchCursor{Query: "%e%", Offset: 0}
+	seen := make(map[string]bool)
+
+	for pages := 0; ; pages++ {
+		if pages > 10 {
+			t.Fatal("the cursor never ran out")
+		}
+		songs, next, err := SearchPage(context.Background(), provider, "token", Region{}, cursor, 20)
+		if err != nil {
+			t.Fatalf("SearchPage: %v", err)
+		}
+		for _, song := range songs {
+			if seen[song.SpotifyID] {
+				t.Fatalf("%s served twice", song.SpotifyID)
+			}
+			seen[song.SpotifyID] = true
+		}
+		if next == nil {
+			break
+		}
+		cursor = *next
+	}
+	if len(seen) != 95 {
+		t.Errorf("served %d songs, want all 95", len(seen))
+	}
+}
+
+func TestSearchPageError(t *testing.T) {
+	provider := &searchStub{err: errors.New("search failed")}
+	songs, next, err := SearchPage(context.Background(), provider, "token", Region{}, RandomSearch(""), 20)
+	if err == nil || songs != nil || next != nil {
+		t.Errorf("SearchPage = (%v, %v, %v), want the provider's error", songs, next, err)
+	}
+}
+
+func TestSearchCursorValid(t *testing.T) {
+	tests := []struct {
+		name   string
+		cursor SearchCursor
+		want   bool
+	}{
+		{name: "random search", cursor: RandomSearch("rock"), want: true},
+		{name: "deep offset", cursor: SearchCursor{Query: "u%", Offset: maxSearchOffset - 1}, want: true},
+		{name: "unknown query", cursor: SearchCursor{Query: "artist:anyone", Offset: 0}, want: false},
+		{name: "negative offset", cursor: SearchCursor{Query: "%a%", Offset: -1}, want: false},
+		{name: "past the max offset", cursor: SearchCursor{Query: "%a%", Offset: maxSearchOffset}, want: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.cursor.Valid(); got != tt.want {
+				t.Errorf("%+v.Valid() = %v, want %v", tt.cursor, got, tt.want)
+			}
+		})
+	}
+}
+
+func ptr(n int) *int {
+	return &n
+}
@@ -0,0 +1,283 @@
+package spotify
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+const (
+	// idempotent requests are retried this many times on 429, 5xx and network errors
+	maxRetries = 3
+	// backoff starts here and doubles per attempt up to maxBackoff, with full jitter
+	baseBackoff = 200 * time.Millisecond
+	maxBackoff  = 2 * time.Second
+	// a Retry-After longer than this isn't waited out, the caller gets the 429
+	maxRetryAfterWait = 5 * time.Second
+
+	// NewClient's timeout covers the whole request, retries included
+	clientTimeout = 10 * time.Second
+	// no retry is waited for past this much of the request, so the last attempt still
+	// fits in clientTimeout and the caller gets Spotify's error instead of a timeout
+	retryBudget = 6 * time.Second
+
+	// process-wide token bucket, Spotify's limit is per app
+	requestsPerSecond = 10
+	requestsBurst     = 20
+
+	// the breaker opens after this many failures (5xx and network errors) in a row and
+	// lets a probe through after the cooldown, rate limits don't count
+	breakerFailureThreshold = 10
+	breakerCooldown         = 30 * time.Second
+)
+
+// ErrCircuitOpen is wrapped by the APIError returned while Spotify is considered down
+var ErrCircuitOpen = errors.New("spotify is unavailable, circuit breaker open")
+
+// APIError is a request Spotify failed, RetryAfter is set when it's worth retrying
+// later (rate limits and the open circuit breaker)
+type APIError struct {
+	StatusCode int
+	RetryAfter time.Duration
+	Message    string
+	err        error
+}
+
+func (e *APIError) Error() string {
+	if e.Message != "" {
+		return fmt.Sprintf("spotify request failed, status: %d: %s", e.StatusCode, e.Message)
+	}
+	return fmt.Sprintf("spotify request failed, status: %d", e.StatusCode)
+}
+
+func (e *APIError) Unwrap() error {
+	return e.err
+}
+
+// a 401 is an ErrUnauthorized, whichever call returned it
+func (e *APIError) Is(target error) bool {
+	return target == ErrUnauthorized && e.StatusCode == http.StatusUnauthorized
+}
+
+// newAPIError reads the failed response, its body is consumed
+func newAPIError(resp *http.Response) *APIError {
+	body, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+	return &APIError{
+		StatusCode: resp.StatusCode,
+		RetryAfter: parseRetryAfter(resp),
+		Message:    string(body),
+	}
+}
+
+// NewTransport wraps base with retries, the rate limit and a circuit breaker per host.
+// Clients built by NewClient share one, so the limits are process-wide.
+func NewTransport(base http.RoundTripper) http.RoundTripper {
+	return &resilientTransport{
+		base:     base,
+		limiter:  newTokenBucket(requestsPerSecond, requestsBurst),
+		breakers: make(map[string]*circuitBreaker),
+	}
+}
+
+var sharedTransport = NewTransport(http.DefaultTransport)
+
+type resilientTransport struct {
+	base    http.RoundTripper
+	limiter *tokenBucket
+
+	mu       sync.Mutex
+	breakers map[string]*circuitBreaker
+}
+
+func (t *resilientTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	breaker := t.breaker(req.URL.Host)
+	retryable := req.Method == http.MethodGet || req.Method == http.MethodHead
+	start := time.Now()
+
+	for attempt := 0; ; attempt++ {
+		if wait, ok := breaker.allow(); !ok {
+			return nil, &APIError{
+				StatusCode: http.StatusServiceUnavailable,
+				RetryAfter: wait,
+				Message:    ErrCircuitOpen.Error(),
+				err:        ErrCircuitOpen,
+			}
+		}
+		if err := t.limiter.wait(req.Context()); err != nil {
+			breaker.release()
+			return nil, err
+		}
+
+		resp, err := t.base.RoundTrip(req)
+		if err != nil && req.Context().Err() != nil {
+			// the caller gave up, that says nothing about Spotify
+			breaker.release()
+			return nil, err
+		}
+		rateLimited := err == nil && resp.StatusCode == http.StatusTooManyRequests
+		if rateLimited {
+			// Spotify is up, Retry-After alone decides when to try again
+			breaker.release()
+		} else {
+			breaker.record(err == nil && resp.StatusCode < 500)
+		}
+		if err == nil && !rateLimited && resp.StatusCode < 500 {
+			return resp, nil
+		}
+
+		var wait time.Duration
+		if err == nil {
+			wait = parseRetryAfter(resp)
+		}
+		if wait == 0 {
+			wait = backoff(attempt)
+		}
+		if !retryable || attempt == maxRetries || wait > maxRetryAfterWait || time.Since(start)+wait > retryBudget {
+			if rateLimited {
+				// rate limited for good, surface the typed error with the hint
+				apiErr := newAPIError(resp)
+				resp.Body.Close()
+				return nil, apiErr
+			}
+			return resp, err
+		}
+		if err == nil {
+			io.Copy(io.Discard, resp.Body)
+			resp.Body.Close()
+		}
+
+		select {
+		case <-req.Context().Done():
+			return nil, req.Context().Err()
+		case <-time.After(wait):
+		}
+	}
+}
+
+func (t *resilientTransport) breaker(host string) *circuitBreaker {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	breaker, ok := t.breakers[host]
+	if !ok {
+		breaker = &circuitBreaker{}
+		t.breakers[host] = breaker
+	}
+	return breaker
+}
+
+// full jitter, a random wait between 0 and the exponential backoff
+func backoff(attempt int) time.Duration {
+	ceiling := min(baseBackoff<<attempt, maxBackoff)
+	return time.Duration(rand.Int63n(int64(ceiling)))
+}
+
+// Retry-After comes in seconds from Spotify, HTTP dates are accepted too
+func parseRetryAfter(resp *http.Response) time.Duration {
+	header := resp.Header.Get("Retry-After")
+	if header == "" {
+		return 0
+	}
+	if seconds, err := strconv.Atoi(header); err == nil && seconds >= 0 {
+		return time.Duration(seconds) * time.Second
+	}
+	if date, err := http.ParseTime(header); err == nil {
+		return max(time.Until(date), 0)
+	}
+	return 0
+}
+
+type tokenBucket struct {
+	mu       sync.Mutex
+	rate     float64
+	capacity float64
+	tokens   float64
+	last     time.Time
+}
+
+func newTokenBucket(rate float64, capacity float64) *tokenBucket {
+	return &tokenBucket{
+		rate:     rate,
+		capacity: capacity,
+		tokens:   capacity,
+		last:     time.Now(),
+	}
+}
+
+// wait blocks until a token is available or the context is done
+func (b *tokenBucket) wait(ctx context.Context) error {
+	for {
+		b.mu.Lock()
+		now := time.Now()
+		b.tokens = min(b.capacity, b.tokens+now.Sub(b.last).Seconds()*b.rate)
+		b.last = now
+		if b.tokens >= 1 {
+			b.tokens--
+			b.mu.Unlock()
+			return nil
+		}
+		wait := time.Duration((1 - b.tokens) / b.rate * float64(time.Second))
+		b.mu.Unlock()
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(wait):
+		}
+	}
+}
+
+// circuitBreaker is closed until breakerFailureThreshold failures in a row, then open
+// for breakerCooldown, after which one probe request decides whether it closes again
+type circuitBreaker struct {
+	mu        sync.Mutex
+	failures  int
+	openUntil time.Time
+	probing   bool
+}
+
+// allow reports whether a request may go through, or how long until it could
+func (b *circuitBreaker) allow() (time.Duration, bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.failures < breakerFailureThreshold {
+		return 0, true
+	}
+	if wait := time.Until(b.openUntil); wait > 0 {
+		return wait, false
+	}
+	// half open, a single probe at a time
+	if b.probing {
+		return breakerCooldown, false
+	}
+	b.probing = true
+	return 0, true
+}
+
+// release gives back a probe that was never sent
+func (b *circuitBreaker) release() {
+	b.mu.Lock()
+	b.probing = false
+	b.mu.Unlock()
+}
+
+func (b *circuitBreaker) record(success bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.probing = false
+	if success {
+		b.failures = 0
+		return
+	}
+	b.failures++
+	if b.failures >= breakerFailureThreshold {
+		b.openUntil = time.Now().Add(breakerCooldown)
+	}
+}
@@ -0,0 +1,253 @@
+package spotify
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+)
+
+// scriptedTransport answers each request with the next scripted status, -1 is a
+// network error
+type scriptedTransport struct {
+	statuses   []int
+	retryAfter string
+	calls      int
+}
+
+func (s *scriptedTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	status := s.statuses[min(s.calls, len(s.statuses)-1)]
+	s.calls++
+	if status < 0 {
+		return nil, errors.New("connection reset")
+	}
+	header := make(http.Header)
+	if s.retryAfter != "" {
+		header.Set("Retry-After", s.retryAfter)
+	}
+	return &http.Response{
+		StatusCode: status,
+		Header:     header,
+		Body:       io.NopCloser(strings.NewReader(http.StatusText(status))),
+		Request:    req,
+	}, nil
+}
+
+func TestTransportRetries(t *testing.T) {
+	tests := []struct {
+		name        string
+		method      string
+		statuses    []int
+		retryAfter  string
+		wantStatus  int
+		wantErr     bool
+		wantCalls   int
+		wantRetryIn time.Duration
+	}{
+		{name: "success", method: http.MethodGet, statuses: []int{200}, wantStatus: 200, wantCalls: 1},
+		{name: "client errors aren't retried", method: http.MethodGet, statuses: []int{404}, wantStatus: 404, wantCalls: 1},
+		{name: "server errors are retried", method: http.MethodGet, statuses: []int{500, 502, 200}, wantStatus: 200, wantCalls: 3},
+		{name: "network errors are retried", method: http.MethodGet, statuses: []int{-1, 200}, wantStatus: 200, wantCalls: 2},
+		{name: "retries give up", method: http.MethodGet, statuses: []int{503}, wantStatus: 503, wantCalls: maxRetries + 1},
+		{name: "rate limits wait out Retry-After", method: http.MethodGet, statuses: []int{429, 200}, retryAfter: "0", wantStatus: 200, wantCalls: 2},
+		{name: "long Retry-After surfaces the rate limit", method: http.MethodGet, statuses: []int{429}, retryAfter: "60", wantErr: true, wantCalls: 1, wantRetryIn: time.Minute},
+		{name: "non idempotent requests aren't retried", method: http.MethodPost, statuses: []int{500, 200}, wantStatus: 500, wantCalls: 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			base := &scriptedTransport{statuses: tt.statuses, retryAfter: tt.retryAfter}
+			req, _ := http.NewRequest(tt.method, "https://api.spotify.test/v1/me", nil)
+
+			resp, err := NewTransport(base).RoundTrip(req)
+			if base.calls != tt.wantCalls {
+				t.Errorf("calls = %d, want %d", base.calls, tt.wantCalls)
+			}
+			if tt.wantErr {
+				var apiErr *APIError
+				if !errors.As(err, &apiErr) {
+					t.Fatalf("error = %v, want an APIError", err)
+				}
+				if apiErr.StatusCode != http.StatusTooManyRequests || apiErr.RetryAfter != tt.wantRetryIn {
+					t.Errorf("APIError = %+v, want a 429 retrying in %v", apiErr, tt.wantRetryIn)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("RoundTrip: %v", err)
+			}
+			defer resp.Body.Close()
+			if resp.StatusCode != tt.wantStatus {
+				t.Errorf("status = %d, want %d", resp.StatusCode, tt.wantStatus)
+			}
+		})
+	}
+}
+
+func TestTransportOpensCircuitPerHost(t *testing.T) {
+	base := &scriptedTransport{statuses: []int{500}}
+	transport := NewTransport(base)
+
+	for i := 0; i < breakerFailureThreshold; i++ {
+		req, _ := http.NewRequest(http.MethodPost, "https://api.spotify.test/v1/me", nil)
+		resp, err := transport.RoundTrip(req)
+		if err != nil {
+			t.Fatalf("RoundTrip %d: %v", i, err)
+		}
+		resp.Body.Close()
+	}
+
+	req, _ := http.NewRequest(http.MethodPost, "https://api.spotify.test/v1/me", nil)
+	_, err := transport.RoundTrip(req)
+	if !errors.Is(err, ErrCircuitOpen) {
+		t.Fatalf("error = %v, want %v", err, ErrCircuitOpen)
+	}
+	var apiErr *APIError
+	if !errors.As(err, &apiErr) || apiErr.StatusCode != http.StatusServiceUnavailable || apiErr.RetryAfter <= 0 {
+		t.Errorf("error = %+v, want a 503 APIError with a retry hint", err)
+	}
+	if base.calls != breakerFailureThreshold {
+		t.Errorf("calls = %d, the open circuit shouldn't reach Spotify", base.calls)
+	}
+
+	// other hosts have their own breaker
+	base.statuses = []int{200}
+	req, _ = http.NewRequest(http.MethodPost, "https://accounts.spotify.test/api/token", nil)
+	resp, err := transport.RoundTrip(req)
+	if err != nil {
+		t.Fatalf("RoundTrip to another host: %v", err)
+	}
+	resp.Body.Close()
+}
+
+func TestTransportRateLimitsDontOpenCircuit(t *testing.T) {
+	base := &scriptedTransport{statuses: []int{429}, retryAfter: "60"}
+	transport := NewTransport(base)
+
+	for i := 0; i <= breakerFailureThreshold; i++ {
+		req, _ := http.NewRequest(http.MethodGet, "https://api.spotify.test/v1/me", nil)
+		_, err := transport.RoundTrip(req)
+		if errors.Is(err, ErrCircuitOpen) {
+			t.Fatalf("RoundTrip %d: the circuit opened on rate limits", i)
+		}
+	}
+	if base.calls != breakerFailureThreshold+1 {
+		t.Errorf("calls = %d, want every request to reach Spotify", base.calls)
+	}
+}
+
+func TestCircuitBreaker(t *testing.T) {
+	tests := []struct {
+		name      string
+		failures  int
+		openUntil time.Time
+		probing   bool
+		wantAllow bool
+	}{
+		{name: "closed", failures: 0, wantAllow: true},
+		{name: "closed below the threshold", failures: breakerFailureThreshold - 1, wantAllow: true},
+		{name: "open", failures: breakerFailureThreshold, openUntil: time.Now().Add(time.Minute), wantAllow: false},
+		{name: "half open lets a probe through", failures: breakerFailureThreshold, openUntil: time.Now().Add(-time.Second), wantAllow: true},
+		{name: "half open with a probe in flight", failures: breakerFailureThreshold, openUntil: time.Now().Add(-time.Second), probing: true, wantAllow: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			breaker := &circuitBreaker{failures: tt.failures, openUntil: tt.openUntil, probing: tt.probing}
+			wait, ok := breaker.allow()
+			if ok != tt.wantAllow {
+				t.Fatalf("allow() = %v, want %v", ok, tt.wantAllow)
+			}
+			if !ok && wait <= 0 {
+				t.Errorf("allow() wait = %v, want a positive wait", wait)
+			}
+		})
+	}
+}
+
+func TestCircuitBreakerProbe(t *testing.T) {
+	tests := []struct {
+		name       string
+		success    bool
+		wantClosed bool
+	}{
+		{name: "successful probe closes", success: true, wantClosed: true},
+		{name: "failed probe reopens", success: false, wantClosed: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			breaker := &circuitBreaker{failures: breakerFailureThreshold, openUntil: time.Now().Add(-time.Second)}
+			if _, ok := breaker.allow(); !ok {
+				t.Fatal("the probe wasn't allowed")
+			}
+			breaker.record(tt.success)
+
+			_, ok := breaker.allow()
+			if ok != tt.wantClosed {
+				t.Errorf("allow() after the probe = %v, want %v", ok, tt.wantClosed)
+			}
+		})
+	}
+
+	breaker := &circuitBreaker{failures: breakerFailureThreshold, openUntil: time.Now().Add(-time.Second)}
+	breaker.allow()
+	breaker.release()
+	if _, ok := breaker.allow(); !ok {
+		t.Error("a released probe should let the next one through")
+	}
+}
+
+func TestParseRetryAfter(t *testing.T) {
+	tests := []struct {
+		header string
+		want   time.Duration
+		approx bool
+	}{
+		{header: "", want: 0},
+		{header: "3", want: 3 * time.Second},
+		{header: "-1", want: 0},
+		{header: "soon", want: 0},
+		{header: time.Now().Add(-time.Hour).UTC().Format(http.TimeFormat), want: 0},
+		{header: time.Now().Add(time.Hour).UTC().Format(http.TimeFormat), want: time.Hour, approx: true},
+	}
+	for _, tt := range tests {
+		resp := &http.Response{Header: http.Header{"Retry-After": []string{tt.header}}}
+		got := parseRetryAfter(resp)
+		if tt.approx {
+			if got < tt.want-2*time.Second || got > tt.want {
+				t.Errorf("parseRetryAfter(%q) = %v, want about %v", tt.header, got, tt.want)
+			}
+		} else if got != tt.want {
+			t.Errorf("parseRetryAfter(%q) = %v, want %v", tt.header, got, tt.want)
+		}
+	}
+}
+
+func TestBackoff(t *testing.T) {
+	for attempt := 0; attempt < 6; attempt++ {
+		ceiling := min(baseBackoff<<attempt, maxBackoff)
+		for i := 0; i < 50; i++ {
+			if wait := backoff(attempt); wait < 0 || wait >= ceiling {
+				t.Fatalf("backoff(%d) = %v, want within [0, %v)", attempt, wait, ceiling)
+			}
+		}
+	}
+}
+
+func TestAPIErrorIsUnauthorized(t *testing.T) {
+	tests := []struct {
+		status int
+		want   bool
+	}{
+		{status: http.StatusUnauthorized, want: true},
+		{status: http.StatusForbidden, want: false},
+		{status: http.StatusTooManyRequests, want: false},
+	}
+	for _, tt := range tests {
+		err := fmt.Errorf("wrapped: %w", &APIError{StatusCode: tt.status})
+		if got := errors.Is(err, ErrUnauthorized); got != tt.want {
+			t.Errorf("errors.Is(%d, ErrUnauthorized) = %v, want %v", tt.status, got, tt.want)
+		}
+	}
+}
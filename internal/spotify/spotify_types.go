@@ -3,10 +3,9 @@ package spotify
 // --- Structs for Spotify API AUTH Response ---
 
 type SpotifyAuthCallbackResponse struct {
-	Code   string `json:"code"`    // An authorization code that can be exchanged for an access token.
-	State  string `json:"state"`   // The value of the state parameter supplied in the request.
-	Err    string `json:"error"`   // The reason authorization failed, for example: "access_denied"
-	UserID uint64 `json:"user_id"` // The ranktify user_id, to relate rt w/ user
+	Code  string `json:"code"`  // An authorization code that can be exchanged for an access token.
+	State string `json:"state"` // The value of the state parameter supplied in the request.
+	Err   string `json:"error"` // The reason authorization failed, for example: "access_denied"
 }
 
 type SpotifyAccessTokenResponse struct {
@@ -17,12 +16,18 @@ type SpotifyAccessTokenResponse struct {
 	Scope        string `json:"scope"`
 }
 
-type SpotifyRefreshTokenRequest struct { // TODO" We can eliminate this struct since we are passing the userID in the JWT token
-	UserID uint64 `json:"user_id"`
-}
-
 // --- Structs for Spotify API Response ---
 
+// SpotifyProfile is the current user's profile, from the /me endpoint
+type SpotifyProfile struct {
+	ID                string  `json:"id"`
+	DisplayName       *string `json:"display_name,omitempty"`
+	Email             string  `json:"email,omitempty"`
+	Country           string  `json:"country,omitempty"` // ISO 3166-1 alpha-2, needs the user-read-private scope
+	ProfileURI        *string `json:"profile_uri,omitempty"`
+	ProfilePictureURI *string `json:"profile_picture_uri,omitempty"`
+}
+
 type SpotifySearchResponse struct {
 	Tracks SpotifyTracksObject `json:"tracks"`
 }
@@ -41,10 +46,22 @@ type SpotifyTrack struct {
 }
 
 type SpotifyArtist struct {
+	ID   string `json:"id"`
 	Name string `json:"name"`
 }
 
+type SpotifyFullArtist struct {
+	ID     string   `json:"id"`
+	Name   string   `json:"name"`
+	Genres []string `json:"genres"`
+}
+
+type SpotifyArtistsResponse struct {
+	Artists []*SpotifyFullArtist `json:"artists"`
+}
+
 type SpotifyAlbum struct {
+	ID          string         `json:"id"`
 	Name        string         `json:"name"`
 	ReleaseDate string         `json:"release_date"`
 	Images      []SpotifyImage `json:"images"`
@@ -0,0 +1,542 @@
+// Package spotifytest serves a fake Spotify Web API and accounts service from canned
+// fixtures, so code that talks to Spotify can run offline.
+package spotifytest
+
+import (
+	"bytes"
+	_ "embed"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ranktify/ranktify-be/internal/spotify"
+)
+
+// Credentials handed out by the fake accounts service
+const (
+	AccessToken  = "fake-access-token"
+	RefreshToken = "fake-refresh-token"
+)
+
+// ExpiredAccessToken is rejected by the fake Web API with a 401, like an expired token
+const ExpiredAccessToken = "expired-access-token"
+
+// InvalidCode is an authorization code the fake accounts service rejects
+const InvalidCode = "invalid-code"
+
+// MissingPlaylistID is a playlist the fake Web API answers with a 404
+const MissingPlaylistID = "missing-playlist"
+
+// BaseURLPlaceholder is replaced in the fixtures by the url the server is reached at
+const BaseURLPlaceholder = "{{BASE_URL}}"
+
+var (
+	//go:embed fixtures/tracks.json
+	tracksFixture []byte
+	//go:embed fixtures/artists.json
+	artistsFixture []byte
+	//go:embed fixtures/track_page.html
+	trackPageFixture []byte
+	//go:embed fixtures/profile.json
+	profileFixture []byte
+)
+
+type fixtureTrack struct {
+	ID      string `json:"id"`
+	Name    string `json:"name"`
+	Artists []struct {
+		ID string `json:"id"`
+	} `json:"artists"`
+	raw json.RawMessage
+}
+
+type fixtureArtist struct {
+	ID     string   `json:"id"`
+	Name   string   `json:"name"`
+	Genres []string `json:"genres"`
+}
+
+// Server is a running fake Spotify, the Web API lives under /v1 and the accounts
+// service at the root, the same layout as the real hosts
+type Server struct {
+	*httptest.Server
+
+	mu       sync.Mutex
+	requests []string
+	failures []failure
+}
+
+type failure struct {
+	status     int
+	retryAfter time.Duration
+}
+
+// NewServer starts a fake Spotify, callers should Close it when done
+func NewServer() *Server {
+	s := &Server{}
+	s.Server = httptest.NewServer(s.record(NewHandler()))
+	return s
+}
+
+// Provider returns a spotify.Client pointed at the fake server
+func (s *Server) Provider() *spotify.Client {
+	return &spotify.Client{
+		APIBaseURL:      s.URL + "/v1",
+		AccountsBaseURL: s.URL,
+		ClientID:        "fake-client-id",
+		ClientSecret:    "fake-client-secret",
+		HTTPClient: &http.Client{
+			Timeout:   10 * time.Second,
+			Transport: spotify.NewTransport(http.DefaultTransport),
+		},
+	}
+}
+
+// Requests returns the "METHOD /path" of every request served so far
+func (s *Server) Requests() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]string(nil), s.requests...)
+}
+
+// Count returns how many times the "METHOD /path" request was served
+func (s *Server) Count(request string) int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	count := 0
+	for _, served := range s.requests {
+		if served == request {
+			count++
+		}
+	}
+	return count
+}
+
+// FailNext makes the next n requests fail with status, a non zero retryAfter is sent
+// in the Retry-After header. Handy to exercise rate limits and outages.
+func (s *Server) FailNext(n int, status int, retryAfter time.Duration) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for range n {
+		s.failures = append(s.failures, failure{status: status, retryAfter: retryAfter})
+	}
+}
+
+func (s *Server) record(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		s.mu.Lock()
+		s.requests = append(s.requests, r.Method+" "+r.URL.Path)
+		var fail *failure
+		if len(s.failures) > 0 {
+			fail = &s.failures[0]
+			s.failures = s.failures[1:]
+		}
+		s.mu.Unlock()
+
+		if fail != nil {
+			if fail.retryAfter > 0 {
+				w.Header().Set("Retry-After", strconv.Itoa(int(fail.retryAfter.Seconds())))
+			}
+			writeError(w, fail.status, http.StatusText(fail.status))
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// NewHandler returns the fake Spotify handler, cmd/fakespotify serves it on a fixed
+// port for local development
+func NewHandler() http.Handler {
+	tracks := mustLoadTracks()
+	artists := mustLoadArtists()
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("POST /api/token", handleToken)
+	mux.Handle("GET /v1/search", requireBearer(handleSearch(tracks, artists)))
+	mux.Handle("GET /v1/me", requireBearer(handleProfile))
+	mux.Handle("GET /v1/me/top/tracks", requireBearer(handleTopTracks(tracks)))
+	mux.Handle("GET /v1/artists", requireBearer(handleArtists(artists)))
+	mux.Handle("GET /v1/playlists/{id}/tracks", requireBearer(handlePlaylistItems(tracks)))
+	mux.Handle("PUT /v1/playlists/{id}/tracks", requireBearer(handleChangePlaylistTracks(http.StatusOK)))
+	mux.Handle("POST /v1/playlists/{id}/tracks", requireBearer(handleChangePlaylistTracks(http.StatusCreated)))
+	mux.Handle("POST /v1/users/{user_id}/playlists", requireBearer(handleCreatePlaylist()))
+	mux.Handle("GET /v1/me/tracks", requireBearer(handleSavedTracks(tracks)))
+	mux.Handle("GET /v1/me/player/recently-played", requireBearer(handleRecentlyPlayed(tracks)))
+	mux.HandleFunc("GET /track/{id}", handleTrackPage)
+	return mux
+}
+
+func handleToken(w http.ResponseWriter, r *http.Request) {
+	if _, _, ok := r.BasicAuth(); !ok {
+		writeError(w, http.StatusBadRequest, "invalid_client")
+		return
+	}
+	if err := r.ParseForm(); err != nil {
+		writeError(w, http.StatusBadRequest, "invalid_request")
+		return
+	}
+
+	tokenResponse := spotify.SpotifyAccessTokenResponse{
+		AccessToken: AccessToken,
+		TokenType:   "Bearer",
+		ExpiresIn:   3600,
+	}
+	switch r.PostForm.Get("grant_type") {
+	case "authorization_code":
+		if code := r.PostForm.Get("code"); code == "" || code == InvalidCode {
+			writeError(w, http.StatusBadRequest, "invalid_grant")
+			return
+		}
+		tokenResponse.RefreshToken = RefreshToken
+		tokenResponse.Scope = "user-top-read user-read-email user-read-private"
+	case "refresh_token":
+		if r.PostForm.Get("refresh_token") == "" {
+			writeError(w, http.StatusBadRequest, "invalid_grant")
+			return
+		}
+	case "client_credentials":
+	default:
+		writeError(w, http.StatusBadRequest, "unsupported_grant_type")
+		return
+	}
+	writeJSON(w, r, tokenResponse)
+}
+
+// Serves every fixture track, narrowed by the track:... and genre:"..." filters in the
+// query (genres are matched against the artists' genres) and the limit
+func handleSearch(tracks []fixtureTrack, artists map[string]fixtureArtist) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		query := r.URL.Query()
+		if query.Get("type") != "track" {
+			writeError(w, http.StatusBadRequest, "only track searches are supported")
+			return
+		}
+
+		title := titleFilter(query.Get("q"))
+		genre := genreFilter(query.Get("q"))
+		items := make([]json.RawMessage, 0, len(tracks))
+		for _, track := range tracks {
+			if title != "" && !strings.EqualFold(track.Name, title) {
+				continue
+			}
+			if genre != "" && !trackHasGenre(track, artists, genre) {
+				continue
+			}
+			items = append(items, track.raw)
+		}
+		if limit, err := strconv.Atoi(query.Get("limit")); err == nil && limit >= 0 && limit < len(items) {
+			items = items[:limit]
+		}
+
+		writeJSON(w, r, map[string]any{
+			"tracks": map[string]any{
+				"href":  r.URL.String(),
+				"items": items,
+				"total": len(items),
+			},
+		})
+	}
+}
+
+func handleTopTracks(tracks []fixtureTrack) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		items := make([]json.RawMessage, 0, len(tracks))
+		for _, track := range tracks {
+			items = append(items, track.raw)
+		}
+		if limit, err := strconv.Atoi(r.URL.Query().Get("limit")); err == nil && limit >= 0 && limit < len(items) {
+			items = items[:limit]
+		}
+
+		writeJSON(w, r, map[string]any{
+			"items": items,
+			"total": len(items),
+		})
+	}
+}
+
+// Every playlist holds the fixture tracks, MissingPlaylistID doesn't exist
+func handlePlaylistItems(tracks []fixtureTrack) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.PathValue("id") == MissingPlaylistID {
+			writeError(w, http.StatusNotFound, "Resource not found")
+			return
+		}
+		items := make([]any, 0, len(tracks))
+		for _, track := range tracks {
+			items = append(items, map[string]any{
+				"added_at": "2024-01-01T00:00:00Z",
+				"is_local": false,
+				"track":    track.raw,
+			})
+		}
+		writePage(w, r, items, 100)
+	}
+}
+
+// Creates playlists with sequential ids, nothing is kept
+func handleCreatePlaylist() http.HandlerFunc {
+	var mu sync.Mutex
+	created := 0
+	return func(w http.ResponseWriter, r *http.Request) {
+		var body struct {
+			Name        string `json:"name"`
+			Description string `json:"description"`
+			Public      bool   `json:"public"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil || body.Name == "" {
+			writeError(w, http.StatusBadRequest, "Missing playlist name")
+			return
+		}
+
+		mu.Lock()
+		created++
+		id := fmt.Sprintf("fake-playlist-%d", created)
+		mu.Unlock()
+
+		writeJSONStatus(w, r, http.StatusCreated, map[string]any{
+			"id":          id,
+			"name":        body.Name,
+			"description": body.Description,
+			"public":      body.Public,
+			"owner":       map[string]any{"id": r.PathValue("user_id")},
+			"uri":         "spotify:playlist:" + id,
+		})
+	}
+}
+
+// Accepts replacing or appending up to 100 tracks, in the uris query or body,
+// MissingPlaylistID doesn't exist
+func handleChangePlaylistTracks(status int) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.PathValue("id") == MissingPlaylistID {
+			writeError(w, http.StatusNotFound, "Resource not found")
+			return
+		}
+
+		var uris []string
+		if query := r.URL.Query().Get("uris"); query != "" {
+			uris = strings.Split(query, ",")
+		} else {
+			var body struct {
+				URIs []string `json:"uris"`
+			}
+			if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+				writeError(w, http.StatusBadRequest, "Invalid body")
+				return
+			}
+			uris = body.URIs
+		}
+		if len(uris) > 100 {
+			writeError(w, http.StatusBadRequest, "Too many ids requested")
+			return
+		}
+		for _, uri := range uris {
+			if !strings.HasPrefix(uri, "spotify:track:") {
+				writeError(w, http.StatusBadRequest, "Invalid track uri: "+uri)
+				return
+			}
+		}
+
+		writeJSONStatus(w, r, status, map[string]any{"snapshot_id": fmt.Sprintf("snapshot-%d", len(uris))})
+	}
+}
+
+// The liked songs are the fixture tracks
+func handleSavedTracks(tracks []fixtureTrack) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		items := make([]any, 0, len(tracks))
+		for _, track := range tracks {
+			items = append(items, map[string]any{
+				"added_at": "2024-01-01T00:00:00Z",
+				"track":    track.raw,
+			})
+		}
+		writePage(w, r, items, 50)
+	}
+}
+
+// Every fixture track was played twice, the latest plays first
+func handleRecentlyPlayed(tracks []fixtureTrack) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		items := make([]any, 0, 2*len(tracks))
+		playedAt := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+		for range 2 {
+			for _, track := range tracks {
+				items = append(items, map[string]any{
+					"track":     track.raw,
+					"played_at": playedAt.Format(time.RFC3339),
+				})
+				playedAt = playedAt.Add(-3 * time.Minute)
+			}
+		}
+		if limit, err := strconv.Atoi(r.URL.Query().Get("limit")); err == nil && limit >= 0 && limit < len(items) {
+			items = items[:limit]
+		}
+		writeJSON(w, r, map[string]any{"items": items})
+	}
+}
+
+var handleProfile = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, r, json.RawMessage(profileFixture))
+})
+
+// Unknown ids come back as null entries like the real endpoint does
+func handleArtists(artists map[string]fixtureArtist) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ids := strings.Split(r.URL.Query().Get("ids"), ",")
+		result := make([]*fixtureArtist, len(ids))
+		for i, id := range ids {
+			if artist, ok := artists[id]; ok {
+				result[i] = &artist
+			}
+		}
+		writeJSON(w, r, map[string]any{"artists": result})
+	}
+}
+
+func handleTrackPage(w http.ResponseWriter, r *http.Request) {
+	page := bytes.ReplaceAll(trackPageFixture, []byte("{{TRACK_ID}}"), []byte(r.PathValue("id")))
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Write(page)
+}
+
+func requireBearer(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		accessToken, found := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+		if !found || accessToken == "" {
+			writeError(w, http.StatusUnauthorized, "No token provided")
+			return
+		}
+		if accessToken == ExpiredAccessToken {
+			writeError(w, http.StatusUnauthorized, "The access token expired")
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+func titleFilter(query string) string {
+	_, title, found := strings.Cut(query, "track:")
+	if !found {
+		return ""
+	}
+	title, _, _ = strings.Cut(title, " artist:")
+	return strings.TrimSpace(title)
+}
+
+func genreFilter(query string) string {
+	_, genre, found := strings.Cut(query, "genre:")
+	if !found {
+		return ""
+	}
+	if unquoted, err := strconv.Unquote(genre); err == nil {
+		return unquoted
+	}
+	return strings.Trim(genre, `"`)
+}
+
+func trackHasGenre(track fixtureTrack, artists map[string]fixtureArtist, genre string) bool {
+	for _, trackArtist := range track.Artists {
+		for _, artistGenre := range artists[trackArtist.ID].Genres {
+			if strings.EqualFold(artistGenre, genre) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// Writes the offset/limit page of items, next points at the following page like the
+// real paging objects do
+func writePage(w http.ResponseWriter, r *http.Request, items []any, defaultLimit int) {
+	query := r.URL.Query()
+	limit, err := strconv.Atoi(query.Get("limit"))
+	if err != nil || limit <= 0 {
+		limit = defaultLimit
+	}
+	offset, err := strconv.Atoi(query.Get("offset"))
+	if err != nil || offset < 0 {
+		offset = 0
+	}
+
+	start := min(offset, len(items))
+	end := min(offset+limit, len(items))
+	var next *string
+	if end < len(items) {
+		nextURL := fmt.Sprintf("http://%s%s?offset=%d&limit=%d", r.Host, r.URL.Path, end, limit)
+		next = &nextURL
+	}
+
+	writeJSON(w, r, map[string]any{
+		"href":   "http://" + r.Host + r.URL.RequestURI(),
+		"items":  items[start:end],
+		"limit":  limit,
+		"offset": offset,
+		"total":  len(items),
+		"next":   next,
+	})
+}
+
+// Writes the body with the base url placeholder pointing back at this server
+func writeJSON(w http.ResponseWriter, r *http.Request, body any) {
+	writeJSONStatus(w, r, http.StatusOK, body)
+}
+
+func writeJSONStatus(w http.ResponseWriter, r *http.Request, status int, body any) {
+	encoded, err := json.Marshal(body)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	encoded = bytes.ReplaceAll(encoded, []byte(BaseURLPlaceholder), []byte("http://"+r.Host))
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	w.Write(encoded)
+}
+
+func writeError(w http.ResponseWriter, status int, message string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(map[string]any{
+		"error": map[string]any{
+			"status":  status,
+			"message": message,
+		},
+	})
+}
+
+func mustLoadTracks() []fixtureTrack {
+	var raw []json.RawMessage
+	if err := json.Unmarshal(tracksFixture, &raw); err != nil {
+		panic("spotifytest: bad tracks fixture: " + err.Error())
+	}
+
+	tracks := make([]fixtureTrack, len(raw))
+	for i, message := range raw {
+		if err := json.Unmarshal(message, &tracks[i]); err != nil {
+			panic("spotifytest: bad tracks fixture: " + err.Error())
+		}
+		tracks[i].raw = message
+	}
+	return tracks
+}
+
+func mustLoadArtists() map[string]fixtureArtist {
+	var list []fixtureArtist
+	if err := json.Unmarshal(artistsFixture, &list); err != nil {
+		panic("spotifytest: bad artists fixture: " + err.Error())
+	}
+
+	artists := make(map[string]fixtureArtist, len(list))
+	for _, artist := range list {
+		artists[artist.ID] = artist
+	}
+	return artists
+}
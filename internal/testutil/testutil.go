@@ -0,0 +1,56 @@
+// Package testutil holds the fixtures tests across packages share: a mocked database,
+// a test keyring and a fake Spotify, each cleaned up with the test.
+package testutil
+
+import (
+	"bytes"
+	"database/sql"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/ranktify/ranktify-be/internal/secrets"
+	"github.com/ranktify/ranktify-be/internal/spotify/spotifytest"
+)
+
+// RefreshTokenQuery matches SpotifyDAO.GetRefreshToken
+const RefreshTokenQuery = `SELECT refresh_token, key_id\s+FROM spotify_refresh_tokens`
+
+// MockDB returns a database whose queries are answered by the returned mock
+func MockDB(t *testing.T) (*sql.DB, sqlmock.Sqlmock) {
+	t.Helper()
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("sqlmock: %v", err)
+	}
+	t.Cleanup(func() { db.Close() })
+	return db, mock
+}
+
+// Keyring returns a keyring with a single fixed key
+func Keyring(t *testing.T) *secrets.Keyring {
+	t.Helper()
+	keyring, err := secrets.NewKeyring(map[string][]byte{"test": bytes.Repeat([]byte{1}, 32)}, "test")
+	if err != nil {
+		t.Fatalf("NewKeyring: %v", err)
+	}
+	return keyring
+}
+
+// SpotifyServer starts a fake Spotify
+func SpotifyServer(t *testing.T) *spotifytest.Server {
+	t.Helper()
+	server := spotifytest.NewServer()
+	t.Cleanup(server.Close)
+	return server
+}
+
+// ExpectRefreshToken answers the user's refresh token lookup with the fake Spotify's
+// refresh token, stored before encryption so it needs no key, or with no rows when
+// the user isn't linked
+func ExpectRefreshToken(mock sqlmock.Sqlmock, userID uint64, linked bool) {
+	rows := sqlmock.NewRows([]string{"refresh_token", "key_id"})
+	if linked {
+		rows.AddRow(spotifytest.RefreshToken, nil)
+	}
+	mock.ExpectQuery(RefreshTokenQuery).WithArgs(userID).WillReturnRows(rows)
+}
@@ -14,6 +14,26 @@ CREATE TABLE users (
     spotify_display_name VARCHAR(255),
     spotify_profile_uri VARCHAR(255),
     spotify_profile_picture_uri VARCHAR(255),
+    market VARCHAR(2), -- ISO 3166-1 alpha-2 Spotify market, NULL searches the US market
+    locale VARCHAR(35), -- BCP 47 language tag for Spotify's localized names, NULL for Spotify's default
+    created_at TIMESTAMP DEFAULT NOW()
+);
+
+-- Artists Table
+CREATE TABLE artists (
+    artist_id SERIAL PRIMARY KEY,
+    spotify_id VARCHAR(255) UNIQUE,
+    name VARCHAR(255) NOT NULL,
+    created_at TIMESTAMP DEFAULT NOW()
+);
+
+-- Albums Table
+CREATE TABLE albums (
+    album_id SERIAL PRIMARY KEY,
+    spotify_id VARCHAR(255) UNIQUE,
+    name VARCHAR(255) NOT NULL,
+    release_date DATE,
+    cover_uri VARCHAR(255),
     created_at TIMESTAMP DEFAULT NOW()
 );
 
@@ -22,15 +42,80 @@ CREATE TABLE songs (
     song_id SERIAL PRIMARY KEY,
     spotify_id VARCHAR(255) UNIQUE,
     title VARCHAR(255) NOT NULL,
-    artist VARCHAR(255),
+    artist VARCHAR(255), -- main artist name, every artist is in song_artists
     album VARCHAR(255),
+    album_id INTEGER REFERENCES albums(album_id) ON DELETE SET NULL,
     release_date DATE,
     genre VARCHAR(100),
     cover_uri VARCHAR(255),
     preview_uri VARCHAR(255),
+    genres_enriched_at TIMESTAMP, -- last time the enrichment job resolved the genres of the song's artists
     created_at TIMESTAMP DEFAULT NOW()
 );
 
+-- Genres Table (Catalog Of Genres Songs To Rank Are Searched By)
+CREATE TABLE genres (
+    genre_id SERIAL PRIMARY KEY,
+    search_key VARCHAR(100) UNIQUE NOT NULL, -- sent to Spotify in the genre:"..." search filter
+    name_en VARCHAR(100) NOT NULL,
+    name_es VARCHAR(100) NOT NULL,
+    markets VARCHAR(2)[] NOT NULL DEFAULT '{}', -- ISO 3166-1 alpha-2 markets the genre is offered in, empty for every market
+    enabled BOOLEAN NOT NULL DEFAULT TRUE,
+    created_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    updated_at TIMESTAMP NOT NULL DEFAULT NOW()
+);
+
+INSERT INTO genres (search_key, name_en, name_es, markets) VALUES
+    ('pop', 'Pop', 'Pop', '{}'),
+    ('hip hop', 'Hip Hop', 'Hip Hop', '{}'),
+    ('rock', 'Rock', 'Rock', '{}'),
+    ('country', 'Country', 'Country', '{}'),
+    ('edm', 'EDM', 'EDM', '{}'),
+    ('latin', 'Latin', 'Latina', '{}'),
+    ('r b', 'R&B', 'R&B', '{}'),
+    ('reggae', 'Reggae', 'Reggae', '{}'),
+    ('jazz', 'Jazz', 'Jazz', '{}'),
+    ('classical', 'Classical', 'Clásica', '{}'),
+    ('rap', 'Rap', 'Rap', '{}'),
+    ('trap', 'Trap', 'Trap', '{}'),
+    ('metal', 'Metal', 'Metal', '{}'),
+    ('indie rock', 'Indie Rock', 'Rock Indie', '{}'),
+    ('alternative pop', 'Alternative Pop', 'Pop Alternativo', '{}'),
+    ('alternative rock', 'Alternative Rock', 'Rock Alternativo', '{}'),
+    ('reggaeton', 'Reggaeton', 'Reguetón', '{AR,BO,CL,CO,CR,DO,EC,ES,GT,HN,MX,NI,PA,PE,PR,PY,SV,UY,VE}'),
+    ('salsa', 'Salsa', 'Salsa', '{AR,BO,CL,CO,CR,DO,EC,ES,GT,HN,MX,NI,PA,PE,PR,PY,SV,UY,VE}'),
+    ('bachata', 'Bachata', 'Bachata', '{AR,BO,CL,CO,CR,DO,EC,ES,GT,HN,MX,NI,PA,PE,PR,PY,SV,UY,VE}'),
+    ('merengue', 'Merengue', 'Merengue', '{AR,BO,CL,CO,CR,DO,EC,ES,GT,HN,MX,NI,PA,PE,PR,PY,SV,UY,VE}'),
+    ('cumbia', 'Cumbia', 'Cumbia', '{AR,BO,CL,CO,CR,DO,EC,ES,GT,HN,MX,NI,PA,PE,PR,PY,SV,UY,VE}'),
+    ('ranchera', 'Ranchera', 'Ranchera', '{AR,BO,CL,CO,CR,DO,EC,ES,GT,HN,MX,NI,PA,PE,PR,PY,SV,UY,VE}'),
+    ('mariachi', 'Mariachi', 'Mariachi', '{AR,BO,CL,CO,CR,DO,EC,ES,GT,HN,MX,NI,PA,PE,PR,PY,SV,UY,VE}'),
+    ('vallenato', 'Vallenato', 'Vallenato', '{AR,BO,CL,CO,CR,DO,EC,ES,GT,HN,MX,NI,PA,PE,PR,PY,SV,UY,VE}'),
+    ('tango', 'Tango', 'Tango', '{AR,BO,CL,CO,CR,DO,EC,ES,GT,HN,MX,NI,PA,PE,PR,PY,SV,UY,VE}'),
+    ('bolero', 'Bolero', 'Bolero', '{AR,BO,CL,CO,CR,DO,EC,ES,GT,HN,MX,NI,PA,PE,PR,PY,SV,UY,VE}'),
+    ('latin pop', 'Latin Pop', 'Pop Latino', '{AR,BO,CL,CO,CR,DO,EC,ES,GT,HN,MX,NI,PA,PE,PR,PY,SV,UY,VE}'),
+    ('latin rock', 'Latin Rock', 'Rock Latino', '{AR,BO,CL,CO,CR,DO,EC,ES,GT,HN,MX,NI,PA,PE,PR,PY,SV,UY,VE}'),
+    ('latin trap', 'Latin Trap', 'Trap Latino', '{AR,BO,CL,CO,CR,DO,EC,ES,GT,HN,MX,NI,PA,PE,PR,PY,SV,UY,VE}');
+
+-- Song Genres Table (A Song Can Have Many Genres)
+CREATE TABLE song_genres (
+    song_id INTEGER NOT NULL REFERENCES songs(song_id) ON DELETE CASCADE,
+    genre VARCHAR(100) NOT NULL,
+    source VARCHAR(50) NOT NULL CHECK (source IN ('search', 'artist')), -- genre search that found the song or the artist's Spotify genres
+    PRIMARY KEY (song_id, genre)
+);
+
+CREATE INDEX idx_song_genres_genre ON song_genres(genre);
+
+-- Song Artists Table (Main and Featured Artists of Each Song)
+CREATE TABLE song_artists (
+    song_id INTEGER NOT NULL REFERENCES songs(song_id) ON DELETE CASCADE,
+    artist_id INTEGER NOT NULL REFERENCES artists(artist_id) ON DELETE CASCADE,
+    position INTEGER NOT NULL DEFAULT 0, -- 0 is the main artist, featured artists follow in Spotify's order
+    PRIMARY KEY (song_id, artist_id)
+);
+
+CREATE INDEX idx_song_artists_artist ON song_artists(artist_id);
+
 -- Friend Requests Table
 CREATE TABLE friend_requests (
     request_id SERIAL PRIMARY KEY,
@@ -63,23 +148,117 @@ CREATE TABLE rankings (
     updated_at TIMESTAMP DEFAULT NOW()
 );
 
--- Index for Faster Ranking Queries
-CREATE INDEX idx_rankings_user_song ON rankings(user_id, song_id);
+-- A user ranks a song once, ranking it again updates the existing row
+CREATE UNIQUE INDEX idx_rankings_user_song ON rankings(user_id, song_id);
+
+-- Pairwise Ratings Table (Per-User Elo Rating of Each Ranked Song, fed by "this or that" comparisons)
+CREATE TABLE pairwise_ratings (
+    user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    song_id INTEGER NOT NULL REFERENCES songs(song_id) ON DELETE CASCADE,
+    rating DOUBLE PRECISION NOT NULL,
+    comparisons INTEGER NOT NULL DEFAULT 0,
+    updated_at TIMESTAMP DEFAULT NOW(),
+    PRIMARY KEY (user_id, song_id)
+);
+
+-- Ranking History Table (Append-Only Audit Trail of Every Score Change)
+CREATE TABLE ranking_history (
+    history_id SERIAL PRIMARY KEY,
+    user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    song_id INTEGER NOT NULL REFERENCES songs(song_id) ON DELETE CASCADE,
+    old_rank INTEGER, -- NULL when the song was ranked for the first time
+    new_rank INTEGER, -- NULL when the ranking was deleted
+    source VARCHAR(255) NOT NULL, -- endpoint that made the change
+    changed_at TIMESTAMP NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX idx_ranking_history_user_song ON ranking_history(user_id, song_id, changed_at);
+
+-- Streak Credits Table (Last Puerto Rico Day Each Song Counted Towards The User's Streak, At Most Once A Day)
+CREATE TABLE streak_credits (
+    user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    song_id INTEGER NOT NULL REFERENCES songs(song_id) ON DELETE CASCADE,
+    credited_on DATE NOT NULL,
+    PRIMARY KEY (user_id, song_id)
+);
+
+-- Song Similarities Table (Item-Based Collaborative Filtering From Co-Ranking Users, Rebuilt By scripts/song_similarities)
+CREATE TABLE song_similarities (
+    song_id INTEGER NOT NULL REFERENCES songs(song_id) ON DELETE CASCADE,
+    similar_song_id INTEGER NOT NULL REFERENCES songs(song_id) ON DELETE CASCADE,
+    similarity DOUBLE PRECISION NOT NULL, -- adjusted cosine of the users' ranks, shrunk when few users ranked both
+    co_rankers INTEGER NOT NULL, -- users that ranked both songs
+    computed_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    PRIMARY KEY (song_id, similar_song_id)
+);
+
+-- Recommendation Feedback Table (Recommended Songs A User Skipped, Wasn't Interested In Or Already Knew, Never Recommended Again)
+CREATE TABLE recommendation_feedback (
+    user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    song_id INTEGER NOT NULL REFERENCES songs(song_id) ON DELETE CASCADE,
+    feedback VARCHAR(20) NOT NULL CHECK (feedback IN ('skip', 'not_interested', 'already_know')),
+    excluded_artist_id INTEGER REFERENCES artists(artist_id) ON DELETE SET NULL, -- the song's main artist when excluded, none of their songs are recommended either
+    created_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    updated_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    PRIMARY KEY (user_id, song_id)
+);
+
+-- Ranking Queue Table (Songs Imported From Spotify, Waiting To Be Ranked In Order)
+CREATE TABLE ranking_queue (
+    user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    song_id INTEGER NOT NULL REFERENCES songs(song_id) ON DELETE CASCADE,
+    position INTEGER NOT NULL, -- songs are drawn lowest first, imports append at the end
+    source VARCHAR(255) NOT NULL, -- playlist:<spotify id>, liked or recent
+    added_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    PRIMARY KEY (user_id, song_id)
+);
+
+CREATE INDEX idx_ranking_queue_user_position ON ranking_queue(user_id, position);
+
+-- Spotify Playlist Exports Table (Playlist Each Ranking Export Syncs To, One Per Minimum Rank)
+CREATE TABLE spotify_playlist_exports (
+    user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    min_rank INTEGER NOT NULL CHECK (min_rank >= 1 AND min_rank <= 5),
+    playlist_id VARCHAR(255) NOT NULL,
+    track_count INTEGER NOT NULL DEFAULT 0,
+    exported_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    PRIMARY KEY (user_id, min_rank)
+);
+
+-- Resolved preview urls by spotify track id, NULL preview_uri caches that there is none
+CREATE TABLE preview_cache (
+    spotify_id VARCHAR(255) PRIMARY KEY,
+    preview_uri TEXT,
+    resolved_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    expires_at TIMESTAMP NOT NULL
+);
+
+CREATE INDEX idx_preview_cache_expires_at ON preview_cache(expires_at);
+
+-- Preview lookups the refresh job failed, retried with an exponential backoff so failing tracks don't hold the job back
+CREATE TABLE preview_failures (
+    spotify_id VARCHAR(255) PRIMARY KEY,
+    failures INTEGER NOT NULL DEFAULT 1,
+    failed_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    retry_at TIMESTAMP NOT NULL
+);
 
 -- Tracks JWT refresh tokens and rotations
 CREATE TABLE jwt_refresh_tokens (
     id SERIAL PRIMARY KEY,
     user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
     jti TEXT NOT NULL, -- new column for the token identifier
-    refresh_token TEXT NOT NULL, 
+    refresh_token TEXT NOT NULL, -- encrypted, see key_id
+    key_id TEXT, -- id of the key that encrypts refresh_token, NULL for plaintext rows stored before encryption
     expires_at TIMESTAMP NOT NULL,
     created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
 );
 
 CREATE TABLE spotify_refresh_tokens (
     id SERIAL PRIMARY KEY,
-    user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
-    refresh_token TEXT NOT NULL, 
+    user_id INTEGER NOT NULL UNIQUE REFERENCES users(id) ON DELETE CASCADE,
+    refresh_token TEXT NOT NULL, -- encrypted, see key_id
+    key_id TEXT, -- id of the key that encrypts refresh_token, NULL for plaintext rows stored before encryption
     created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
 );
 
@@ -104,10 +283,24 @@ CREATE TABLE impression_stats (
 
 --give ownership to ranktifyUser
 ALTER TABLE users OWNER TO ranktifyUser;
+ALTER TABLE artists OWNER TO ranktifyUser;
+ALTER TABLE albums OWNER TO ranktifyUser;
 ALTER TABLE songs OWNER TO ranktifyUser;
+ALTER TABLE song_artists OWNER TO ranktifyUser;
+ALTER TABLE genres OWNER TO ranktifyUser;
+ALTER TABLE song_genres OWNER TO ranktifyUser;
 ALTER TABLE friend_requests OWNER TO ranktifyUser;
 ALTER TABLE friends OWNER TO ranktifyUser;
 ALTER TABLE rankings OWNER TO ranktifyUser;
+ALTER TABLE pairwise_ratings OWNER TO ranktifyUser;
+ALTER TABLE ranking_history OWNER TO ranktifyUser;
+ALTER TABLE streak_credits OWNER TO ranktifyUser;
+ALTER TABLE song_similarities OWNER TO ranktifyUser;
+ALTER TABLE recommendation_feedback OWNER TO ranktifyUser;
+ALTER TABLE ranking_queue OWNER TO ranktifyUser;
+ALTER TABLE spotify_playlist_exports OWNER TO ranktifyUser;
+ALTER TABLE preview_cache OWNER TO ranktifyUser;
+ALTER TABLE preview_failures OWNER TO ranktifyUser;
 ALTER TABLE jwt_refresh_tokens OWNER TO ranktifyUser;
 ALTER TABLE spotify_refresh_tokens OWNER TO ranktifyUser;
 ALTER TABLE impression_stats OWNER TO ranktifyUser;
\ No newline at end of file
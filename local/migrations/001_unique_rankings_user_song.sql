@@ -0,0 +1,25 @@
+-- Upgrades databases created before a user could rank a song only once. init.sql
+-- already has all of this, run it once against databases created from an older one.
+BEGIN;
+
+-- keeps the latest ranking of each song the user ranked more than once
+DELETE FROM rankings r
+USING rankings newer
+WHERE newer.user_id = r.user_id
+  AND newer.song_id = r.song_id
+  AND (COALESCE(newer.updated_at, '-infinity'), newer.ranking_id)
+    > (COALESCE(r.updated_at, '-infinity'), r.ranking_id);
+
+DROP INDEX IF EXISTS idx_rankings_user_song;
+CREATE UNIQUE INDEX idx_rankings_user_song ON rankings(user_id, song_id);
+
+-- Streak Credits Table (Last Puerto Rico Day Each Song Counted Towards The User's Streak, At Most Once A Day)
+CREATE TABLE IF NOT EXISTS streak_credits (
+    user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    song_id INTEGER NOT NULL REFERENCES songs(song_id) ON DELETE CASCADE,
+    credited_on DATE NOT NULL,
+    PRIMARY KEY (user_id, song_id)
+);
+ALTER TABLE streak_credits OWNER TO ranktifyUser;
+
+COMMIT;
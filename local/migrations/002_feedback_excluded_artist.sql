@@ -0,0 +1,21 @@
+-- Upgrades databases created before excluded artists were remembered by artist_id
+-- rather than matched by the songs' artist text. Feedback keeps the main artist of the
+-- song it was given on. init.sql already has this, run it once against older databases.
+BEGIN;
+
+ALTER TABLE recommendation_feedback
+    ADD COLUMN IF NOT EXISTS excluded_artist_id INTEGER REFERENCES artists(artist_id) ON DELETE SET NULL;
+
+UPDATE recommendation_feedback f
+SET excluded_artist_id = (
+    SELECT sa.artist_id
+    FROM song_artists sa
+    WHERE sa.song_id = f.song_id
+    ORDER BY sa.position
+    LIMIT 1
+)
+WHERE f.exclude_artist;
+
+ALTER TABLE recommendation_feedback DROP COLUMN IF EXISTS exclude_artist;
+
+COMMIT;
@@ -0,0 +1,34 @@
+package main
+
+import (
+	"context"
+	"log"
+
+	"github.com/ranktify/ranktify-be/config"
+	"github.com/ranktify/ranktify-be/internal/dao"
+	"github.com/ranktify/ranktify-be/internal/service"
+	"github.com/ranktify/ranktify-be/internal/spotify"
+)
+
+// songs enriched per batch, batches run until no song is left to enrich
+const batchSize = 200
+
+func main() {
+	db := config.SetupConnection()
+	enrichment := service.NewGenreEnrichmentService(dao.NewSongsDAO(db), spotify.NewClient())
+
+	total := 0
+	for {
+		processed, err := enrichment.EnrichSongs(context.Background(), batchSize)
+		if err != nil {
+			log.Println("Couldn't enrich song genres, error:", err.Error())
+			break
+		}
+		if processed == 0 {
+			break
+		}
+		total += processed
+	}
+
+	log.Printf("Enriched the genres of %d songs", total)
+}
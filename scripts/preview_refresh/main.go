@@ -0,0 +1,36 @@
+package main
+
+import (
+	"context"
+	"log"
+
+	"github.com/ranktify/ranktify-be/config"
+	"github.com/ranktify/ranktify-be/internal/dao"
+	"github.com/ranktify/ranktify-be/internal/service"
+	"github.com/ranktify/ranktify-be/internal/spotify"
+)
+
+// songs refreshed per batch, batches run until there are no stale songs left to try
+const batchSize = 100
+
+func main() {
+	db := config.SetupConnection()
+	previews := service.NewPreviewService(dao.NewPreviewCacheDAO(db), spotify.NewClient())
+
+	total, totalFailed := 0, 0
+	for {
+		refreshed, failed, err := previews.RefreshStalePreviews(context.Background(), batchSize)
+		total += refreshed
+		totalFailed += failed
+		if err != nil {
+			log.Println("Couldn't refresh song previews, error:", err.Error())
+			break
+		}
+		// failed songs are backed off, an empty batch means every stale song was tried
+		if refreshed+failed == 0 {
+			break
+		}
+	}
+
+	log.Printf("Refreshed the previews of %d songs, %d failed", total, totalFailed)
+}
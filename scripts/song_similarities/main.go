@@ -0,0 +1,22 @@
+package main
+
+import (
+	"context"
+	"log"
+
+	"github.com/ranktify/ranktify-be/config"
+	"github.com/ranktify/ranktify-be/internal/dao"
+	"github.com/ranktify/ranktify-be/internal/service"
+)
+
+func main() {
+	db := config.SetupConnection()
+	similarities := service.NewSongSimilarityService(dao.NewSongSimilaritiesDAO(db))
+
+	stored, err := similarities.RebuildSimilarities(context.Background())
+	if err != nil {
+		log.Fatalln("Couldn't rebuild the song similarities, error:", err.Error())
+	}
+
+	log.Printf("Stored %d song similarities", stored)
+}